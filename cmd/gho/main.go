@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"ghostal/pkg/adapters/file_data_store"
 	"ghostal/pkg/adapters/logrus_logger"
 	"ghostal/pkg/adapters/mongo_db_operator"
+	"ghostal/pkg/adapters/mysql_db_operator"
 	"ghostal/pkg/adapters/postgres_db_operator"
 	"ghostal/pkg/adapters/pretty_table_builder"
+	"ghostal/pkg/adapters/redis_db_operator"
+	"ghostal/pkg/adapters/sqlite_db_operator"
+	"ghostal/pkg/adapters/terminal_progress_reporter"
 	"ghostal/pkg/app"
 	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
 	"ghostal/pkg/values"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -20,14 +28,31 @@ var (
 var dbOperatorBuilders = []definitions.IDBOperatorBuilder{
 	&postgres_db_operator.PostgresDBOperatorBuilder{},
 	&mongo_db_operator.MongoDBOperatorBuilder{},
+	&mysql_db_operator.MySQLDBOperatorBuilder{},
+	&sqlite_db_operator.SQLiteDBOperatorBuilder{},
+	&redis_db_operator.RedisDBOperatorBuilder{},
 }
 
 var logger = logrus_logger.NewLogrusLogger()
 var tableBuilder = pretty_table_builder.NewPrettyTableBuilder()
 
+// the bar is drawn on stderr, so that stdout stays parseable, and only when both are terminals
+var progressReporter = terminal_progress_reporter.NewTerminalProgressReporter(
+	os.Stderr,
+	utils.IsTerminal(os.Stdout) && utils.IsTerminal(os.Stderr),
+	logger,
+)
+
 var start = time.Now()
 
-func exit(err error) {
+// exit stays silent when the output is JSON or YAML, the result and the error are already part of it
+func exit(err error, outputFormat string) {
+	if outputFormat != values.TableOutputFormat {
+		if err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if err == nil {
 		logger.Passthrough("Done in %.3fs.\n", time.Since(start).Seconds())
 		os.Exit(0)
@@ -40,7 +65,13 @@ func exit(err error) {
 func main() {
 	executable := os.Args[0]
 	args := os.Args[1:]
+	// Ctrl-C cancels the running operation, which rolls back what it has done so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	app := app.NewApp(Version, dbOperatorBuilders, logger, tableBuilder)
+	app.SetProgressReporter(progressReporter)
 	dataStore := file_data_store.NewFileDataStore(values.DefaultConfigFilepath)
-	exit(app.Run(dataStore, executable, args))
+	err := app.Run(ctx, dataStore, executable, args)
+	progressReporter.Clear()
+	stop()
+	exit(err, app.OutputFormat())
 }
@@ -0,0 +1,57 @@
+package file_data_store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomically replaces `path` by `data` in one step, a crash leaves either the old or the new file behind,
+// never a truncated one
+func writeFileAtomically(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	dir := filepath.Dir(path)
+	// in the same directory, renaming across file systems isn't atomic
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempPath := file.Name()
+	if err := writeAndSync(file, data, mode); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write %s: %w", tempPath, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+func writeAndSync(file *os.File, data []byte, mode os.FileMode) error {
+	_, err := file.Write(data)
+	if err == nil {
+		err = file.Chmod(mode)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir makes the rename durable, it is best effort since directories can't be synced everywhere, e.g. on Windows
+func syncDir(dir string) {
+	file, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = file.Sync()
+	_ = file.Close()
+}
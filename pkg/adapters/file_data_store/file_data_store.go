@@ -1,20 +1,34 @@
 package file_data_store
 
 import (
+	"context"
+	"fmt"
+	"ghostal/pkg/definitions"
 	"ghostal/pkg/values"
+	"net/url"
 	"os"
 	"path"
 )
 
 type FileDataStore struct {
 	filepath string
+	// climb looks for the file in the parent directories too
+	climb bool
 }
 
 func NewFileDataStore(filepath string) *FileDataStore {
+	return &FileDataStore{filepath: filepath, climb: true}
+}
+
+// NewFixedFileDataStore uses `filepath` as is, without looking for it in the parent directories
+func NewFixedFileDataStore(filepath string) *FileDataStore {
 	return &FileDataStore{filepath: filepath}
 }
 
 func (d *FileDataStore) resolveFilepath() (string, error) {
+	if !d.climb {
+		return d.filepath, nil
+	}
 	filepath := d.filepath
 	for depth := 0; depth < values.ConfigScanClimbMaxDepth; depth++ {
 		_, err := os.Stat(filepath)
@@ -36,6 +50,10 @@ func (d *FileDataStore) Load() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	return readFile(filepath)
+}
+
+func readFile(filepath string) ([]byte, error) {
 	data, err := os.ReadFile(filepath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -48,10 +66,84 @@ func (d *FileDataStore) Load() ([]byte, error) {
 	return data, nil
 }
 
+func (d *FileDataStore) Append(data []byte) error {
+	filepath, err := d.resolveFilepath()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (d *FileDataStore) Sibling(suffix string) definitions.IDataStore {
+	filepath, err := d.resolveFilepath()
+	if err != nil {
+		// surfaces again on load/save
+		filepath = d.filepath
+	}
+	// next to the resolved file, never in one of its parents
+	return NewFixedFileDataStore(filepath + suffix)
+}
+
 func (d *FileDataStore) Save(data []byte) error {
 	filepath, err := d.resolveFilepath()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filepath, data, 0644)
+	return writeFileAtomically(filepath, data)
+}
+
+// Update saves what `fn` makes of the stored data, no other process can update the file in between.
+// The lock is taken on a file of its own, since the data file is replaced on every save.
+func (d *FileDataStore) Update(fn func(data []byte) ([]byte, error)) error {
+	filepath, err := d.resolveFilepath()
+	if err != nil {
+		return err
+	}
+	lock, _, err := tryLockFile(filepath+values.UpdateLockFileSuffix, true)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	data, err := readFile(filepath)
+	if err != nil {
+		return err
+	}
+	newData, err := fn(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(filepath, newData)
+}
+
+// Dir returns the directory of the resolved file
+func (d *FileDataStore) Dir() string {
+	filepath, err := d.resolveFilepath()
+	if err != nil {
+		filepath = d.filepath
+	}
+	return path.Dir(filepath)
+}
+
+// Lock locks a file of its own per `name` next to the resolved file,
+// other gho processes using the same config can't take the same lock until unlocked
+func (d *FileDataStore) Lock(ctx context.Context, name string, wait bool) (func(), error) {
+	filepath, err := d.resolveFilepath()
+	if err != nil {
+		return nil, err
+	}
+	// escaped, the name may contain characters that aren't allowed in file names
+	lockPath := filepath + values.LockFileSuffix + "." + url.QueryEscape(name)
+	lock, err := acquireFileLock(ctx, lockPath, wait, fmt.Sprintf("\"%s\" in config \"%s\"", name, filepath))
+	if err != nil {
+		return nil, err
+	}
+	return lock.release, nil
 }
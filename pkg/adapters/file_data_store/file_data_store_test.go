@@ -0,0 +1,105 @@
+package file_data_store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUnit_FileDataStore_Lock(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".ghostal")
+	store := NewFixedFileDataStore(configPath)
+	other := NewFixedFileDataStore(configPath)
+
+	unlock, err := store.Lock(context.Background(), "main", false)
+	assert.NoError(t, err)
+	_, err = other.Lock(context.Background(), "main", false)
+	assert.ErrorIs(t, err, values.LockedErr)
+	assert.Contains(t, err.Error(), fmt.Sprintf("locked by pid %d", os.Getpid()))
+	// the other names stay free
+	unlockOtherName, err := other.Lock(context.Background(), "side/project", false)
+	assert.NoError(t, err)
+	unlockOtherName()
+
+	// waits until released
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = other.Lock(ctx, "main", true)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlock()
+	}()
+	unlockOther, err := other.Lock(context.Background(), "main", true)
+	assert.NoError(t, err)
+	unlockOther()
+
+	unlockAgain, err := store.Lock(context.Background(), "main", false)
+	assert.NoError(t, err)
+	unlockAgain()
+	assert.FileExists(t, configPath+values.LockFileSuffix+".main")
+	assert.FileExists(t, configPath+values.LockFileSuffix+".side%2Fproject")
+}
+
+func TestUnit_FileDataStore_Save(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, ".ghostal")
+	store := NewFixedFileDataStore(configPath)
+
+	assert.NoError(t, store.Save([]byte("first")))
+	assert.NoError(t, store.Save([]byte("second")))
+	data, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+
+	info, err := os.Stat(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	// no temporary file is left behind
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestUnit_FileDataStore_Update(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".ghostal")
+
+	// every store has a lock file descriptor of its own, like separate processes
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := NewFixedFileDataStore(configPath).Update(func(data []byte) ([]byte, error) {
+				count := 0
+				if len(data) > 0 {
+					count, _ = strconv.Atoi(string(data))
+				}
+				return []byte(strconv.Itoa(count + 1)), nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	data, err := NewFixedFileDataStore(configPath).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "20", string(data))
+
+	// nothing is saved if the update fails
+	testErr := errors.New("test err")
+	err = NewFixedFileDataStore(configPath).Update(func(data []byte) ([]byte, error) {
+		return []byte("broken"), testErr
+	})
+	assert.ErrorIs(t, err, testErr)
+	data, err = NewFixedFileDataStore(configPath).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "20", string(data))
+}
@@ -0,0 +1,92 @@
+package file_data_store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"io"
+	"os"
+)
+
+// fileLock is an exclusive lock on a file, released by the OS if the process dies, so it never goes stale.
+// The holder writes its LockInfo into the file, for the error of the processes that can't take it.
+type fileLock struct {
+	file *os.File
+}
+
+// tryLockFile returns the lock, or the info of the holder if another process holds it,
+// unless `block` is set, then it waits for the lock to be released
+func tryLockFile(path string, block bool) (*fileLock, definitions.LockInfo, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, definitions.LockInfo{}, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	acquired, err := lockFile(file, block)
+	if err != nil {
+		_ = file.Close()
+		return nil, definitions.LockInfo{}, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	if !acquired {
+		// the holder may not have written its info yet, it is only used in the error
+		var holder definitions.LockInfo
+		if data, err := io.ReadAll(file); err == nil {
+			_ = json.Unmarshal(data, &holder)
+		}
+		_ = file.Close()
+		return nil, holder, nil
+	}
+	data, err := json.Marshal(definitions.NewLockInfo())
+	if err == nil {
+		err = writeLockInfo(file, data)
+	}
+	if err != nil {
+		_ = unlockFile(file)
+		_ = file.Close()
+		return nil, definitions.LockInfo{}, fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return &fileLock{file: file}, definitions.LockInfo{}, nil
+}
+
+func writeLockInfo(file *os.File, data []byte) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// acquireFileLock takes the lock on `path`, polling until it is released if `wait`,
+// `resource` names what the lock protects in the error
+func acquireFileLock(ctx context.Context, path string, wait bool, resource string) (*fileLock, error) {
+	var lock *fileLock
+	err := utils.Poll(ctx, values.LockPollInterval, func() (bool, error) {
+		acquired, holder, err := tryLockFile(path, false)
+		if err != nil {
+			return false, err
+		}
+		if acquired != nil {
+			lock = acquired
+			return true, nil
+		}
+		if !wait {
+			return false, holder.LockedErr(resource)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+func (l *fileLock) release() {
+	// the file is kept, removing it would let another process lock a file nobody else can see anymore
+	_ = l.file.Truncate(0)
+	_ = unlockFile(l.file)
+	_ = l.file.Close()
+}
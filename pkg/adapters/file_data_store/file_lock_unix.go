@@ -0,0 +1,26 @@
+//go:build !windows
+
+package file_data_store
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile returns false if another process holds the lock, unless `block` is set
+func lockFile(file *os.File, block bool) (bool, error) {
+	how := syscall.LOCK_EX
+	if !block {
+		how |= syscall.LOCK_NB
+	}
+	err := syscall.Flock(int(file.Fd()), how)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package file_data_store
+
+import (
+	"errors"
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+// lockedOffset is far beyond the end of the lock file, since Windows keeps other processes from reading a locked range,
+// and the info of the holder must stay readable
+const lockedOffset = 1 << 30
+
+func lockedRange() *windows.Overlapped {
+	return &windows.Overlapped{Offset: lockedOffset}
+}
+
+// lockFile returns false if another process holds the lock, unless `block` is set
+func lockFile(file *os.File, block bool) (bool, error) {
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK)
+	if !block {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	err := windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, 1, 0, lockedRange())
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(file *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, lockedRange())
+}
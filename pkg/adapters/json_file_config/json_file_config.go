@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
+	"sync"
 	"time"
 )
 
 type JSONFileConfig struct {
+	// mu makes the methods safe to call from several goroutines, e.g. with --all-projects
+	mu         sync.Mutex
 	dataStore  definitions.IDataStore
 	ConfigData definitions.ConfigData
 }
@@ -24,21 +28,39 @@ func (cm *JSONFileConfig) load() error {
 	if err != nil {
 		return err
 	}
+	return cm.decode(data)
+}
+
+func (cm *JSONFileConfig) decode(data []byte) error {
 	if len(data) == 0 {
 		return nil
 	}
-	return json.Unmarshal(data, &cm.ConfigData)
-}
-
-func (cm *JSONFileConfig) save() error {
-	data, err := json.MarshalIndent(cm.ConfigData, "", "  ")
-	if err != nil {
+	// decode into a fresh value, otherwise the maps of previously returned projects would be overwritten
+	var configData definitions.ConfigData
+	if err := json.Unmarshal(data, &configData); err != nil {
 		return err
 	}
-	return cm.dataStore.Save(data)
+	cm.ConfigData = configData
+	return nil
+}
+
+// update applies `fn` to the latest config and saves it, other processes can't change the config in between.
+// Nothing is saved if `fn` fails.
+func (cm *JSONFileConfig) update(fn func() error) error {
+	return cm.dataStore.Update(func(data []byte) ([]byte, error) {
+		if err := cm.decode(data); err != nil {
+			return nil, err
+		}
+		if err := fn(); err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(cm.ConfigData, "", "  ")
+	})
 }
 
 func (cm *JSONFileConfig) InitProject(name, dbURL string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 	if len(name) == 0 {
 		return errors.New("name cannot be empty")
 	}
@@ -46,43 +68,43 @@ func (cm *JSONFileConfig) InitProject(name, dbURL string) error {
 		return errors.New("db URL cannot be empty")
 	}
 
-	if err := cm.load(); err != nil {
-		return err
-	}
-
-	for _, p := range cm.ConfigData.Projects {
-		if p.Name == name {
-			return errors.New("project already exists")
+	return cm.update(func() error {
+		for _, p := range cm.ConfigData.Projects {
+			if p.Name == name {
+				return errors.New("project already exists")
+			}
 		}
-	}
 
-	cm.ConfigData.SelectedProject = name
+		cm.ConfigData.SelectedProject = name
 
-	newProject := definitions.Project{
-		Name:      name,
-		DBURL:     dbURL,
-		CreatedAt: time.Now(),
-	}
+		newProject := definitions.Project{
+			Name:      name,
+			DBURL:     dbURL,
+			CreatedAt: time.Now(),
+		}
 
-	cm.ConfigData.Projects = append(cm.ConfigData.Projects, newProject)
-	return cm.save()
+		cm.ConfigData.Projects = append(cm.ConfigData.Projects, newProject)
+		return nil
+	})
 }
 
 func (cm *JSONFileConfig) SelectProject(name string) error {
-	if err := cm.load(); err != nil {
-		return err
-	}
-
-	for _, p := range cm.ConfigData.Projects {
-		if p.Name == name {
-			cm.ConfigData.SelectedProject = p.Name
-			return cm.save()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.update(func() error {
+		for _, p := range cm.ConfigData.Projects {
+			if p.Name == name {
+				cm.ConfigData.SelectedProject = p.Name
+				return nil
+			}
 		}
-	}
-	return fmt.Errorf("project \"%s\" not found", name)
+		return fmt.Errorf("%w: \"%s\"", values.ProjectNotFoundErr, name)
+	})
 }
 
 func (cm *JSONFileConfig) GetProject(name *string) (definitions.Project, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 	if err := cm.load(); err != nil {
 		return definitions.Project{}, err
 	}
@@ -101,41 +123,109 @@ func (cm *JSONFileConfig) GetProject(name *string) (definitions.Project, error)
 			return p, nil
 		}
 	}
-	return definitions.Project{}, errors.New("project not found")
+	return definitions.Project{}, values.ProjectNotFoundErr
+}
+
+func (cm *JSONFileConfig) UpdateProject(name string, fn func(project *definitions.Project) error) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.update(func() error {
+		for i := range cm.ConfigData.Projects {
+			if cm.ConfigData.Projects[i].Name == name {
+				return fn(&cm.ConfigData.Projects[i])
+			}
+		}
+		return fmt.Errorf("%w: \"%s\"", values.ProjectNotFoundErr, name)
+	})
 }
 
-func (cm *JSONFileConfig) SetProject(name *string, project definitions.Project) error {
+func (cm *JSONFileConfig) GetAllProjects() (definitions.ProjectsList, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 	if err := cm.load(); err != nil {
-		return err
+		return nil, err
 	}
 
-	projectToSet := ""
-	if name != nil {
-		projectToSet = *name
-	} else if cm.ConfigData.SelectedProject != "" {
-		projectToSet = cm.ConfigData.SelectedProject
-	} else {
-		return errors.New("failed to determine project to set")
+	if cm.ConfigData.Projects == nil {
+		return make([]definitions.Project, 0), nil
+	}
+
+	return cm.ConfigData.Projects, nil
+}
+
+func (cm *JSONFileConfig) GetGroup(name string) (definitions.Group, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if err := cm.load(); err != nil {
+		return definitions.Group{}, err
 	}
 
-	for i, p := range cm.ConfigData.Projects {
-		if p.Name == projectToSet {
-			cm.ConfigData.Projects[i] = project
-			return cm.save()
+	for _, g := range cm.ConfigData.Groups {
+		if g.Name == name {
+			return g, nil
 		}
 	}
+	return definitions.Group{}, fmt.Errorf("%w: \"%s\"", values.GroupNotFoundErr, name)
+}
+
+func (cm *JSONFileConfig) SetGroup(group definitions.Group) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if len(group.Name) == 0 {
+		return errors.New("group name cannot be empty")
+	}
+	if len(group.Projects) == 0 {
+		return errors.New("group must have at least one project")
+	}
+
+	return cm.update(func() error {
+		for _, projectName := range group.Projects {
+			found := false
+			for _, p := range cm.ConfigData.Projects {
+				if p.Name == projectName {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("%w: \"%s\"", values.ProjectNotFoundErr, projectName)
+			}
+		}
 
-	return errors.New("project not found")
+		for i, g := range cm.ConfigData.Groups {
+			if g.Name == group.Name {
+				cm.ConfigData.Groups[i] = group
+				return nil
+			}
+		}
+		cm.ConfigData.Groups = append(cm.ConfigData.Groups, group)
+		return nil
+	})
 }
 
-func (cm *JSONFileConfig) GetAllProjects() (definitions.ProjectsList, error) {
+func (cm *JSONFileConfig) DeleteGroup(name string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.update(func() error {
+		for i, g := range cm.ConfigData.Groups {
+			if g.Name == name {
+				cm.ConfigData.Groups = append(cm.ConfigData.Groups[:i], cm.ConfigData.Groups[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: \"%s\"", values.GroupNotFoundErr, name)
+	})
+}
+
+func (cm *JSONFileConfig) GetAllGroups() (definitions.GroupsList, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 	if err := cm.load(); err != nil {
 		return nil, err
 	}
 
-	if cm.ConfigData.Projects == nil {
-		return make([]definitions.Project, 0), nil
+	if cm.ConfigData.Groups == nil {
+		return make([]definitions.Group, 0), nil
 	}
 
-	return cm.ConfigData.Projects, nil
+	return cm.ConfigData.Groups, nil
 }
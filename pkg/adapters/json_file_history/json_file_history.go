@@ -0,0 +1,53 @@
+package json_file_history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"ghostal/pkg/definitions"
+)
+
+// JSONFileHistory stores one JSON entry per line, so that recording a command never rewrites the file
+type JSONFileHistory struct {
+	dataStore definitions.IDataStore
+}
+
+func NewJSONFileHistory(dataStore definitions.IDataStore) *JSONFileHistory {
+	return &JSONFileHistory{
+		dataStore: dataStore,
+	}
+}
+
+func (h *JSONFileHistory) Append(entry definitions.HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return h.dataStore.Append(append(data, '\n'))
+}
+
+func (h *JSONFileHistory) List() (definitions.HistoryList, error) {
+	data, err := h.dataStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	list := make(definitions.HistoryList, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry definitions.HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// a write cut short by a crash shouldn't hide the rest of the history
+			continue
+		}
+		list = append(list, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
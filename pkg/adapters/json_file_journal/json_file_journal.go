@@ -0,0 +1,103 @@
+package json_file_journal
+
+import (
+	"encoding/json"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
+	"sync"
+)
+
+type JSONFileJournal struct {
+	// mu makes the methods safe to call from several goroutines, e.g. with --all-projects
+	mu          sync.Mutex
+	dataStore   definitions.IDataStore
+	JournalData definitions.JournalData
+}
+
+func NewJSONFileJournal(dataStore definitions.IDataStore) *JSONFileJournal {
+	return &JSONFileJournal{
+		dataStore: dataStore,
+	}
+}
+
+func (j *JSONFileJournal) load() error {
+	data, err := j.dataStore.Load()
+	if err != nil {
+		return err
+	}
+	return j.decode(data)
+}
+
+func (j *JSONFileJournal) decode(data []byte) error {
+	if len(data) == 0 {
+		j.JournalData = definitions.JournalData{}
+		return nil
+	}
+	var journalData definitions.JournalData
+	if err := json.Unmarshal(data, &journalData); err != nil {
+		return err
+	}
+	j.JournalData = journalData
+	return nil
+}
+
+// update applies `fn` to the latest journal and saves it, nothing is saved if `fn` fails
+func (j *JSONFileJournal) update(fn func() error) error {
+	return j.dataStore.Update(func(data []byte) ([]byte, error) {
+		if err := j.decode(data); err != nil {
+			return nil, err
+		}
+		if err := fn(); err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(j.JournalData, "", "  ")
+	})
+}
+
+func (j *JSONFileJournal) lastIndex(projectName string) int {
+	for i := len(j.JournalData.Entries) - 1; i >= 0; i-- {
+		if j.JournalData.Entries[i].Project == projectName {
+			return i
+		}
+	}
+	return -1
+}
+
+func (j *JSONFileJournal) Append(entry definitions.JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.update(func() error {
+		j.JournalData.Entries = append(j.JournalData.Entries, entry)
+		if len(j.JournalData.Entries) > values.JournalMaxEntries {
+			// only the recent operations are worth undoing
+			j.JournalData.Entries = j.JournalData.Entries[len(j.JournalData.Entries)-values.JournalMaxEntries:]
+		}
+		return nil
+	})
+}
+
+func (j *JSONFileJournal) Last(projectName string) (definitions.JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.load(); err != nil {
+		return definitions.JournalEntry{}, err
+	}
+	idx := j.lastIndex(projectName)
+	if idx < 0 {
+		return definitions.JournalEntry{}, values.NothingToUndoErr
+	}
+	return j.JournalData.Entries[idx], nil
+}
+
+func (j *JSONFileJournal) RemoveLast(projectName string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.update(func() error {
+		idx := j.lastIndex(projectName)
+		if idx < 0 {
+			return values.NothingToUndoErr
+		}
+		j.JournalData.Entries = append(j.JournalData.Entries[:idx], j.JournalData.Entries[idx+1:]...)
+		return nil
+	})
+}
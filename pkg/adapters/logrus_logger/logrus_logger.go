@@ -14,7 +14,8 @@ func NewLogrusLogger() *LogrusLogger {
 	instance := logrus.New()
 	instance.SetFormatter(&logrus.TextFormatter{})
 	instance.SetLevel(logrus.DebugLevel)
-	instance.SetOutput(os.Stdout)
+	// keeps stdout parseable when the output is JSON or YAML
+	instance.SetOutput(os.Stderr)
 
 	return &LogrusLogger{
 		logger: instance,
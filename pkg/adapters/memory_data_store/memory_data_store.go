@@ -1,12 +1,30 @@
 package memory_data_store
 
+import (
+	"context"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"sync"
+)
+
 type MemoryDataStore struct {
 	Data []byte
+	// BaseDir is returned as the directory of the store
+	BaseDir  string
+	Siblings map[string]*MemoryDataStore
+	updateMu sync.Mutex
+	lockMu   sync.Mutex
+	// lockedBy holds the taken locks by name
+	lockedBy map[string]definitions.LockInfo
 }
 
 func NewMemoryDataStore() *MemoryDataStore {
 	return &MemoryDataStore{
-		Data: make([]byte, 0),
+		Data:     make([]byte, 0),
+		Siblings: make(map[string]*MemoryDataStore),
+		lockedBy: make(map[string]definitions.LockInfo),
 	}
 }
 
@@ -18,3 +36,63 @@ func (m *MemoryDataStore) Save(bytes []byte) error {
 	m.Data = bytes
 	return nil
 }
+
+func (m *MemoryDataStore) Update(fn func(data []byte) ([]byte, error)) error {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+	data, err := fn(m.Data)
+	if err != nil {
+		return err
+	}
+	m.Data = data
+	return nil
+}
+
+func (m *MemoryDataStore) Append(bytes []byte) error {
+	m.Data = append(m.Data, bytes...)
+	return nil
+}
+
+func (m *MemoryDataStore) Sibling(suffix string) definitions.IDataStore {
+	if _, ok := m.Siblings[suffix]; !ok {
+		m.Siblings[suffix] = NewMemoryDataStore()
+	}
+	return m.Siblings[suffix]
+}
+
+func (m *MemoryDataStore) Dir() string {
+	return m.BaseDir
+}
+
+func (m *MemoryDataStore) Lock(ctx context.Context, name string, wait bool) (func(), error) {
+	err := utils.Poll(ctx, values.LockPollInterval, func() (bool, error) {
+		m.lockMu.Lock()
+		defer m.lockMu.Unlock()
+		holder, locked := m.lockedBy[name]
+		if !locked {
+			m.lockedBy[name] = definitions.NewLockInfo()
+			return true, nil
+		}
+		if !wait {
+			return false, holder.LockedErr(fmt.Sprintf("\"%s\"", name))
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		m.SetLockedBy(name, nil)
+	}, nil
+}
+
+// SetLockedBy pretends that another process holds the lock `name`, nil releases it
+func (m *MemoryDataStore) SetLockedBy(name string, info *definitions.LockInfo) {
+	m.lockMu.Lock()
+	defer m.lockMu.Unlock()
+	if info == nil {
+		delete(m.lockedBy, name)
+		return
+	}
+	m.lockedBy[name] = *info
+}
@@ -0,0 +1,157 @@
+package mongo_db_operator
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+)
+
+const viewCollectionType = "view"
+
+// snapshotMarkerCollection is written to every snapshot database, so that snapshots of empty databases exist too
+const snapshotMarkerCollection = "__ghostal_snapshot__"
+
+// MetadataArchiveSuffix marks the entry holding the options and indexes of a collection in an exported snapshot
+const MetadataArchiveSuffix = ".metadata.json"
+
+// collectionSpec holds everything needed to re-create a collection apart from its documents
+type collectionSpec struct {
+	Name string `bson:"name"`
+	// Type is "collection", "timeseries" or "view"
+	Type string `bson:"type"`
+	// Options are passed as is to the create command (capped, validator, collation, timeseries, viewOn, ...)
+	Options bson.Raw `bson:"options"`
+	// Indexes are the index definitions except the default _id index
+	Indexes []bson.Raw `bson:"indexes"`
+}
+
+func (c collectionSpec) isView() bool {
+	return c.Type == viewCollectionType
+}
+
+func listIndexes(ctx context.Context, collection *mongo.Collection) ([]bson.Raw, error) {
+	cur, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer cur.Close(context.Background())
+
+	indexes := make([]bson.Raw, 0)
+	for cur.Next(ctx) {
+		if name, ok := cur.Current.Lookup("name").StringValueOK(); ok && name == "_id_" {
+			// always created along with the collection
+			continue
+		}
+		index := make(bson.Raw, len(cur.Current))
+		copy(index, cur.Current)
+		indexes = append(indexes, index)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %s", err)
+	}
+	return indexes, nil
+}
+
+// listCollectionSpecs returns the specs of every user collection and view in `db`
+func listCollectionSpecs(ctx context.Context, db *mongo.Database) ([]collectionSpec, error) {
+	specifications, err := db.ListCollectionSpecifications(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	specs := make([]collectionSpec, 0, len(specifications))
+	for _, specification := range specifications {
+		// system collections (views, timeseries buckets, profiling...) are maintained by the server
+		if strings.HasPrefix(specification.Name, "system.") {
+			continue
+		}
+		// the marker belongs to the snapshot, not to the data
+		if specification.Name == snapshotMarkerCollection {
+			continue
+		}
+		spec := collectionSpec{
+			Name:    specification.Name,
+			Type:    specification.Type,
+			Options: specification.Options,
+		}
+		if !spec.isView() {
+			spec.Indexes, err = listIndexes(ctx, db.Collection(spec.Name))
+			if err != nil {
+				return nil, err
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// toD copies `doc` into a command document, leaving out `skipKeys`
+func toD(doc bson.Raw, skipKeys ...string) (bson.D, error) {
+	elements, err := doc.Elements()
+	if err != nil {
+		return nil, err
+	}
+	result := make(bson.D, 0, len(elements))
+	for _, element := range elements {
+		skip := false
+		for _, key := range skipKeys {
+			if element.Key() == key {
+				skip = true
+			}
+		}
+		if !skip {
+			result = append(result, bson.E{Key: element.Key(), Value: element.Value()})
+		}
+	}
+	return result, nil
+}
+
+// createCollection creates the collection or view described by `spec` with its original options
+func createCollection(ctx context.Context, db *mongo.Database, spec collectionSpec) error {
+	command := bson.D{{Key: "create", Value: spec.Name}}
+	if len(spec.Options) > 0 {
+		options, err := toD(spec.Options)
+		if err != nil {
+			return fmt.Errorf("invalid options of collection %s: %w", spec.Name, err)
+		}
+		command = append(command, options...)
+	}
+	if err := db.RunCommand(ctx, command).Err(); err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", spec.Name, err)
+	}
+	return nil
+}
+
+func createIndexes(ctx context.Context, db *mongo.Database, spec collectionSpec) error {
+	if len(spec.Indexes) == 0 {
+		return nil
+	}
+	indexes := make(bson.A, 0, len(spec.Indexes))
+	for _, index := range spec.Indexes {
+		// "ns" is only reported by older servers and rejected by createIndexes
+		definition, err := toD(index, "ns")
+		if err != nil {
+			return fmt.Errorf("invalid index of collection %s: %w", spec.Name, err)
+		}
+		indexes = append(indexes, definition)
+	}
+	command := bson.D{
+		{Key: "createIndexes", Value: spec.Name},
+		{Key: "indexes", Value: indexes},
+	}
+	if err := db.RunCommand(ctx, command).Err(); err != nil {
+		return fmt.Errorf("failed to create indexes of collection %s: %w", spec.Name, err)
+	}
+	return nil
+}
+
+func encodeCollectionSpec(spec collectionSpec) ([]byte, error) {
+	return bson.MarshalExtJSON(spec, true, false)
+}
+
+func decodeCollectionSpec(data []byte) (collectionSpec, error) {
+	var spec collectionSpec
+	err := bson.UnmarshalExtJSON(data, true, &spec)
+	return spec, err
+}
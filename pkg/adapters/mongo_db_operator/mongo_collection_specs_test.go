@@ -0,0 +1,62 @@
+package mongo_db_operator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func mustMarshal(doc bson.D) bson.Raw {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+func TestUnit_EncodeCollectionSpec(t *testing.T) {
+	spec := collectionSpec{
+		Name: "events",
+		Type: "collection",
+		Options: mustMarshal(bson.D{
+			{Key: "capped", Value: true},
+			{Key: "size", Value: int64(4096)},
+			{Key: "validator", Value: bson.D{{Key: "year", Value: bson.D{{Key: "$gte", Value: 1900}}}}},
+		}),
+		Indexes: []bson.Raw{
+			mustMarshal(bson.D{
+				{Key: "v", Value: 2},
+				{Key: "key", Value: bson.D{{Key: "createdAt", Value: 1}}},
+				{Key: "name", Value: "createdAt_1"},
+				{Key: "expireAfterSeconds", Value: 3600},
+			}),
+		},
+	}
+	data, err := encodeCollectionSpec(spec)
+	assert.NoError(t, err)
+
+	decoded, err := decodeCollectionSpec(data)
+	assert.NoError(t, err)
+	assert.Equal(t, spec.Name, decoded.Name)
+	assert.Equal(t, spec.Type, decoded.Type)
+	assert.False(t, decoded.isView())
+	assert.Equal(t, spec.Options, decoded.Options)
+	assert.Equal(t, spec.Indexes, decoded.Indexes)
+}
+
+func TestUnit_ToD(t *testing.T) {
+	doc := mustMarshal(bson.D{
+		{Key: "v", Value: 2},
+		{Key: "key", Value: bson.D{{Key: "email", Value: 1}}},
+		{Key: "name", Value: "email_1"},
+		{Key: "ns", Value: "gho_db.users"},
+		{Key: "unique", Value: true},
+	})
+	result, err := toD(doc, "ns")
+	assert.NoError(t, err)
+	keys := make([]string, 0)
+	for _, element := range result {
+		keys = append(keys, element.Key)
+	}
+	assert.Equal(t, []string{"v", "key", "name", "unique"}, keys)
+}
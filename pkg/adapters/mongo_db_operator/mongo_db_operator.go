@@ -1,6 +1,7 @@
 package mongo_db_operator
 
 import (
+	"context"
 	"fmt"
 	"ghostal/pkg/definitions"
 	"ghostal/pkg/utils"
@@ -9,7 +10,11 @@ import (
 )
 
 type MongoDBOperator struct {
-	mongoURL *MongoURL
+	mongoURL  *MongoURL
+	workers   int
+	batchSize int
+	reporter  definitions.IProgressReporter
+	logger    definitions.ILogger
 }
 
 func CreateMongoDBOperator(dbURL string) (*MongoDBOperator, error) {
@@ -20,17 +25,62 @@ func CreateMongoDBOperator(dbURL string) (*MongoDBOperator, error) {
 	if mongoURL.dbURL.Scheme != "mongodb" {
 		return nil, values.UnsupportedURLSchemeError
 	}
+	workers, err := mongoURL.Workers()
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := mongoURL.BatchSize()
+	if err != nil {
+		return nil, err
+	}
 	return &MongoDBOperator{
-		mongoURL: mongoURL,
+		mongoURL:  mongoURL,
+		workers:   workers,
+		batchSize: batchSize,
 	}, nil
 }
 
-func (mo *MongoDBOperator) connect(useDefault bool) (*mongo.Client, func(), error) {
-	return createMongoConnection(mo.mongoURL, useDefault)
+// SetProgressReporter reports the steps of the operations, and the documents as they are copied
+func (mo *MongoDBOperator) SetProgressReporter(reporter definitions.IProgressReporter) {
+	mo.reporter = reporter
 }
 
-func (mo *MongoDBOperator) checkSnapshotName(snapshotName string) error {
-	list, err := mo.ListSnapshots()
+func (mo *MongoDBOperator) SetLogger(logger definitions.ILogger) {
+	mo.logger = logger
+}
+
+func (mo *MongoDBOperator) warnSkippedDB(dbName string, err error) {
+	if mo.logger != nil {
+		mo.logger.Warning("skipped database \"%s\", it looks like a snapshot but can't be read: %s", dbName, err)
+	}
+}
+
+func (mo *MongoDBOperator) cloneOptions() cloneOptions {
+	opts := cloneOptions{
+		workers:   mo.workers,
+		batchSize: mo.batchSize,
+	}
+	if mo.reporter != nil {
+		opts.onProgress = func(progress CloneProgress) {
+			mo.reporter.Report(definitions.Progress{
+				Phase:   fmt.Sprintf("copying collection %d of %d (%s)", progress.CollectionIndex, progress.NumCollections, progress.Collection),
+				Current: progress.CopiedDocuments,
+				Total:   progress.TotalDocuments,
+			})
+		}
+		opts.onPhase = func(phase string) {
+			mo.reporter.Report(definitions.Progress{Phase: phase})
+		}
+	}
+	return opts
+}
+
+func (mo *MongoDBOperator) connect(ctx context.Context, useDefault bool) (*mongo.Client, func(), error) {
+	return createMongoConnection(ctx, mo.mongoURL, useDefault)
+}
+
+func (mo *MongoDBOperator) checkSnapshotName(ctx context.Context, snapshotName string) error {
+	list, err := mo.ListSnapshots(ctx)
 	if err != nil {
 		return err
 	}
@@ -44,11 +94,11 @@ func (mo *MongoDBOperator) checkSnapshotName(snapshotName string) error {
 	return nil
 }
 
-func (mo *MongoDBOperator) Snapshot(snapshotName string) error {
-	if err := mo.checkSnapshotName(snapshotName); err != nil {
+func (mo *MongoDBOperator) Snapshot(ctx context.Context, snapshotName string) error {
+	if err := mo.checkSnapshotName(ctx, snapshotName); err != nil {
 		return err
 	}
-	db, close, err := mo.connect(true)
+	db, close, err := mo.connect(ctx, true)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -56,17 +106,46 @@ func (mo *MongoDBOperator) Snapshot(snapshotName string) error {
 	sourceDatabase := mo.mongoURL.DBName()
 	destinationDatabase := snapshotName
 
-	return snapshotDB(db, sourceDatabase, destinationDatabase)
+	return snapshotDB(ctx, db, sourceDatabase, destinationDatabase, mo.cloneOptions())
+}
+
+// ReplaceSnapshot takes the new snapshot before dropping the previous one, so that a failure never loses both
+func (mo *MongoDBOperator) ReplaceSnapshot(ctx context.Context, snapshotName string) error {
+	return mo.replace(ctx, snapshotName, func(db *mongo.Client) error {
+		return snapshotDB(ctx, db, mo.mongoURL.DBName(), snapshotName, mo.cloneOptions())
+	})
+}
+
+// replace runs `create`, then drops the snapshot databases that were named `snapshotName` before
+func (mo *MongoDBOperator) replace(ctx context.Context, snapshotName string, create func(db *mongo.Client) error) error {
+	db, close, err := mo.connect(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+	list, err := listSnapshots(ctx, db, mo.mongoURL.DBName(), mo.warnSkippedDB)
+	if err != nil {
+		return err
+	}
+	if err := create(db); err != nil {
+		return err
+	}
+	for _, item := range list.Named(snapshotName) {
+		if err := dropDB(ctx, db, item.DBName); err != nil {
+			return fmt.Errorf("failed to drop the previous snapshot \"%s\": %w", snapshotName, err)
+		}
+	}
+	return nil
 }
 
-func (mo *MongoDBOperator) Restore(snapshotName string, fast bool) error {
-	db, close, err := mo.connect(true)
+func (mo *MongoDBOperator) Restore(ctx context.Context, snapshotName string, fast bool) error {
+	db, close, err := mo.connect(ctx, true)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 	defer close()
 
-	allDatabases, err := listSnapshots(db, mo.mongoURL.DBName())
+	allDatabases, err := listSnapshots(ctx, db, mo.mongoURL.DBName(), mo.warnSkippedDB)
 	if err != nil {
 		return err
 	}
@@ -74,40 +153,138 @@ func (mo *MongoDBOperator) Restore(snapshotName string, fast bool) error {
 		if d.SnapshotName == snapshotName {
 			originalDBName := mo.mongoURL.DBName()
 			snapshotDBName := d.DBName
-			return restoreDB(db, originalDBName, snapshotDBName, fast)
+			return restoreDB(ctx, db, originalDBName, snapshotDBName, fast, mo.cloneOptions())
 		}
 	}
 
 	return values.SnapshotNotExistsErr
 }
 
-func (mo *MongoDBOperator) Delete(snapshotName string) error {
-	db, close, err := mo.connect(true)
+func (mo *MongoDBOperator) Delete(ctx context.Context, snapshotName string) error {
+	db, close, err := mo.connect(ctx, true)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 	defer close()
 
-	allDatabases, err := listSnapshots(db, mo.mongoURL.DBName())
+	allDatabases, err := listSnapshots(ctx, db, mo.mongoURL.DBName(), mo.warnSkippedDB)
 	if err != nil {
 		return err
 	}
 	for _, d := range allDatabases {
 		if d.SnapshotName == snapshotName {
 			snapshotDBName := d.DBName
-			return dropDB(db, snapshotDBName)
+			return dropDB(ctx, db, snapshotDBName)
 		}
 	}
 
 	return values.SnapshotNotExistsErr
 }
 
-func (mo *MongoDBOperator) ListSnapshots() (definitions.SnapshotList, error) {
-	db, close, err := mo.connect(true)
+func (mo *MongoDBOperator) ListSnapshots(ctx context.Context) (definitions.SnapshotList, error) {
+	db, close, err := mo.connect(ctx, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 	defer close()
 
-	return listSnapshots(db, mo.mongoURL.DBName())
+	return listSnapshots(ctx, db, mo.mongoURL.DBName(), mo.warnSkippedDB)
+}
+
+func (mo *MongoDBOperator) FindEmergencyBackup(ctx context.Context) (*definitions.EmergencyBackup, error) {
+	db, close, err := mo.connect(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer close()
+
+	return findEmergencyBackup(ctx, db, mo.mongoURL.DBName())
+}
+
+func (mo *MongoDBOperator) RollBack(ctx context.Context) error {
+	db, close, err := mo.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	return rollBackEmergencyBackup(ctx, db, mo.mongoURL.DBName(), mo.cloneOptions())
+}
+
+func (mo *MongoDBOperator) RollForward(ctx context.Context) error {
+	db, close, err := mo.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	return rollForwardEmergencyBackup(ctx, db, mo.mongoURL.DBName())
+}
+
+// Lock keeps other gho processes from working on the database until unlocked, even with another config
+func (mo *MongoDBOperator) Lock(ctx context.Context, wait bool) (context.Context, func(), error) {
+	db, close, err := mo.connect(ctx, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	lockedCtx, unlock, err := lockDB(ctx, db, mo.mongoURL.DBName(), wait)
+	if err != nil {
+		close()
+		return nil, nil, err
+	}
+	return lockedCtx, func() {
+		unlock()
+		close()
+	}, nil
+}
+
+func (mo *MongoDBOperator) ExportSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveWriter) error {
+	db, close, err := mo.connect(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+
+	allDatabases, err := listSnapshots(ctx, db, mo.mongoURL.DBName(), mo.warnSkippedDB)
+	if err != nil {
+		return err
+	}
+	for _, d := range allDatabases {
+		if d.SnapshotName == snapshotName {
+			return exportDB(ctx, db, d.DBName, archive)
+		}
+	}
+
+	return values.SnapshotNotExistsErr
+}
+
+func (mo *MongoDBOperator) ImportSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveReader) error {
+	if err := mo.checkSnapshotName(ctx, snapshotName); err != nil {
+		return err
+	}
+	db, close, err := mo.connect(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+	return mo.importSnapshot(ctx, db, snapshotName, archive)
+}
+
+func (mo *MongoDBOperator) ReplaceWithImportedSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveReader) error {
+	return mo.replace(ctx, snapshotName, func(db *mongo.Client) error {
+		return mo.importSnapshot(ctx, db, snapshotName, archive)
+	})
+}
+
+func (mo *MongoDBOperator) importSnapshot(ctx context.Context, db *mongo.Client, snapshotName string, archive definitions.ISnapshotArchiveReader) error {
+	snapshotDBName, err := buildSnapshotDBName(mo.mongoURL.DBName(), snapshotName)
+	if err != nil {
+		return err
+	}
+	if err := importDB(ctx, db, snapshotDBName, archive, mo.cloneOptions()); err != nil {
+		// don't leave a partial snapshot behind
+		_ = dropDB(context.WithoutCancel(ctx), db, snapshotDBName)
+		return err
+	}
+	return nil
 }
@@ -1,13 +1,21 @@
 package mongo_db_operator
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
 	"testing"
+	"time"
 )
 
 const DBPassword = "gho_pass"
@@ -79,21 +87,27 @@ func runTest(t *testing.T, dbUser string) {
 	assert.Equal(t, 5, getNumVehicles(dbURL))
 
 	{
-		assert.NoError(t, operator.Snapshot("v1"))
+		assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
 	}
 
 	{
-		allDatabases, err := operator.ListSnapshots()
+		allDatabases, err := operator.ListSnapshots(context.Background())
 		assert.NoError(t, err)
 		assert.Len(t, allDatabases, 1)
 	}
 
 	{
-		assert.NoError(t, operator.Snapshot("v2"))
+		// every escaped special char takes 3 characters of the database name
+		err := operator.Snapshot(context.Background(), "before-migration_42.1-of-the-users-table")
+		assert.ErrorIs(t, err, values.SnapshotNameTooLongErr)
 	}
 
 	{
-		allDatabases, err := operator.ListSnapshots()
+		assert.NoError(t, operator.Snapshot(context.Background(), "v2"))
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
 		assert.NoError(t, err)
 		assert.Len(t, allDatabases, 2)
 	}
@@ -121,21 +135,242 @@ func runTest(t *testing.T, dbUser string) {
 	assert.Equal(t, 2, getNumVehicles(dbURL))
 
 	{
-		err := operator.Restore("v1", false)
+		err := operator.Restore(context.Background(), "v1", false)
 		assert.NoError(t, err)
 	}
 
 	assert.Equal(t, 5, getNumVehicles(dbURL))
 
 	{
-		err := operator.Delete("v2")
+		err := operator.Delete(context.Background(), "v2")
 		assert.NoError(t, err)
 	}
 
 	{
-		allDatabases, err := operator.ListSnapshots()
+		allDatabases, err := operator.ListSnapshots(context.Background())
 		assert.NoError(t, err)
 		assert.Len(t, allDatabases, 1)
 		assert.Equal(t, "v1", allDatabases[0].SnapshotName)
 	}
 }
+
+type memoryArchive map[string]*bytes.Buffer
+
+func (m memoryArchive) CreateEntry(name string) (io.Writer, error) {
+	m[name] = bytes.NewBuffer(nil)
+	return m[name], nil
+}
+
+func (m memoryArchive) Manifest() definitions.SnapshotArchiveManifest {
+	return definitions.SnapshotArchiveManifest{}
+}
+
+func (m memoryArchive) EntryNames() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m memoryArchive) OpenEntry(name string) (io.ReadCloser, error) {
+	entry, ok := m[name]
+	if !ok {
+		return nil, values.InvalidSnapshotArchiveErr
+	}
+	return io.NopCloser(bytes.NewReader(entry.Bytes())), nil
+}
+
+func TestIntegration_MongoDBOperator_Export(t *testing.T) {
+	dbURL, cleanup := createMongoContainer(DBName, "admin", DBPassword)
+	defer cleanup()
+
+	operator, err := CreateMongoDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WriteMongoDBSeedData(dbURL, "vehicles")
+	assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+
+	archive := make(memoryArchive)
+	assert.ErrorIs(t, operator.ExportSnapshot(context.Background(), "v2", archive), values.SnapshotNotExistsErr)
+	assert.NoError(t, operator.ExportSnapshot(context.Background(), "v1", archive))
+
+	entry, ok := archive[CollectionsArchiveDir+"vehicles.bson"]
+	assert.True(t, ok)
+	numDocuments := 0
+	for entry.Len() > 0 {
+		doc, err := bson.ReadDocument(entry)
+		assert.NoError(t, err)
+		assert.NoError(t, doc.Validate())
+		numDocuments++
+	}
+	assert.Equal(t, 5, numDocuments)
+}
+
+func TestIntegration_MongoDBOperator_Import(t *testing.T) {
+	dbURL, cleanup := createMongoContainer(DBName, "admin", DBPassword)
+	defer cleanup()
+
+	operator, err := CreateMongoDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WriteMongoDBSeedData(dbURL, "vehicles")
+	assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+
+	archive := make(memoryArchive)
+	assert.NoError(t, operator.ExportSnapshot(context.Background(), "v1", archive))
+	assert.ErrorIs(t, operator.ImportSnapshot(context.Background(), "v1", archive), values.SnapshotNameTakenErr)
+	assert.NoError(t, operator.ImportSnapshot(context.Background(), "v2", archive))
+
+	{
+		// modify DB before restoring snapshot
+		collection, cleanup := GetMongoDBCollection(dbURL, "vehicles")
+		defer cleanup()
+		_, err := collection.DeleteMany(context.Background(), bson.D{})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 0, getNumVehicles(dbURL))
+	assert.NoError(t, operator.Restore(context.Background(), "v2", false))
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+}
+
+func findCollectionSpec(t *testing.T, db *mongo.Database, name string) collectionSpec {
+	specs, err := listCollectionSpecs(context.Background(), db)
+	assert.NoError(t, err)
+	spec, err := utils.Find(specs, func(spec collectionSpec) bool {
+		return spec.Name == name
+	})
+	assert.NoError(t, err, name)
+	return spec
+}
+
+func TestIntegration_MongoDBOperator_PreservesMetadata(t *testing.T) {
+	dbURL, cleanup := createMongoContainer(DBName, "admin", DBPassword)
+	defer cleanup()
+
+	operator, err := CreateMongoDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WriteMongoDBSeedData(dbURL, "vehicles")
+	collection, cleanupCollection := GetMongoDBCollection(dbURL, "vehicles")
+	defer cleanupCollection()
+	db := collection.Database()
+	ctx := context.Background()
+
+	// unique and TTL indexes
+	_, err = collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "model", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "soldAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(3600)},
+	})
+	assert.NoError(t, err)
+	// capped collection with validator and collation
+	assert.NoError(t, db.CreateCollection(ctx, "logs", options.CreateCollection().
+		SetCapped(true).
+		SetSizeInBytes(4096).
+		SetValidator(bson.D{{Key: "level", Value: bson.D{{Key: "$exists", Value: true}}}}).
+		SetCollation(&options.Collation{Locale: "fr"})))
+	// timeseries collection
+	assert.NoError(t, db.CreateCollection(ctx, "metrics", options.CreateCollection().
+		SetTimeSeriesOptions(options.TimeSeries().SetTimeField("ts").SetMetaField("sensor"))))
+	_, err = db.Collection("metrics").InsertOne(ctx, bson.D{{Key: "ts", Value: time.Now()}, {Key: "sensor", Value: "a"}, {Key: "value", Value: 1}})
+	assert.NoError(t, err)
+	// view
+	assert.NoError(t, db.CreateView(ctx, "recent_vehicles", "vehicles", mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "year", Value: bson.D{{Key: "$gte", Value: 2022}}}}}},
+	}))
+
+	assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+	// restoring drops the database before copying the snapshot back
+	assert.NoError(t, operator.Restore(context.Background(), "v1", false))
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	vehicles := findCollectionSpec(t, db, "vehicles")
+	indexNames := make([]string, 0)
+	for _, index := range vehicles.Indexes {
+		indexNames = append(indexNames, index.Lookup("name").StringValue())
+		if index.Lookup("name").StringValue() == "model_1" {
+			assert.True(t, index.Lookup("unique").Boolean())
+		}
+		if index.Lookup("name").StringValue() == "soldAt_1" {
+			assert.EqualValues(t, 3600, index.Lookup("expireAfterSeconds").AsInt64())
+		}
+	}
+	assert.ElementsMatch(t, []string{"model_1", "soldAt_1"}, indexNames)
+
+	logs := findCollectionSpec(t, db, "logs")
+	assert.True(t, logs.Options.Lookup("capped").Boolean())
+	assert.NotEmpty(t, logs.Options.Lookup("validator").Document())
+	assert.Equal(t, "fr", logs.Options.Lookup("collation", "locale").StringValue())
+	_, err = db.Collection("logs").InsertOne(ctx, bson.D{{Key: "message", Value: "no level"}})
+	assert.Error(t, err, "validator should reject document")
+
+	metrics := findCollectionSpec(t, db, "metrics")
+	assert.Equal(t, "timeseries", metrics.Type)
+	numMetrics, err := db.Collection("metrics").CountDocuments(ctx, bson.D{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, numMetrics)
+
+	view := findCollectionSpec(t, db, "recent_vehicles")
+	assert.True(t, view.isView())
+	numRecent, err := db.Collection("recent_vehicles").CountDocuments(ctx, bson.D{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, numRecent)
+
+	// the archive carries the same metadata
+	archive := make(memoryArchive)
+	assert.NoError(t, operator.ExportSnapshot(context.Background(), "v1", archive))
+	assert.Contains(t, archive, CollectionsArchiveDir+"logs"+MetadataArchiveSuffix)
+	assert.Contains(t, archive, CollectionsArchiveDir+"recent_vehicles"+MetadataArchiveSuffix)
+	assert.NotContains(t, archive, CollectionsArchiveDir+"recent_vehicles.bson")
+	assert.NoError(t, operator.ImportSnapshot(context.Background(), "v2", archive))
+	assert.NoError(t, operator.Restore(context.Background(), "v2", false))
+	assert.True(t, findCollectionSpec(t, db, "logs").Options.Lookup("capped").Boolean())
+	assert.True(t, findCollectionSpec(t, db, "recent_vehicles").isView())
+	assert.Len(t, findCollectionSpec(t, db, "vehicles").Indexes, 2)
+}
+
+func TestIntegration_MongoDBOperator_EmptyDatabase(t *testing.T) {
+	dbURL, cleanup := createMongoContainer(DBName, "admin", DBPassword)
+	defer cleanup()
+
+	operator, err := CreateMongoDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	collection, cleanupCollection := GetMongoDBCollection(dbURL, "vehicles")
+	defer cleanupCollection()
+	db := collection.Database()
+	ctx := context.Background()
+
+	// the database doesn't have any collection yet
+	assert.NoError(t, operator.Snapshot(context.Background(), "empty"))
+
+	// empty collection
+	assert.NoError(t, db.CreateCollection(ctx, "drivers"))
+	assert.NoError(t, operator.Snapshot(context.Background(), "nodocuments"))
+
+	list, err := operator.ListSnapshots(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	WriteMongoDBSeedData(dbURL, "vehicles")
+	assert.NoError(t, operator.Restore(context.Background(), "nodocuments", false))
+	names, err := db.ListCollectionNames(ctx, bson.D{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"drivers"}, names)
+	assert.Equal(t, 0, getNumVehicles(dbURL))
+
+	assert.NoError(t, operator.Restore(context.Background(), "empty", false))
+	names, err = db.ListCollectionNames(ctx, bson.D{})
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	// exporting an empty snapshot and importing it back keeps it listed
+	archive := make(memoryArchive)
+	assert.NoError(t, operator.ExportSnapshot(context.Background(), "empty", archive))
+	assert.NoError(t, operator.ImportSnapshot(context.Background(), "emptycopy", archive))
+	list, err = operator.ListSnapshots(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+}
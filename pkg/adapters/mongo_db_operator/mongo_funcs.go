@@ -1,8 +1,8 @@
 package mongo_db_operator
 
 import (
+	"bufio"
 	"context"
-	"errors"
 	"fmt"
 	"ghostal/pkg/definitions"
 	"ghostal/pkg/utils"
@@ -10,18 +10,102 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
 	"strings"
 	"time"
 )
 
-func createMongoConnection(mongoURL *MongoURL, useDefault bool) (*mongo.Client, func(), error) {
-	dbURL := mongoURL.dbURL.String()
-	if useDefault {
-		newMongoURL := mongoURL.Clone()
-		newMongoURL.Path = "admin"
-		dbURL = newMongoURL.String()
+// CollectionsArchiveDir holds one entry per collection in an exported snapshot,
+// each containing the raw BSON documents back to back like mongodump does
+const CollectionsArchiveDir = "collections/"
+
+const backupDBPrefix = "temp_emergency_backup_"
+
+// maxDBNameLength is the longest database name MongoDB accepts
+const maxDBNameLength = 63
+
+// maxBatchBytes keeps every insert well below the 48MB message size limit of MongoDB
+const maxBatchBytes = 16 * 1024 * 1024
+
+// CloneProgress describes how far the copy of a database has come
+type CloneProgress struct {
+	Collection string
+	// CollectionIndex starts at 1
+	CollectionIndex int
+	NumCollections  int
+	CopiedDocuments int64
+	// TotalDocuments is estimated from the collection metadata
+	TotalDocuments int64
+}
+
+type cloneOptions struct {
+	workers    int
+	batchSize  int
+	onProgress func(progress CloneProgress)
+	// onPhase is called as the operation moves on to its next step, e.g. "dropping the database"
+	onPhase func(phase string)
+}
+
+func (o cloneOptions) report(progress CloneProgress) {
+	if o.onProgress != nil {
+		o.onProgress(progress)
+	}
+}
+
+func (o cloneOptions) phase(phase string) {
+	if o.onPhase != nil {
+		o.onPhase(phase)
+	}
+}
+
+// batchWriter buffers documents and inserts them once the batch is full, so memory stays bounded
+type batchWriter struct {
+	collection *mongo.Collection
+	batchSize  int
+	docs       []interface{}
+	numBytes   int
+	written    int64
+	onFlush    func(written int64)
+}
+
+func newBatchWriter(collection *mongo.Collection, batchSize int, onFlush func(written int64)) *batchWriter {
+	return &batchWriter{
+		collection: collection,
+		batchSize:  batchSize,
+		docs:       make([]interface{}, 0, batchSize),
+		onFlush:    onFlush,
+	}
+}
+
+func (b *batchWriter) add(ctx context.Context, doc bson.Raw) error {
+	b.docs = append(b.docs, doc)
+	b.numBytes += len(doc)
+	if len(b.docs) >= b.batchSize || b.numBytes >= maxBatchBytes {
+		return b.flush(ctx)
+	}
+	return nil
+}
+
+func (b *batchWriter) flush(ctx context.Context) error {
+	if len(b.docs) == 0 {
+		return nil
 	}
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(dbURL))
+	if _, err := b.collection.InsertMany(ctx, b.docs); err != nil {
+		return fmt.Errorf("failed to insert many: %w", err)
+	}
+	b.written += int64(len(b.docs))
+	clear(b.docs)
+	b.docs = b.docs[:0]
+	b.numBytes = 0
+	if b.onFlush != nil {
+		b.onFlush(b.written)
+	}
+	return nil
+}
+
+func createMongoConnection(ctx context.Context, mongoURL *MongoURL, useDefault bool) (*mongo.Client, func(), error) {
+	dbURL := mongoURL.ConnectionString(useDefault)
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(dbURL))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -30,69 +114,188 @@ func createMongoConnection(mongoURL *MongoURL, useDefault bool) (*mongo.Client,
 	}, nil
 }
 
-// backupDB backs up `sourceDB` and restores it if `fn` fails
-func backupDB(db *mongo.Client, sourceDB string, fn func() error) error {
-	backupDBName := "temp_emergency_backup_" + sourceDB
-	if err := cloneDB(db, sourceDB, backupDBName); err != nil {
+// backupDB backs up `sourceDB` and restores it if `fn` fails, including when `ctx` is cancelled
+func backupDB(ctx context.Context, db *mongo.Client, sourceDB string, opts cloneOptions, fn func() error) error {
+	backupDBName := backupDBPrefix + sourceDB
+	// the cleanup and the rollback must run even if cancelled
+	rollbackCtx := context.WithoutCancel(ctx)
+	opts.phase("backing up the database")
+	if err := cloneDB(ctx, db, sourceDB, backupDBName, opts); err != nil {
+		_ = dropDB(rollbackCtx, db, backupDBName)
 		return fmt.Errorf("failed clone original to backup: %w", err)
 	}
+	if err := writeSnapshotMarker(ctx, db, backupDBName); err != nil {
+		_ = dropDB(rollbackCtx, db, backupDBName)
+		return err
+	}
 	if err := fn(); err != nil {
 		// if error, drop current source and rename backup to source
-		_ = dropDB(db, sourceDB)
-		_ = cloneDB(db, backupDBName, sourceDB)
+		opts.phase("rolling back to the backup")
+		_ = dropDB(rollbackCtx, db, sourceDB)
+		_ = cloneDB(rollbackCtx, db, backupDBName, sourceDB, opts)
 		// after emergency restore, drop backup
-		_ = dropDB(db, backupDBName)
+		_ = dropDB(rollbackCtx, db, backupDBName)
 		return err
 	}
 	// is success, drop backup
-	_ = dropDB(db, backupDBName)
+	opts.phase("dropping the backup")
+	_ = dropDB(ctx, db, backupDBName)
 	return nil
 }
 
-func restoreDB(db *mongo.Client, originalDBName, snapshotDBName string, fast bool) error {
+func dbExists(ctx context.Context, db *mongo.Client, dbName string) (bool, error) {
+	names, err := db.ListDatabaseNames(ctx, bson.D{{Key: "name", Value: dbName}})
+	if err != nil {
+		return false, fmt.Errorf("failed to list databases: %w", err)
+	}
+	return len(names) > 0, nil
+}
+
+// findEmergencyBackup returns the backup left behind by a restore of `sourceDB` that was killed, nil if there is none
+func findEmergencyBackup(ctx context.Context, db *mongo.Client, sourceDB string) (*definitions.EmergencyBackup, error) {
+	backupDBName := backupDBPrefix + sourceDB
+	backupExists, err := dbExists(ctx, db, backupDBName)
+	if err != nil || !backupExists {
+		return nil, err
+	}
+	// the marker is written once the backup is fully cloned
+	markers, err := db.Database(backupDBName).ListCollectionNames(ctx, bson.D{{Key: "name", Value: snapshotMarkerCollection}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	sourceExists, err := dbExists(ctx, db, sourceDB)
+	if err != nil {
+		return nil, err
+	}
+	return &definitions.EmergencyBackup{
+		Name:     backupDBName,
+		Complete: len(markers) > 0,
+		DBExists: sourceExists,
+	}, nil
+}
+
+// rollBackEmergencyBackup replaces `sourceDB` by its emergency backup, the backup is only dropped once copied,
+// so it can be run again if interrupted
+func rollBackEmergencyBackup(ctx context.Context, db *mongo.Client, sourceDB string, opts cloneOptions) error {
+	backup, err := findEmergencyBackup(ctx, db, sourceDB)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	if !backup.CanRollBack() {
+		return fmt.Errorf("backup %s is incomplete, it can only be dropped", backup.Name)
+	}
+	opts.phase("dropping the database")
+	if err := dropDB(ctx, db, sourceDB); err != nil {
+		return fmt.Errorf("failed to drop the database: %w", err)
+	}
+	opts.phase("copying the backup")
+	if err := cloneDB(ctx, db, backup.Name, sourceDB, opts); err != nil {
+		return fmt.Errorf("failed to clone backup to original: %w", err)
+	}
+	opts.phase("dropping the backup")
+	return dropDB(ctx, db, backup.Name)
+}
+
+// rollForwardEmergencyBackup keeps `sourceDB` and drops its emergency backup
+func rollForwardEmergencyBackup(ctx context.Context, db *mongo.Client, sourceDB string) error {
+	backup, err := findEmergencyBackup(ctx, db, sourceDB)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	if !backup.CanRollForward() {
+		return fmt.Errorf("database %s is missing, it can only be rolled back", sourceDB)
+	}
+	return dropDB(ctx, db, backup.Name)
+}
+
+func restoreDB(ctx context.Context, db *mongo.Client, originalDBName, snapshotDBName string, fast bool, opts cloneOptions) error {
 	// NOTE: MongoDB doesn't support renaming databases (?)
 	//		 so cloning is used instead
 
 	if fast {
 		// drop original
-		if err := dropDB(db, originalDBName); err != nil {
+		opts.phase("dropping the database")
+		if err := dropDB(ctx, db, originalDBName); err != nil {
 			return fmt.Errorf("failed to drop original: %w", err)
 		}
 		// copy snapshot to original
-		if err := cloneDB(db, snapshotDBName, originalDBName); err != nil {
+		opts.phase("copying the snapshot")
+		if err := cloneDB(ctx, db, snapshotDBName, originalDBName, opts); err != nil {
 			return fmt.Errorf("failed to clone snapshot to orignal: %w", err)
 		}
 		return nil
 	}
 
-	return backupDB(db, originalDBName, func() error {
+	return backupDB(ctx, db, originalDBName, opts, func() error {
 		// drop original
-		if err := dropDB(db, originalDBName); err != nil {
+		opts.phase("dropping the database")
+		if err := dropDB(ctx, db, originalDBName); err != nil {
 			return fmt.Errorf("failed to drop original: %w", err)
 		}
 		// copy snapshot to original
-		if err := cloneDB(db, snapshotDBName, originalDBName); err != nil {
+		opts.phase("copying the snapshot")
+		if err := cloneDB(ctx, db, snapshotDBName, originalDBName, opts); err != nil {
 			return fmt.Errorf("failed to clone snapshot to orignal: %w", err)
 		}
 		return nil
 	})
 }
 
-func snapshotDB(db *mongo.Client, originalDBName, snapshotName string) error {
-	fullSnapshotName, err := utils.BuildSnapshotDBName(originalDBName, snapshotName, time.Now())
+// buildSnapshotDBName fails up front when the name doesn't fit, MongoDB would only fail once the copy starts
+func buildSnapshotDBName(originalDBName, snapshotName string) (string, error) {
+	snapshotDBName, err := utils.BuildSnapshotDBName(originalDBName, snapshotName, time.Now())
 	if err != nil {
+		return "", err
+	}
+	if err := utils.CheckSnapshotDBNameLength(snapshotDBName, maxDBNameLength); err != nil {
+		return "", err
+	}
+	return snapshotDBName, nil
+}
+
+func snapshotDB(ctx context.Context, db *mongo.Client, originalDBName, snapshotName string, opts cloneOptions) error {
+	fullSnapshotName, err := buildSnapshotDBName(originalDBName, snapshotName)
+	if err != nil {
+		return err
+	}
+	opts.phase("copying the database")
+	err = cloneDB(ctx, db, originalDBName, fullSnapshotName, opts)
+	if err == nil {
+		err = writeSnapshotMarker(ctx, db, fullSnapshotName)
+	}
+	if err != nil {
+		// don't leave a partial snapshot behind
+		_ = dropDB(context.WithoutCancel(ctx), db, fullSnapshotName)
 		return err
 	}
-	return cloneDB(db, originalDBName, fullSnapshotName)
+	return nil
+}
+
+// writeSnapshotMarker makes sure that `dbName` exists even if the source database had no collections,
+// since MongoDB only creates a database along with its first collection
+func writeSnapshotMarker(ctx context.Context, db *mongo.Client, dbName string) error {
+	_, err := db.Database(dbName).Collection(snapshotMarkerCollection).InsertOne(ctx, bson.D{
+		{Key: "createdAt", Value: time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot marker: %w", err)
+	}
+	return nil
 }
 
-func dropDB(db *mongo.Client, dbName string) error {
-	return db.Database(dbName).Drop(context.Background())
+func dropDB(ctx context.Context, db *mongo.Client, dbName string) error {
+	return db.Database(dbName).Drop(ctx)
 }
 
-func listSnapshots(db *mongo.Client, sourceDBName string) (definitions.SnapshotList, error) {
+func listSnapshots(ctx context.Context, db *mongo.Client, sourceDBName string, onSkip func(dbName string, err error)) (definitions.SnapshotList, error) {
 	// List all collections in the source database
-	databases, err := db.ListDatabases(context.TODO(), bson.D{})
+	databases, err := db.ListDatabases(ctx, bson.D{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list databases: %w", err)
 	}
@@ -103,7 +306,9 @@ func listSnapshots(db *mongo.Client, sourceDBName string) (definitions.SnapshotL
 		}
 		snapshotDBNameParts, err := utils.ParseSnapshotDBName(d.Name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse snapshot database name: %w", err)
+			// it must not keep the snapshots of every project on the server from being listed
+			onSkip(d.Name, err)
+			continue
 		}
 		if snapshotDBNameParts.SourceDBName != sourceDBName {
 			continue
@@ -112,62 +317,244 @@ func listSnapshots(db *mongo.Client, sourceDBName string) (definitions.SnapshotL
 			SnapshotName: snapshotDBNameParts.SnapshotName,
 			DBName:       d.Name,
 			CreatedAt:    snapshotDBNameParts.Timestamp,
+			SizeBytes:    d.SizeOnDisk,
 		})
 	}
 	return list, nil
 }
 
-func cloneDB(db *mongo.Client, sourceDBName, targetDBName string) error {
+func cloneCollection(ctx context.Context, srcColl, dstColl *mongo.Collection, opts cloneOptions, progress CloneProgress) error {
+	total, err := srcColl.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count documents: %w", err)
+	}
+	progress.TotalDocuments = total
+	opts.report(progress)
+
+	// stream the documents instead of loading the whole collection into memory
+	cur, err := srcColl.Find(ctx, bson.D{}, options.Find().SetBatchSize(int32(opts.batchSize)))
+	if err != nil {
+		return fmt.Errorf("failed to find documents: %w", err)
+	}
+	defer cur.Close(context.Background())
+
+	writer := newBatchWriter(dstColl, opts.batchSize, func(written int64) {
+		progress.CopiedDocuments = written
+		opts.report(progress)
+	})
+	for cur.Next(ctx) {
+		// the cursor reuses its buffer, so the document must be copied before it is batched
+		doc := make(bson.Raw, len(cur.Current))
+		copy(doc, cur.Current)
+		if err := writer.add(ctx, doc); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("cursor error: %s", err)
+	}
+	return writer.flush(ctx)
+}
+
+func cloneDB(ctx context.Context, db *mongo.Client, sourceDBName, targetDBName string, opts cloneOptions) error {
 
 	srcDB := db.Database(sourceDBName)
 	dstDB := db.Database(targetDBName)
 
 	// List all collections in the source database
-	collections, err := srcDB.ListCollectionNames(context.TODO(), bson.D{})
+	specs, err := listCollectionSpecs(ctx, srcDB)
 	if err != nil {
-		return fmt.Errorf("failed to list collection names: %w", err)
+		return err
 	}
 
-	if len(collections) == 0 {
-		return errors.New("cannot clone: source database has no collections")
+	collections := make([]collectionSpec, 0, len(specs))
+	views := make([]collectionSpec, 0)
+	for _, spec := range specs {
+		if spec.isView() {
+			views = append(views, spec)
+		} else {
+			collections = append(collections, spec)
+		}
 	}
 
-	// Iterate over each collection in the source database
-	for _, collection := range collections {
-		srcColl := srcDB.Collection(collection)
-		dstColl := dstDB.Collection(collection)
+	// create the collections up front so that their options (capped, validator, timeseries...) apply
+	for _, spec := range collections {
+		if err := createCollection(ctx, dstDB, spec); err != nil {
+			return err
+		}
+	}
+
+	progressList := make([]CloneProgress, len(collections))
+	for idx, spec := range collections {
+		progressList[idx] = CloneProgress{
+			Collection:      spec.Name,
+			CollectionIndex: idx + 1,
+			NumCollections:  len(collections),
+		}
+	}
+
+	// copy several collections at once
+	err = utils.RunConcurrently(progressList, opts.workers, func(progress CloneProgress) error {
+		spec := collections[progress.CollectionIndex-1]
+		if err := cloneCollection(ctx, srcDB.Collection(spec.Name), dstDB.Collection(spec.Name), opts, progress); err != nil {
+			return fmt.Errorf("failed to clone collection %s: %w", spec.Name, err)
+		}
+		// building the indexes once the data is in place is faster than maintaining them on every insert
+		return createIndexes(ctx, dstDB, spec)
+	})
+	if err != nil {
+		return err
+	}
+
+	// views only hold a pipeline, so they are created last
+	for _, spec := range views {
+		if err := createCollection(ctx, dstDB, spec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportDB streams every collection of `dbName` into `archive`
+func exportDB(ctx context.Context, db *mongo.Client, dbName string, archive definitions.ISnapshotArchiveWriter) error {
+	srcDB := db.Database(dbName)
+
+	specs, err := listCollectionSpecs(ctx, srcDB)
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		metadata, err := encodeCollectionSpec(spec)
+		if err != nil {
+			return fmt.Errorf("failed to encode metadata of collection %s: %w", spec.Name, err)
+		}
+		metadataEntry, err := archive.CreateEntry(CollectionsArchiveDir + spec.Name + MetadataArchiveSuffix)
+		if err != nil {
+			return err
+		}
+		if _, err := metadataEntry.Write(metadata); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+
+		if spec.isView() {
+			continue
+		}
+
+		entry, err := archive.CreateEntry(CollectionsArchiveDir + spec.Name + ".bson")
+		if err != nil {
+			return err
+		}
 
-		// Find all documents in the source collection
-		cur, err := srcColl.Find(context.TODO(), bson.D{})
+		cur, err := srcDB.Collection(spec.Name).Find(ctx, bson.D{})
 		if err != nil {
 			return fmt.Errorf("failed to find documents: %w", err)
 		}
 
-		// Insert each document into the destination collection
-		var docs []interface{}
-		for cur.Next(context.TODO()) {
-			var elem bson.D
-			err := cur.Decode(&elem)
-			if err != nil {
-				return fmt.Errorf("failed to decode document: %w", err)
+		for cur.Next(ctx) {
+			if _, err := entry.Write(cur.Current); err != nil {
+				_ = cur.Close(context.Background())
+				return fmt.Errorf("failed to write document: %w", err)
 			}
-			docs = append(docs, elem)
 		}
 
 		if err := cur.Err(); err != nil {
+			_ = cur.Close(context.Background())
 			return fmt.Errorf("cursor error: %s", err)
 		}
 
-		cur.Close(context.TODO())
+		_ = cur.Close(context.Background())
+	}
 
-		// Perform the insert to the destination collection
-		if len(docs) > 0 {
-			_, err = dstColl.InsertMany(context.TODO(), docs)
-			if err != nil {
-				return fmt.Errorf("failed to insert many: %w", err)
-			}
+	return nil
+}
+
+func importCollection(ctx context.Context, collection *mongo.Collection, r io.Reader, batchSize int) error {
+	reader := bufio.NewReader(r)
+	writer := newBatchWriter(collection, batchSize, nil)
+	for {
+		doc, err := bson.ReadDocument(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read document: %w", err)
+		}
+		if err := writer.add(ctx, doc); err != nil {
+			return err
 		}
 	}
+	return writer.flush(ctx)
+}
 
-	return nil
+func readCollectionSpec(archive definitions.ISnapshotArchiveReader, entryName string) (collectionSpec, error) {
+	entry, err := archive.OpenEntry(entryName)
+	if err != nil {
+		return collectionSpec{}, err
+	}
+	defer entry.Close()
+	data, err := io.ReadAll(entry)
+	if err != nil {
+		return collectionSpec{}, err
+	}
+	spec, err := decodeCollectionSpec(data)
+	if err != nil {
+		return collectionSpec{}, fmt.Errorf("invalid metadata in %s: %w", entryName, err)
+	}
+	return spec, nil
+}
+
+// importDB creates `targetDBName` from the collections stored in `archive`
+func importDB(ctx context.Context, db *mongo.Client, targetDBName string, archive definitions.ISnapshotArchiveReader, opts cloneOptions) error {
+	dstDB := db.Database(targetDBName)
+
+	// archives exported before the metadata was recorded only contain documents
+	specs := make(map[string]collectionSpec)
+	views := make([]collectionSpec, 0)
+	for _, entryName := range archive.EntryNames() {
+		if !strings.HasPrefix(entryName, CollectionsArchiveDir) || !strings.HasSuffix(entryName, MetadataArchiveSuffix) {
+			continue
+		}
+		spec, err := readCollectionSpec(archive, entryName)
+		if err != nil {
+			return err
+		}
+		if spec.isView() {
+			views = append(views, spec)
+			continue
+		}
+		if err := createCollection(ctx, dstDB, spec); err != nil {
+			return err
+		}
+		specs[spec.Name] = spec
+	}
+
+	for _, entryName := range archive.EntryNames() {
+		if !strings.HasPrefix(entryName, CollectionsArchiveDir) || !strings.HasSuffix(entryName, ".bson") {
+			continue
+		}
+		collection := strings.TrimSuffix(strings.TrimPrefix(entryName, CollectionsArchiveDir), ".bson")
+		entry, err := archive.OpenEntry(entryName)
+		if err != nil {
+			return err
+		}
+		err = importCollection(ctx, dstDB.Collection(collection), entry, opts.batchSize)
+		_ = entry.Close()
+		if err != nil {
+			return fmt.Errorf("failed to import collection %s: %w", collection, err)
+		}
+	}
+
+	for _, spec := range specs {
+		if err := createIndexes(ctx, dstDB, spec); err != nil {
+			return err
+		}
+	}
+	for _, spec := range views {
+		if err := createCollection(ctx, dstDB, spec); err != nil {
+			return err
+		}
+	}
+	return writeSnapshotMarker(ctx, db, targetDBName)
 }
@@ -3,10 +3,13 @@ package mongo_db_operator
 import (
 	"context"
 	"errors"
+	"ghostal/pkg/definitions"
 	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"sync"
 	"testing"
 )
 
@@ -17,7 +20,7 @@ func TestIntegration_MongoBackup(t *testing.T) {
 	parsedURL, err := ParseMongoURL(dbURL)
 	assert.NoError(t, err)
 
-	mongoClient, cleanupConnection, err := createMongoConnection(parsedURL, true)
+	mongoClient, cleanupConnection, err := createMongoConnection(context.Background(), parsedURL, true)
 	assert.NoError(t, err)
 	defer cleanupConnection()
 
@@ -26,8 +29,8 @@ func TestIntegration_MongoBackup(t *testing.T) {
 
 	// attempt destructive operation with backup
 	didAttemptDrop := false
-	err = backupDB(mongoClient, parsedURL.DBName(), func() error {
-		if err := dropDB(mongoClient, parsedURL.DBName()); err != nil {
+	err = backupDB(context.Background(), mongoClient, parsedURL.DBName(), cloneOptions{workers: DefaultWorkers, batchSize: DefaultBatchSize}, func() error {
+		if err := dropDB(context.Background(), mongoClient, parsedURL.DBName()); err != nil {
 			panic(err)
 		}
 		didAttemptDrop = true
@@ -50,3 +53,147 @@ func TestIntegration_MongoBackup(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 5, numItems)
 }
+
+func TestIntegration_MongoCloneDB(t *testing.T) {
+	dbURL, cleanupContainer := createMongoContainer("gho_db", "gho_user", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseMongoURL(dbURL)
+	assert.NoError(t, err)
+
+	mongoClient, cleanupConnection, err := createMongoConnection(context.Background(), parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	WriteMongoDBSeedData(dbURL, "vehicles")
+	numEvents := 2500
+	events := make([]interface{}, numEvents)
+	for idx := range events {
+		events[idx] = bson.D{{Key: "seq", Value: idx}}
+	}
+	_, err = mongoClient.Database(parsedURL.DBName()).Collection("events").InsertMany(context.Background(), events)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	copied := make(map[string]int64)
+	opts := cloneOptions{
+		workers:   2,
+		batchSize: 100,
+		onProgress: func(progress CloneProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, 2, progress.NumCollections)
+			assert.GreaterOrEqual(t, progress.CopiedDocuments, copied[progress.Collection], "progress should not go backwards")
+			copied[progress.Collection] = progress.CopiedDocuments
+		},
+	}
+	assert.NoError(t, cloneDB(context.Background(), mongoClient, parsedURL.DBName(), "gho_db_copy", opts))
+
+	assert.EqualValues(t, 5, copied["vehicles"])
+	assert.EqualValues(t, numEvents, copied["events"])
+	numItems, err := mongoClient.Database("gho_db_copy").Collection("events").CountDocuments(context.Background(), bson.D{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, numEvents, numItems)
+}
+
+func TestIntegration_MongoRecovery(t *testing.T) {
+	dbURL, cleanupContainer := createMongoContainer("gho_db", "gho_user", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseMongoURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	dbName := parsedURL.DBName()
+	backupDBName := backupDBPrefix + dbName
+	opts := cloneOptions{workers: DefaultWorkers, batchSize: DefaultBatchSize}
+
+	mongoClient, cleanupConnection, err := createMongoConnection(ctx, parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	WriteMongoDBSeedData(dbURL, "vehicles")
+	countDocuments := func(collectionName string) int64 {
+		count, err := mongoClient.Database(dbName).Collection(collectionName).CountDocuments(ctx, bson.D{})
+		assert.NoError(t, err)
+		return count
+	}
+
+	// nothing was interrupted
+	backup, err := findEmergencyBackup(ctx, mongoClient, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+	assert.ErrorIs(t, rollForwardEmergencyBackup(ctx, mongoClient, dbName), values.NothingToRecoverErr)
+
+	// killed while backing up the database, before the marker was written
+	assert.NoError(t, cloneDB(ctx, mongoClient, dbName, backupDBName, opts))
+	backup, err = findEmergencyBackup(ctx, mongoClient, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupDBName, Complete: false, DBExists: true}, backup)
+	assert.Error(t, rollBackEmergencyBackup(ctx, mongoClient, dbName, opts), "a partial backup can't replace the database")
+	assert.NoError(t, rollForwardEmergencyBackup(ctx, mongoClient, dbName))
+	assert.EqualValues(t, 5, countDocuments("vehicles"))
+
+	// killed after dropping the database
+	assert.NoError(t, cloneDB(ctx, mongoClient, dbName, backupDBName, opts))
+	assert.NoError(t, writeSnapshotMarker(ctx, mongoClient, backupDBName))
+	assert.NoError(t, dropDB(ctx, mongoClient, dbName))
+	backup, err = findEmergencyBackup(ctx, mongoClient, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupDBName, Complete: true, DBExists: false}, backup)
+	assert.Error(t, rollForwardEmergencyBackup(ctx, mongoClient, dbName), "the backup is all that is left")
+	assert.NoError(t, rollBackEmergencyBackup(ctx, mongoClient, dbName, opts))
+	assert.EqualValues(t, 5, countDocuments("vehicles"))
+
+	// killed while copying the snapshot
+	assert.NoError(t, cloneDB(ctx, mongoClient, dbName, backupDBName, opts))
+	assert.NoError(t, writeSnapshotMarker(ctx, mongoClient, backupDBName))
+	assert.NoError(t, mongoClient.Database(dbName).Collection("vehicles").Drop(ctx))
+	WriteMongoDBSeedData(dbURL, "planets")
+	backup, err = findEmergencyBackup(ctx, mongoClient, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupDBName, Complete: true, DBExists: true}, backup)
+	assert.NoError(t, rollBackEmergencyBackup(ctx, mongoClient, dbName, opts))
+	assert.EqualValues(t, 5, countDocuments("vehicles"))
+	assert.EqualValues(t, 0, countDocuments("planets"))
+
+	// killed before dropping the backup
+	assert.NoError(t, cloneDB(ctx, mongoClient, dbName, backupDBName, opts))
+	assert.NoError(t, writeSnapshotMarker(ctx, mongoClient, backupDBName))
+	WriteMongoDBSeedData(dbURL, "planets")
+	assert.NoError(t, rollForwardEmergencyBackup(ctx, mongoClient, dbName))
+	assert.EqualValues(t, 5, countDocuments("planets"))
+	backup, err = findEmergencyBackup(ctx, mongoClient, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+}
+
+func TestIntegration_MongoListSnapshots_UnreadableName(t *testing.T) {
+	dbURL, cleanupContainer := createMongoContainer("gho_db", "gho_user", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseMongoURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	dbName := parsedURL.DBName()
+
+	mongoClient, cleanupConnection, err := createMongoConnection(ctx, parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	WriteMongoDBSeedData(dbURL, "vehicles")
+	assert.NoError(t, snapshotDB(ctx, mongoClient, dbName, "v1", cloneOptions{workers: DefaultWorkers, batchSize: DefaultBatchSize}))
+
+	// e.g. a snapshot database copied by hand
+	unreadableDBName := values.SnapshotDBPrefix + "copy"
+	_, err = mongoClient.Database(unreadableDBName).Collection("vehicles").InsertOne(ctx, bson.D{{Key: "make", Value: "Kia"}})
+	assert.NoError(t, err)
+
+	skipped := make([]string, 0)
+	list, err := listSnapshots(ctx, mongoClient, dbName, func(dbName string, err error) {
+		skipped = append(skipped, dbName)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "v1", list[0].SnapshotName)
+	assert.Equal(t, []string{unreadableDBName}, skipped)
+}
@@ -0,0 +1,146 @@
+package mongo_db_operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+// lockDBName holds one lock document per locked database, it is kept apart since restores drop the locked database
+const lockDBName = "ghostal_locks"
+const lockCollection = "locks"
+
+// lockLease is how long a lock outlives a gho process that was killed without unlocking
+const lockLease = 30 * time.Second
+const lockRefreshInterval = lockLease / 3
+
+type lockDocument struct {
+	DBName string `bson:"_id"`
+	// Owner tells the locks of the same process apart
+	Owner string    `bson:"owner"`
+	PID   int       `bson:"pid"`
+	Host  string    `bson:"host"`
+	Since time.Time `bson:"since"`
+	// ExpiresAt is set from the clock of the server, the clocks of the gho processes may disagree
+	ExpiresAt time.Time `bson:"expiresAt,omitempty"`
+}
+
+func (d lockDocument) info() definitions.LockInfo {
+	return definitions.LockInfo{PID: d.PID, Host: d.Host, Since: d.Since}
+}
+
+// renewLease is the update pipeline that extends the lease from the current time of the server
+var renewLease = mongo.Pipeline{
+	{{Key: "$set", Value: bson.D{{Key: "expiresAt", Value: bson.D{{Key: "$add", Value: bson.A{"$$NOW", lockLease.Milliseconds()}}}}}}},
+}
+
+// tryLockDB inserts the lock document of `dbName`, or returns the one of the process holding it
+func tryLockDB(ctx context.Context, locks *mongo.Collection, doc lockDocument) (*lockDocument, error) {
+	// a lock whose process was killed is taken over once its lease has expired,
+	// otherwise the filter matches nothing and the upsert fails on the taken _id
+	filter := bson.D{
+		{Key: "_id", Value: doc.DBName},
+		{Key: "$expr", Value: bson.D{{Key: "$lt", Value: bson.A{"$expiresAt", "$$NOW"}}}},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "owner", Value: doc.Owner},
+			{Key: "pid", Value: doc.PID},
+			{Key: "host", Value: doc.Host},
+			{Key: "since", Value: doc.Since},
+		}}},
+	}
+	update = append(update, renewLease...)
+	_, err := locks.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("failed to lock database: %w", err)
+	}
+	var holder lockDocument
+	if err := locks.FindOne(ctx, bson.D{{Key: "_id", Value: doc.DBName}}).Decode(&holder); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to look up the lock holder: %w", err)
+	}
+	return &holder, nil
+}
+
+// refreshLock renews the lease of the lock, it fails if the lock is no longer held by `owner`
+func refreshLock(ctx context.Context, locks *mongo.Collection, ownFilter bson.D) error {
+	ctx, cancel := context.WithTimeout(ctx, lockRefreshInterval)
+	defer cancel()
+	result, err := locks.UpdateOne(ctx, ownFilter, renewLease)
+	if err != nil {
+		return fmt.Errorf("failed to renew the lock: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("the lock expired and was taken over")
+	}
+	return nil
+}
+
+// lockDB writes a lock document for `dbName`, whose lease is renewed until unlocked.
+// The returned context is cancelled once the lease can't be renewed, the lock may belong to another process by then.
+func lockDB(ctx context.Context, db *mongo.Client, dbName string, wait bool) (context.Context, func(), error) {
+	locks := db.Database(lockDBName).Collection(lockCollection)
+	self := definitions.NewLockInfo()
+	doc := lockDocument{
+		DBName: dbName,
+		Owner:  fmt.Sprintf("%s:%d:%d", self.Host, self.PID, self.Since.UnixNano()),
+		PID:    self.PID,
+		Host:   self.Host,
+		Since:  self.Since,
+	}
+	err := utils.Poll(ctx, values.LockPollInterval, func() (bool, error) {
+		holder, err := tryLockDB(ctx, locks, doc)
+		if err != nil {
+			return false, err
+		}
+		if holder == nil {
+			return true, nil
+		}
+		if !wait {
+			return false, holder.info().LockedErr(fmt.Sprintf("database \"%s\"", dbName))
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lockedCtx, cancel := context.WithCancelCause(ctx)
+	ownFilter := bson.D{{Key: "_id", Value: dbName}, {Key: "owner", Value: doc.Owner}}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := refreshLock(context.Background(), locks, ownFilter); err != nil {
+					cancel(fmt.Errorf("lost the lock of database \"%s\": %w", dbName, err))
+					return
+				}
+			}
+		}
+	}()
+	return lockedCtx, func() {
+		close(stop)
+		wg.Wait()
+		cancel(nil)
+		_, _ = locks.DeleteOne(context.Background(), ownFilter)
+	}, nil
+}
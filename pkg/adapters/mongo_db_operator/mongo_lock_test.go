@@ -0,0 +1,70 @@
+package mongo_db_operator
+
+import (
+	"context"
+	"fmt"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIntegration_MongoLock(t *testing.T) {
+	dbURL, cleanupContainer := createMongoContainer("gho_db", "gho_user", "gho_pass")
+	defer cleanupContainer()
+
+	operator, err := CreateMongoDBOperator(dbURL)
+	assert.NoError(t, err)
+	other, err := CreateMongoDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	_, unlock, err := operator.Lock(context.Background(), false)
+	assert.NoError(t, err)
+	_, _, err = other.Lock(context.Background(), false)
+	assert.ErrorIs(t, err, values.LockedErr)
+	assert.Contains(t, err.Error(), fmt.Sprintf("database \"gho_db\" is locked by pid %d", os.Getpid()))
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		unlock()
+	}()
+	_, unlockOther, err := other.Lock(context.Background(), true)
+	assert.NoError(t, err)
+	unlockOther()
+
+	// the lock of a killed process is taken over once expired
+	mongoClient, cleanupConnection, err := createMongoConnection(context.Background(), operator.mongoURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+	_, err = mongoClient.Database(lockDBName).Collection(lockCollection).InsertOne(context.Background(), lockDocument{
+		DBName:    "gho_db",
+		Owner:     "killed",
+		PID:       1,
+		Since:     time.Now().Add(-time.Hour),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	assert.NoError(t, err)
+	lockedCtx, unlock, err := operator.Lock(context.Background(), false)
+	assert.NoError(t, err)
+	defer unlock()
+	var doc lockDocument
+	locks := mongoClient.Database(lockDBName).Collection(lockCollection)
+	assert.NoError(t, locks.FindOne(context.Background(), bson.D{{Key: "_id", Value: "gho_db"}}).Decode(&doc))
+	assert.WithinDuration(t, time.Now().Add(lockLease), doc.ExpiresAt, 5*time.Second, "the lease starts from the time of the server")
+	assert.NoError(t, lockedCtx.Err())
+
+	// the lease can't be renewed once the lock is taken over
+	ownFilter := bson.D{{Key: "_id", Value: "gho_db"}, {Key: "owner", Value: doc.Owner}}
+	assert.NoError(t, refreshLock(context.Background(), locks, ownFilter))
+	_, err = locks.UpdateOne(context.Background(), ownFilter, bson.D{{Key: "$set", Value: bson.D{{Key: "owner", Value: "other"}}}})
+	assert.NoError(t, err)
+	assert.Error(t, refreshLock(context.Background(), locks, ownFilter))
+	select {
+	case <-lockedCtx.Done():
+		assert.Contains(t, context.Cause(lockedCtx).Error(), "lost the lock of database \"gho_db\"")
+	case <-time.After(2 * lockRefreshInterval):
+		assert.Fail(t, "the work done under a lost lock should be cancelled")
+	}
+}
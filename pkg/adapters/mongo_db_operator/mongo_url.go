@@ -1,6 +1,18 @@
 package mongo_db_operator
 
-import "net/url"
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// WorkersParam sets how many collections are copied concurrently
+const WorkersParam = "ghostalWorkers"
+const DefaultWorkers = 4
+
+// BatchSizeParam sets the maximum number of documents written per insert
+const BatchSizeParam = "ghostalBatchSize"
+const DefaultBatchSize = 1000
 
 type MongoURL struct {
 	dbURL *url.URL
@@ -21,6 +33,39 @@ func (p *MongoURL) DBName() string {
 	return p.dbURL.Path[1:]
 }
 
+func (p *MongoURL) positiveIntParam(param string, defaultValue int) (int, error) {
+	value := p.dbURL.Query().Get(param)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s \"%s\"", param, value)
+	}
+	return n, nil
+}
+
+func (p *MongoURL) Workers() (int, error) {
+	return p.positiveIntParam(WorkersParam, DefaultWorkers)
+}
+
+func (p *MongoURL) BatchSize() (int, error) {
+	return p.positiveIntParam(BatchSizeParam, DefaultBatchSize)
+}
+
+// ConnectionString returns the URL without the ghostal specific parameters, which the driver doesn't understand
+func (p *MongoURL) ConnectionString(useDefault bool) string {
+	clone := p.Clone()
+	query := clone.Query()
+	query.Del(WorkersParam)
+	query.Del(BatchSizeParam)
+	clone.RawQuery = query.Encode()
+	if useDefault {
+		clone.Path = "admin"
+	}
+	return clone.String()
+}
+
 func ParseMongoURL(dbURL string) (*MongoURL, error) {
 	u, err := url.Parse(dbURL)
 	if err != nil {
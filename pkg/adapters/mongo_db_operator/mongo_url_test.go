@@ -0,0 +1,46 @@
+package mongo_db_operator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_ParseMongoURL(t *testing.T) {
+	mongoURL, err := ParseMongoURL("mongodb://admin:pw@localhost/main?authSource=admin")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "admin", mongoURL.Username())
+	assert.Equal(t, "main", mongoURL.DBName())
+
+	workers, err := mongoURL.Workers()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultWorkers, workers)
+	batchSize, err := mongoURL.BatchSize()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBatchSize, batchSize)
+}
+
+func TestUnit_ParseMongoURL_CopyParams(t *testing.T) {
+	mongoURL, err := ParseMongoURL("mongodb://admin:pw@localhost/main?authSource=admin&ghostalWorkers=8&ghostalBatchSize=200")
+	assert.NoError(t, err)
+
+	workers, err := mongoURL.Workers()
+	assert.NoError(t, err)
+	assert.Equal(t, 8, workers)
+	batchSize, err := mongoURL.BatchSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 200, batchSize)
+
+	assert.Equal(t, "mongodb://admin:pw@localhost/main?authSource=admin", mongoURL.ConnectionString(false))
+	assert.Equal(t, "mongodb://admin:pw@localhost/admin?authSource=admin", mongoURL.ConnectionString(true))
+}
+
+func TestUnit_ParseMongoURL_InvalidCopyParams(t *testing.T) {
+	for _, query := range []string{"ghostalWorkers=0", "ghostalWorkers=many", "ghostalBatchSize=-1"} {
+		mongoURL, err := ParseMongoURL("mongodb://admin:pw@localhost/main?" + query)
+		assert.NoError(t, err)
+		_, workersErr := mongoURL.Workers()
+		_, batchSizeErr := mongoURL.BatchSize()
+		assert.True(t, workersErr != nil || batchSizeErr != nil, query)
+	}
+}
@@ -0,0 +1,167 @@
+package mysql_db_operator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type MySQLDBOperator struct {
+	mysqlURL *MySQLURL
+	logger   definitions.ILogger
+}
+
+func CreateMySQLDBOperator(dbURL string) (*MySQLDBOperator, error) {
+	mysqlURL, err := ParseMySQLURL(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	return &MySQLDBOperator{
+		mysqlURL: mysqlURL,
+	}, nil
+}
+
+func (m *MySQLDBOperator) SetLogger(logger definitions.ILogger) {
+	m.logger = logger
+}
+
+func (m *MySQLDBOperator) warnSkippedDB(dbName string, err error) {
+	if m.logger != nil {
+		m.logger.Warning("skipped database \"%s\", it looks like a snapshot but can't be read: %s", dbName, err)
+	}
+}
+
+func (m *MySQLDBOperator) connect(ctx context.Context, useDefault bool) (*sql.DB, func(), error) {
+	return createMySQLConnection(ctx, m.mysqlURL, useDefault)
+}
+
+func (m *MySQLDBOperator) checkSnapshotName(ctx context.Context, snapshotName string) error {
+	list, err := m.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = utils.Find(list, func(item definitions.SnapshotListResult) bool {
+		return item.SnapshotName == snapshotName
+	})
+	if err == nil {
+		// item found
+		return values.SnapshotNameTakenErr
+	}
+	return nil
+}
+
+func (m *MySQLDBOperator) Snapshot(ctx context.Context, snapshotName string) error {
+	if err := m.checkSnapshotName(ctx, snapshotName); err != nil {
+		return err
+	}
+	db, close, err := m.connect(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+	return snapshotDB(ctx, db, m.mysqlURL.DBName(), snapshotName)
+}
+
+// ReplaceSnapshot takes the new snapshot before dropping the previous one, so that a failure never loses both
+func (m *MySQLDBOperator) ReplaceSnapshot(ctx context.Context, snapshotName string) error {
+	db, close, err := m.connect(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+	list, err := listSnapshots(ctx, db, m.mysqlURL.DBName(), m.warnSkippedDB)
+	if err != nil {
+		return err
+	}
+	if err := snapshotDB(ctx, db, m.mysqlURL.DBName(), snapshotName); err != nil {
+		return err
+	}
+	for _, item := range list.Named(snapshotName) {
+		if err := dropDB(ctx, db, item.DBName); err != nil {
+			return fmt.Errorf("failed to drop the previous snapshot \"%s\": %w", snapshotName, err)
+		}
+	}
+	return nil
+}
+
+func (m *MySQLDBOperator) Restore(ctx context.Context, snapshotName string, fast bool) error {
+	db, close, err := m.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	list, err := listSnapshots(ctx, db, m.mysqlURL.DBName(), m.warnSkippedDB)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			return restoreDB(ctx, db, m.mysqlURL.DBName(), item.DBName, fast)
+		}
+	}
+	return values.SnapshotNotExistsErr
+}
+
+func (m *MySQLDBOperator) Delete(ctx context.Context, snapshotName string) error {
+	db, close, err := m.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	list, err := listSnapshots(ctx, db, m.mysqlURL.DBName(), m.warnSkippedDB)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			return dropDB(ctx, db, item.DBName)
+		}
+	}
+	return values.SnapshotNotExistsErr
+}
+
+func (m *MySQLDBOperator) FindEmergencyBackup(ctx context.Context) (*definitions.EmergencyBackup, error) {
+	db, close, err := m.connect(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer close()
+
+	return findEmergencyBackup(ctx, db, m.mysqlURL.DBName())
+}
+
+func (m *MySQLDBOperator) RollBack(ctx context.Context) error {
+	db, close, err := m.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	return rollBackEmergencyBackup(ctx, db, m.mysqlURL.DBName())
+}
+
+func (m *MySQLDBOperator) RollForward(ctx context.Context) error {
+	db, close, err := m.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	return rollForwardEmergencyBackup(ctx, db, m.mysqlURL.DBName())
+}
+
+func (m *MySQLDBOperator) ListSnapshots(ctx context.Context) (definitions.SnapshotList, error) {
+	db, close, err := m.connect(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer close()
+
+	return listSnapshots(ctx, db, m.mysqlURL.DBName(), m.warnSkippedDB)
+}
@@ -0,0 +1,15 @@
+package mysql_db_operator
+
+import (
+	"ghostal/pkg/definitions"
+)
+
+type MySQLDBOperatorBuilder struct{}
+
+func (p *MySQLDBOperatorBuilder) ID() string {
+	return "MySQL"
+}
+
+func (p *MySQLDBOperatorBuilder) BuildOperator(dbURL string) (definitions.IDBOperator, error) {
+	return CreateMySQLDBOperator(dbURL)
+}
@@ -0,0 +1,141 @@
+package mysql_db_operator
+
+import (
+	"context"
+	"fmt"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"testing"
+)
+
+const DBPassword = "gho_pass"
+const DBName = "gho_db"
+const DBPort = "3306"
+
+func createMySQLContainer(dbName, dbUser, dbPassword string) (string, func()) {
+	ctx := context.Background()
+	req := testcontainers.ContainerRequest{
+		Image:        "mysql:8.0.36",
+		ExposedPorts: []string{DBPort + "/tcp"},
+		Env: map[string]string{
+			"MYSQL_DATABASE":      dbName,
+			"MYSQL_ROOT_PASSWORD": dbPassword,
+		},
+		WaitingFor: wait.ForListeningPort(DBPort + "/tcp"),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		panic(fmt.Errorf("failed to start container: %s", err))
+	}
+
+	host, err := container.Host(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	mappedPort, err := container.MappedPort(context.Background(), DBPort)
+	if err != nil {
+		panic(err)
+	}
+
+	dbURL := fmt.Sprintf("mysql://%s:%s@%s:%s/%s", dbUser, dbPassword, host, mappedPort.Port(), dbName)
+	return dbURL, func() {
+		_ = container.Terminate(ctx)
+	}
+}
+
+func getNumVehicles(dbURL string) int {
+	results := MySQLRunQuery(dbURL, `
+		SELECT * FROM vehicles
+	`)
+	return len(results)
+}
+
+func TestIntegration_MySQLDBOperator_Lifecycle(t *testing.T) {
+	dbURL, cleanup := createMySQLContainer(DBName, "root", DBPassword)
+	defer cleanup()
+
+	operator, err := CreateMySQLDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WriteMySQLSeedData(dbURL, "vehicles")
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	{
+		assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 1)
+	}
+
+	{
+		// every escaped special char takes 3 characters of the database name
+		err := operator.Snapshot(context.Background(), "before-migration_42.1-of-the-users-table")
+		assert.ErrorIs(t, err, values.SnapshotNameTooLongErr)
+	}
+
+	{
+		assert.NoError(t, operator.Snapshot(context.Background(), "v2"))
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 2)
+	}
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	{
+		// modify DB before restoring snapshot
+		MySQLRunQuery(dbURL, `
+			DELETE FROM vehicles WHERE year < 2022
+		`)
+	}
+
+	assert.Equal(t, 2, getNumVehicles(dbURL))
+
+	{
+		err := operator.Restore(context.Background(), "v1", false)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	{
+		// modify DB before restoring snapshot (fast)
+		MySQLRunQuery(dbURL, `
+			DELETE FROM vehicles WHERE year < 2023
+		`)
+	}
+
+	assert.Equal(t, 1, getNumVehicles(dbURL))
+
+	{
+		err := operator.Restore(context.Background(), "v2", true)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	{
+		err := operator.Delete(context.Background(), "v2")
+		assert.NoError(t, err)
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 1)
+		assert.Equal(t, "v1", allDatabases[0].SnapshotName)
+	}
+}
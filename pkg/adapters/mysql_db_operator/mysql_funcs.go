@@ -0,0 +1,514 @@
+package mysql_db_operator
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"strings"
+	"time"
+)
+
+// maxDBNameLength is the longest database name MySQL accepts
+const maxDBNameLength = 64
+
+const backupDBPrefix = "temp_emergency_backup_"
+
+// backupMarkerTable is created in the emergency backup once it is fully copied, it is never copied itself
+const backupMarkerTable = "__ghostal_backup_complete__"
+
+func createMySQLConnection(ctx context.Context, mysqlURL *MySQLURL, useDefault bool) (*sql.DB, func(), error) {
+	dsn, err := mysqlURL.DSN(useDefault)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open connection: %w", err)
+	}
+	// Attempt to ping the database to ensure connection is alive
+	err = db.PingContext(ctx)
+	if err != nil {
+		_ = db.Close() // Ensure the connection is closed if not usable
+		sanitizedDBURL, _ := utils.SanitizeDBURL(mysqlURL.dbURL.String())
+		return nil, nil, fmt.Errorf("failed to connect to database (%s): %w", sanitizedDBURL, err)
+	}
+	return db, func() {
+		_ = db.Close()
+	}, nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func terminateConnections(ctx context.Context, db *sql.DB, targetDB string) error {
+	query := "SELECT ID FROM information_schema.PROCESSLIST WHERE DB = ? AND ID <> CONNECTION_ID()"
+	rows, err := db.QueryContext(ctx, query, targetDB)
+	if err != nil {
+		return fmt.Errorf("error listing connections to database: %w", err)
+	}
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	for _, id := range ids {
+		// the connection may have already closed on its own, so errors are ignored
+		_, _ = db.ExecContext(ctx, fmt.Sprintf("KILL %d", id))
+	}
+	return nil
+}
+
+func createDB(ctx context.Context, db *sql.DB, targetDB, templateDB string) error {
+	query := fmt.Sprintf("CREATE DATABASE %s", quoteIdentifier(targetDB))
+	if templateDB != "" {
+		// carry over the character set and collation of the template
+		var charset, collation string
+		row := db.QueryRowContext(ctx, "SELECT DEFAULT_CHARACTER_SET_NAME, DEFAULT_COLLATION_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?", templateDB)
+		if err := row.Scan(&charset, &collation); err != nil {
+			return fmt.Errorf("failed to read database %s charset: %w", templateDB, err)
+		}
+		query += fmt.Sprintf(" CHARACTER SET %s COLLATE %s", charset, collation)
+	}
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", targetDB, err)
+	}
+	return nil
+}
+
+func dropDB(ctx context.Context, db *sql.DB, targetDB string) error {
+	if err := terminateConnections(ctx, db, targetDB); err != nil {
+		return fmt.Errorf("failed to terminate connection: %w", err)
+	}
+	query := fmt.Sprintf("DROP DATABASE IF EXISTS %s", quoteIdentifier(targetDB))
+	_, err := db.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// listNames runs `query`, which selects a single string column
+func listNames(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		names = append(names, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	return names, nil
+}
+
+func listTables(ctx context.Context, db *sql.DB, dbName string) ([]string, error) {
+	query := "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE' AND TABLE_NAME <> ?"
+	return listNames(ctx, db, query, dbName, backupMarkerTable)
+}
+
+// listInsertableColumns lists the columns of `table` that values can be inserted into, generated columns are computed
+func listInsertableColumns(ctx context.Context, db *sql.DB, dbName, table string) ([]string, error) {
+	query := `
+		SELECT COLUMN_NAME FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COALESCE(GENERATION_EXPRESSION, '') = ''
+		ORDER BY ORDINAL_POSITION
+	`
+	return listNames(ctx, db, query, dbName, table)
+}
+
+// cloneDB creates `targetDB` and copies the structure and rows of every table in `sourceDB` into it,
+// then its routines, views and triggers
+func cloneDB(ctx context.Context, db *sql.DB, sourceDB, targetDB string) error {
+	tables, err := listTables(ctx, db, sourceDB)
+	if err != nil {
+		return err
+	}
+	if err := createDB(ctx, db, targetDB, sourceDB); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		source := quoteIdentifier(sourceDB) + "." + quoteIdentifier(table)
+		target := quoteIdentifier(targetDB) + "." + quoteIdentifier(table)
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s LIKE %s", target, source)); err != nil {
+			return fmt.Errorf("failed to create table %s: %w", target, err)
+		}
+		columns, err := listInsertableColumns(ctx, db, sourceDB, table)
+		if err != nil {
+			return err
+		}
+		quotedColumns := make([]string, len(columns))
+		for idx, column := range columns {
+			quotedColumns[idx] = quoteIdentifier(column)
+		}
+		columnList := strings.Join(quotedColumns, ", ")
+		query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", target, columnList, columnList, source)
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to copy rows into %s: %w", target, err)
+		}
+	}
+	// the triggers are created once the rows are copied, so that they don't fire on them
+	return cloneSchemaObjects(ctx, db, sourceDB, targetDB)
+}
+
+// schemaObject is a routine, view or trigger, along with the statement that creates it
+type schemaObject struct {
+	kind      string
+	name      string
+	statement string
+	sqlMode   string
+}
+
+// listSchemaObjects lists the routines, views and triggers of `dbName`, in an order they can be created in,
+// except for views using other views
+func listSchemaObjects(ctx context.Context, db *sql.DB, dbName string) ([]schemaObject, error) {
+	kindQueries := []struct {
+		kind  string
+		query string
+	}{
+		{"FUNCTION", "SELECT ROUTINE_NAME FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? AND ROUTINE_TYPE = 'FUNCTION'"},
+		{"PROCEDURE", "SELECT ROUTINE_NAME FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? AND ROUTINE_TYPE = 'PROCEDURE'"},
+		{"VIEW", "SELECT TABLE_NAME FROM information_schema.VIEWS WHERE TABLE_SCHEMA = ?"},
+		// triggers of the same table and timing fire in the order they were created
+		{"TRIGGER", "SELECT TRIGGER_NAME FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = ? ORDER BY EVENT_OBJECT_TABLE, ACTION_ORDER"},
+	}
+	objects := make([]schemaObject, 0)
+	for _, item := range kindQueries {
+		names, err := listNames(ctx, db, item.query, dbName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s objects: %w", strings.ToLower(item.kind), err)
+		}
+		for _, name := range names {
+			objects = append(objects, schemaObject{kind: item.kind, name: name})
+		}
+	}
+	return objects, nil
+}
+
+// readCreateStatement fills in the statement of `object` and the SQL mode it was created with, from SHOW CREATE.
+// The names in a view are only left unqualified by the server if `conn` uses the database of the view.
+func readCreateStatement(ctx context.Context, conn *sql.Conn, dbName string, object *schemaObject) error {
+	query := fmt.Sprintf("SHOW CREATE %s %s.%s", object.kind, quoteIdentifier(dbName), quoteIdentifier(object.name))
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to read %s %s: %w", strings.ToLower(object.kind), object.name, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to read columns: %w", err)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("rows err: %w", err)
+		}
+		return fmt.Errorf("%s %s not found", strings.ToLower(object.kind), object.name)
+	}
+	// the columns differ per kind of object
+	row := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for idx := range row {
+		dest[idx] = &row[idx]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+	for idx, column := range columns {
+		switch column {
+		case "Create View", "Create Function", "Create Procedure", "SQL Original Statement":
+			object.statement = row[idx].String
+		case "sql_mode":
+			object.sqlMode = row[idx].String
+		}
+	}
+	if object.statement == "" {
+		// routines are shown without their body to users that aren't allowed to see it
+		return fmt.Errorf("not allowed to read the definition of %s %s", strings.ToLower(object.kind), object.name)
+	}
+	return nil
+}
+
+// cloneSchemaObjects creates the routines, views and triggers of `sourceDB` in `targetDB`
+func cloneSchemaObjects(ctx context.Context, db *sql.DB, sourceDB, targetDB string) error {
+	objects, err := listSchemaObjects(ctx, db, sourceDB)
+	if err != nil || len(objects) == 0 {
+		return err
+	}
+	// the statements create the objects in the database in use, which is set on a session of its own
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer func() {
+		// the database in use and the SQL mode are changed, the session must not go back to the pool
+		_ = conn.Raw(func(any) error {
+			return driver.ErrBadConn
+		})
+		_ = conn.Close()
+	}()
+
+	var sessionSQLMode string
+	if err := conn.QueryRowContext(ctx, "SELECT @@SESSION.sql_mode").Scan(&sessionSQLMode); err != nil {
+		return fmt.Errorf("failed to read sql mode: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "USE "+quoteIdentifier(sourceDB)); err != nil {
+		return fmt.Errorf("failed to use database %s: %w", sourceDB, err)
+	}
+	for idx := range objects {
+		if err := readCreateStatement(ctx, conn, sourceDB, &objects[idx]); err != nil {
+			return err
+		}
+		if objects[idx].kind == "VIEW" {
+			// views are created with the mode of the session
+			objects[idx].sqlMode = sessionSQLMode
+		}
+	}
+	if _, err := conn.ExecContext(ctx, "USE "+quoteIdentifier(targetDB)); err != nil {
+		return fmt.Errorf("failed to use database %s: %w", targetDB, err)
+	}
+
+	pending := objects
+	for len(pending) > 0 {
+		// a view using another view can only be created after it, the failed ones are retried until none succeeds
+		failed := make([]schemaObject, 0)
+		var lastErr error
+		for _, object := range pending {
+			if err := createSchemaObject(ctx, conn, object); err != nil {
+				if object.kind != "VIEW" {
+					return err
+				}
+				failed = append(failed, object)
+				lastErr = err
+			}
+		}
+		if len(failed) == len(pending) {
+			return lastErr
+		}
+		pending = failed
+	}
+	return nil
+}
+
+func createSchemaObject(ctx context.Context, conn *sql.Conn, object schemaObject) error {
+	if _, err := conn.ExecContext(ctx, "SET SESSION sql_mode = ?", object.sqlMode); err != nil {
+		return fmt.Errorf("failed to set sql mode: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, object.statement); err != nil {
+		return fmt.Errorf("failed to create %s %s: %w", strings.ToLower(object.kind), object.name, err)
+	}
+	return nil
+}
+
+func listSnapshots(ctx context.Context, db *sql.DB, sourceDBName string, onSkip func(dbName string, err error)) (definitions.SnapshotList, error) {
+	query := `
+		SELECT s.SCHEMA_NAME, COALESCE(SUM(t.DATA_LENGTH + t.INDEX_LENGTH), 0)
+		FROM information_schema.SCHEMATA s
+		LEFT JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = s.SCHEMA_NAME
+		GROUP BY s.SCHEMA_NAME
+	`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]definitions.SnapshotListResult, 0)
+
+	for rows.Next() {
+		var dbName string
+		var sizeBytes int64
+		if err := rows.Scan(&dbName, &sizeBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if !strings.HasPrefix(dbName, values.SnapshotDBPrefix) {
+			continue
+		}
+
+		snapshotDBNameParts, err := utils.ParseSnapshotDBName(dbName)
+		if err != nil {
+			// it must not keep the snapshots of every project on the server from being listed
+			onSkip(dbName, err)
+			continue
+		}
+
+		if snapshotDBNameParts.SourceDBName != sourceDBName {
+			continue
+		}
+
+		list = append(list, definitions.SnapshotListResult{
+			SnapshotName: snapshotDBNameParts.SnapshotName,
+			DBName:       dbName,
+			CreatedAt:    snapshotDBNameParts.Timestamp,
+			SizeBytes:    sizeBytes,
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	return list, nil
+}
+
+// backupDB backs up `sourceDB` and restores it if `fn` fails, including when `ctx` is cancelled
+func backupDB(ctx context.Context, db *sql.DB, sourceDB string, fn func() error) error {
+	// MySQL cannot rename databases, and tables with triggers can't be moved to another one, so the backup is a copy
+	backupDBName := backupDBPrefix + sourceDB
+	// the cleanup and the rollback must run even if cancelled
+	rollbackCtx := context.WithoutCancel(ctx)
+	if err := cloneDB(ctx, db, sourceDB, backupDBName); err != nil {
+		_ = dropDB(rollbackCtx, db, backupDBName)
+		return fmt.Errorf("failed to copy original to backup: %w", err)
+	}
+	if err := writeBackupMarker(ctx, db, backupDBName); err != nil {
+		_ = dropDB(rollbackCtx, db, backupDBName)
+		return err
+	}
+	if err := fn(); err != nil {
+		// if error, recreate source from the backup, which is only dropped once copied
+		_ = dropDB(rollbackCtx, db, sourceDB)
+		if cloneErr := cloneDB(rollbackCtx, db, backupDBName, sourceDB); cloneErr == nil {
+			_ = dropDB(rollbackCtx, db, backupDBName)
+		}
+		return err
+	}
+	// is success, drop backup
+	_ = dropDB(ctx, db, backupDBName)
+	return nil
+}
+
+func writeBackupMarker(ctx context.Context, db *sql.DB, backupDBName string) error {
+	query := fmt.Sprintf("CREATE TABLE %s.%s (id INT)", quoteIdentifier(backupDBName), quoteIdentifier(backupMarkerTable))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to write backup marker: %w", err)
+	}
+	return nil
+}
+
+func dbExists(ctx context.Context, db *sql.DB, dbName string) (bool, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?"
+	if err := db.QueryRowContext(ctx, query, dbName).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to look up database %s: %w", dbName, err)
+	}
+	return count > 0, nil
+}
+
+// findEmergencyBackup returns the backup left behind by a restore of `sourceDB` that was killed, nil if there is none
+func findEmergencyBackup(ctx context.Context, db *sql.DB, sourceDB string) (*definitions.EmergencyBackup, error) {
+	backupDBName := backupDBPrefix + sourceDB
+	backupExists, err := dbExists(ctx, db, backupDBName)
+	if err != nil || !backupExists {
+		return nil, err
+	}
+	// the marker is written once the backup is fully copied
+	var markers int
+	query := "SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	if err := db.QueryRowContext(ctx, query, backupDBName, backupMarkerTable).Scan(&markers); err != nil {
+		return nil, fmt.Errorf("failed to look up backup marker: %w", err)
+	}
+	sourceExists, err := dbExists(ctx, db, sourceDB)
+	if err != nil {
+		return nil, err
+	}
+	return &definitions.EmergencyBackup{
+		Name:     backupDBName,
+		Complete: markers > 0,
+		DBExists: sourceExists,
+	}, nil
+}
+
+// rollBackEmergencyBackup replaces `sourceDB` by its emergency backup, the backup is only dropped once copied,
+// so it can be run again if interrupted
+func rollBackEmergencyBackup(ctx context.Context, db *sql.DB, sourceDB string) error {
+	backup, err := findEmergencyBackup(ctx, db, sourceDB)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	if !backup.CanRollBack() {
+		return fmt.Errorf("backup %s is incomplete, it can only be dropped", backup.Name)
+	}
+	if err := dropDB(ctx, db, sourceDB); err != nil {
+		return fmt.Errorf("failed to drop the database: %w", err)
+	}
+	if err := cloneDB(ctx, db, backup.Name, sourceDB); err != nil {
+		return fmt.Errorf("failed to copy backup to original: %w", err)
+	}
+	return dropDB(ctx, db, backup.Name)
+}
+
+// rollForwardEmergencyBackup keeps `sourceDB` and drops its emergency backup
+func rollForwardEmergencyBackup(ctx context.Context, db *sql.DB, sourceDB string) error {
+	backup, err := findEmergencyBackup(ctx, db, sourceDB)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	if !backup.CanRollForward() {
+		return fmt.Errorf("database %s is missing, it can only be rolled back", sourceDB)
+	}
+	return dropDB(ctx, db, backup.Name)
+}
+
+func restoreDB(ctx context.Context, db *sql.DB, originalDBName, snapshotDBName string, fast bool) error {
+
+	if fast {
+		if err := dropDB(ctx, db, originalDBName); err != nil {
+			return err
+		}
+		if err := cloneDB(ctx, db, snapshotDBName, originalDBName); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	return backupDB(ctx, db, originalDBName, func() error {
+		if err := dropDB(ctx, db, originalDBName); err != nil {
+			return err
+		}
+		if err := cloneDB(ctx, db, snapshotDBName, originalDBName); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func snapshotDB(ctx context.Context, db *sql.DB, originalDBName, snapshotName string) error {
+	snapshotDBName, err := utils.BuildSnapshotDBName(originalDBName, snapshotName, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := utils.CheckSnapshotDBNameLength(snapshotDBName, maxDBNameLength); err != nil {
+		return err
+	}
+	if err := cloneDB(ctx, db, originalDBName, snapshotDBName); err != nil {
+		// don't leave a partial snapshot behind
+		_ = dropDB(context.WithoutCancel(ctx), db, snapshotDBName)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package mysql_db_operator
+
+import (
+	"context"
+	"errors"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestIntegration_MySQLBackup(t *testing.T) {
+	dbURL, cleanupContainer := createMySQLContainer("gho_db", "root", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseMySQLURL(dbURL)
+	assert.NoError(t, err)
+
+	mysqlClient, cleanupConnection, err := createMySQLConnection(context.Background(), parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	// write data to DB
+	WriteMySQLSeedData(dbURL, "vehicles")
+
+	// attempt destructive operation with backup
+	didAttemptDrop := false
+	err = backupDB(context.Background(), mysqlClient, parsedURL.DBName(), func() error {
+		if err := dropDB(context.Background(), mysqlClient, parsedURL.DBName()); err != nil {
+			panic(err)
+		}
+		didAttemptDrop = true
+		return errors.New("test err")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "test err", err.Error())
+	assert.True(t, didAttemptDrop)
+
+	// verify that original DB is intact
+	rows := MySQLRunQuery(dbURL, "SELECT SCHEMA_NAME FROM information_schema.SCHEMATA")
+	_, err = utils.Find(rows, func(row map[string]interface{}) bool {
+		return string(row["SCHEMA_NAME"].([]byte)) == parsedURL.DBName()
+	})
+	assert.NoError(t, err)
+
+	items := MySQLRunQuery(dbURL, `
+		SELECT * FROM vehicles
+	`)
+	assert.EqualValues(t, 5, len(items))
+}
+
+func TestIntegration_MySQLCloneDB(t *testing.T) {
+	dbURL, cleanupContainer := createMySQLContainer("gho_db", "root", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseMySQLURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	mysqlClient, cleanupConnection, err := createMySQLConnection(ctx, parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	WriteMySQLSeedData(dbURL, "vehicles")
+	MySQLRunQuery(dbURL, "ALTER TABLE vehicles ADD COLUMN label VARCHAR(120) AS (CONCAT(make, ' ', model)) STORED")
+	MySQLRunQuery(dbURL, "CREATE TABLE audit (id INT AUTO_INCREMENT PRIMARY KEY, vehicle_id INT)")
+	MySQLRunQuery(dbURL, "CREATE TRIGGER vehicles_audit AFTER INSERT ON vehicles FOR EACH ROW INSERT INTO audit (vehicle_id) VALUES (NEW.id)")
+	MySQLRunQuery(dbURL, "CREATE FUNCTION vehicle_count() RETURNS INT READS SQL DATA RETURN (SELECT COUNT(*) FROM vehicles)")
+	MySQLRunQuery(dbURL, "CREATE PROCEDURE add_vehicle(IN vehicle_make VARCHAR(50)) INSERT INTO vehicles (make) VALUES (vehicle_make)")
+	// listed before the view it uses
+	MySQLRunQuery(dbURL, "CREATE VIEW z_labels AS SELECT id, label, year FROM vehicles")
+	MySQLRunQuery(dbURL, "CREATE VIEW a_recent AS SELECT * FROM z_labels WHERE year > 2020")
+
+	assert.NoError(t, cloneDB(ctx, mysqlClient, "gho_db", "gho_clone"))
+	cloneURL := strings.TrimSuffix(dbURL, "gho_db") + "gho_clone"
+
+	rows := MySQLRunQuery(cloneURL, "SELECT label FROM vehicles WHERE id = 1")
+	assert.Equal(t, "Toyota Camry", string(rows[0]["label"].([]byte)))
+	assert.Len(t, MySQLRunQuery(cloneURL, "SELECT * FROM a_recent"), 3)
+	assert.Empty(t, MySQLRunQuery(cloneURL, "SELECT * FROM audit"), "the triggers shouldn't fire on the copied rows")
+	views := MySQLRunQuery(cloneURL, "SELECT VIEW_DEFINITION FROM information_schema.VIEWS WHERE TABLE_SCHEMA = 'gho_clone'")
+	assert.Len(t, views, 2)
+	for _, view := range views {
+		assert.NotContains(t, string(view["VIEW_DEFINITION"].([]byte)), "gho_db", "the views should use the tables of the clone")
+	}
+
+	// the routines and triggers work on the clone
+	MySQLRunQuery(cloneURL, "CALL add_vehicle('Kia')")
+	assert.Len(t, MySQLRunQuery(cloneURL, "SELECT * FROM audit"), 1)
+	rows = MySQLRunQuery(cloneURL, "SELECT vehicle_count() AS count")
+	assert.Equal(t, "6", string(rows[0]["count"].([]byte)))
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+	assert.Empty(t, MySQLRunQuery(dbURL, "SELECT * FROM audit"))
+}
+
+func TestIntegration_MySQLRecovery(t *testing.T) {
+	dbURL, cleanupContainer := createMySQLContainer("gho_db", "root", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseMySQLURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	dbName := parsedURL.DBName()
+	backupDBName := backupDBPrefix + dbName
+
+	mysqlClient, cleanupConnection, err := createMySQLConnection(ctx, parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	WriteMySQLSeedData(dbURL, "vehicles")
+
+	// nothing was interrupted
+	backup, err := findEmergencyBackup(ctx, mysqlClient, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+	assert.ErrorIs(t, rollForwardEmergencyBackup(ctx, mysqlClient, dbName), values.NothingToRecoverErr)
+
+	// killed while backing up the database, before the marker was written
+	assert.NoError(t, cloneDB(ctx, mysqlClient, dbName, backupDBName))
+	backup, err = findEmergencyBackup(ctx, mysqlClient, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupDBName, Complete: false, DBExists: true}, backup)
+	assert.Error(t, rollBackEmergencyBackup(ctx, mysqlClient, dbName), "a partial backup can't replace the database")
+	assert.NoError(t, rollForwardEmergencyBackup(ctx, mysqlClient, dbName))
+	assert.Len(t, MySQLRunQuery(dbURL, "SELECT * FROM vehicles"), 5)
+
+	// killed after dropping the database
+	assert.NoError(t, cloneDB(ctx, mysqlClient, dbName, backupDBName))
+	assert.NoError(t, writeBackupMarker(ctx, mysqlClient, backupDBName))
+	assert.NoError(t, dropDB(ctx, mysqlClient, dbName))
+	backup, err = findEmergencyBackup(ctx, mysqlClient, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupDBName, Complete: true, DBExists: false}, backup)
+	assert.Error(t, rollForwardEmergencyBackup(ctx, mysqlClient, dbName), "the backup is all that is left")
+	assert.NoError(t, rollBackEmergencyBackup(ctx, mysqlClient, dbName))
+	assert.Len(t, MySQLRunQuery(dbURL, "SELECT * FROM vehicles"), 5)
+	assert.Empty(t, MySQLRunQuery(dbURL, "SHOW TABLES LIKE '"+backupMarkerTable+"'"), "the marker is never copied")
+
+	// killed while copying the snapshot
+	assert.NoError(t, cloneDB(ctx, mysqlClient, dbName, backupDBName))
+	assert.NoError(t, writeBackupMarker(ctx, mysqlClient, backupDBName))
+	MySQLRunQuery(dbURL, "DROP TABLE vehicles")
+	WriteMySQLSeedData(dbURL, "planets")
+	backup, err = findEmergencyBackup(ctx, mysqlClient, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupDBName, Complete: true, DBExists: true}, backup)
+	assert.NoError(t, rollBackEmergencyBackup(ctx, mysqlClient, dbName))
+	assert.Len(t, MySQLRunQuery(dbURL, "SELECT * FROM vehicles"), 5)
+	assert.Empty(t, MySQLRunQuery(dbURL, "SHOW TABLES LIKE 'planets'"))
+
+	// killed before dropping the backup
+	assert.NoError(t, cloneDB(ctx, mysqlClient, dbName, backupDBName))
+	assert.NoError(t, writeBackupMarker(ctx, mysqlClient, backupDBName))
+	WriteMySQLSeedData(dbURL, "planets")
+	assert.NoError(t, rollForwardEmergencyBackup(ctx, mysqlClient, dbName))
+	assert.Len(t, MySQLRunQuery(dbURL, "SELECT * FROM planets"), 5)
+	backup, err = findEmergencyBackup(ctx, mysqlClient, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+}
+
+func TestIntegration_MySQLListSnapshots_UnreadableName(t *testing.T) {
+	dbURL, cleanupContainer := createMySQLContainer("gho_db", "root", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseMySQLURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	dbName := parsedURL.DBName()
+
+	mysqlClient, cleanupConnection, err := createMySQLConnection(ctx, parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	WriteMySQLSeedData(dbURL, "vehicles")
+	assert.NoError(t, snapshotDB(ctx, mysqlClient, dbName, "v1"))
+
+	// e.g. a snapshot database copied by hand
+	unreadableDBName := values.SnapshotDBPrefix + "copy"
+	assert.NoError(t, cloneDB(ctx, mysqlClient, dbName, unreadableDBName))
+
+	skipped := make([]string, 0)
+	list, err := listSnapshots(ctx, mysqlClient, dbName, func(dbName string, err error) {
+		skipped = append(skipped, dbName)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "v1", list[0].SnapshotName)
+	assert.Equal(t, []string{unreadableDBName}, skipped)
+}
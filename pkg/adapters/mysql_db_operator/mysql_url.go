@@ -0,0 +1,76 @@
+package mysql_db_operator
+
+import (
+	"fmt"
+	"ghostal/pkg/values"
+	"github.com/go-sql-driver/mysql"
+	"net/url"
+	"strings"
+)
+
+type MySQLURL struct {
+	dbURL *url.URL
+}
+
+func (p *MySQLURL) Clone() *url.URL {
+	clone := *p.dbURL
+	if p.dbURL.User != nil {
+		u := *p.dbURL.User
+		clone.User = &u
+	}
+	return &clone
+}
+
+func (p *MySQLURL) Username() string {
+	return p.dbURL.User.Username()
+}
+
+func (p *MySQLURL) DBName() string {
+	return strings.TrimPrefix(p.dbURL.Path, "/")
+}
+
+// DSN converts the URL to the DSN format expected by the MySQL driver
+func (p *MySQLURL) DSN(useDefault bool) (string, error) {
+	dbName := p.DBName()
+	if useDefault {
+		dbName = ""
+	}
+	dsn := fmt.Sprintf("tcp(%s)/%s", p.dbURL.Host, dbName)
+	if p.dbURL.RawQuery != "" {
+		dsn += "?" + p.dbURL.RawQuery
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse mysql url: %w", err)
+	}
+	if p.dbURL.User != nil {
+		cfg.User = p.dbURL.User.Username()
+		cfg.Passwd, _ = p.dbURL.User.Password()
+	}
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	// tables are copied one at a time, so foreign keys cannot be checked until all tables exist
+	if _, ok := cfg.Params["foreign_key_checks"]; !ok {
+		cfg.Params["foreign_key_checks"] = "0"
+	}
+	return cfg.FormatDSN(), nil
+}
+
+func ParseMySQLURL(dbURL string) (*MySQLURL, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "mysql" {
+		return nil, values.UnsupportedURLSchemeError
+	}
+	mysqlURL := &MySQLURL{
+		dbURL: u,
+	}
+	// the snapshots are databases next to it, the server as a whole can't be snapshotted
+	if mysqlURL.DBName() == "" {
+		return nil, fmt.Errorf("%w: %s", values.MissingDatabaseNameErr, "add it to the path, e.g. mysql://localhost:3306/main")
+	}
+	return mysqlURL, nil
+}
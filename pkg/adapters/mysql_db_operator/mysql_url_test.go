@@ -0,0 +1,47 @@
+package mysql_db_operator
+
+import (
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const ValidMySQLURL = "mysql://admin:pw@localhost:3306/main?parseTime=true"
+
+func TestUnit_ParseMySQLURL(t *testing.T) {
+	mysqlURL, err := ParseMySQLURL(ValidMySQLURL)
+	assert.NoError(t, err)
+	assert.NotNil(t, mysqlURL)
+
+	assert.Equal(t, "admin", mysqlURL.Username())
+	assert.Equal(t, "main", mysqlURL.DBName())
+}
+
+func TestUnit_ParseMySQLURL_MissingDatabaseName(t *testing.T) {
+	for _, input := range []string{"mysql://admin:pw@localhost:3306", "mysql://admin:pw@localhost:3306/", "mysql://localhost?parseTime=true"} {
+		_, err := ParseMySQLURL(input)
+		assert.ErrorIs(t, err, values.MissingDatabaseNameErr, input)
+	}
+}
+
+func TestUnit_MySQLURL_DSN(t *testing.T) {
+	mysqlURL, err := ParseMySQLURL(ValidMySQLURL)
+	assert.NoError(t, err)
+
+	{
+		dsn, err := mysqlURL.DSN(false)
+		assert.NoError(t, err)
+		assert.Equal(t, "admin:pw@tcp(localhost:3306)/main?parseTime=true&foreign_key_checks=0", dsn)
+	}
+
+	{
+		dsn, err := mysqlURL.DSN(true)
+		assert.NoError(t, err)
+		assert.Equal(t, "admin:pw@tcp(localhost:3306)/?parseTime=true&foreign_key_checks=0", dsn)
+	}
+}
+
+func TestUnit_CreateMySQLDBOperator_UnsupportedScheme(t *testing.T) {
+	_, err := CreateMySQLDBOperator("postgresql://admin:pw@localhost/main")
+	assert.Error(t, err)
+}
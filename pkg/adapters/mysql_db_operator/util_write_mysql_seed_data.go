@@ -0,0 +1,23 @@
+package mysql_db_operator
+
+import "fmt"
+
+func WriteMySQLSeedData(dbURL string, tableName string) {
+	MySQLRunQuery(dbURL, fmt.Sprintf(`
+		CREATE TABLE %s (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			make VARCHAR(50),
+			model VARCHAR(50),
+			year INT,
+			color VARCHAR(50)
+		);
+	`, tableName))
+	MySQLRunQuery(dbURL, fmt.Sprintf(`
+		INSERT INTO %s (make, model, year, color) VALUES
+		('Toyota', 'Camry', 2022, 'Black'),
+		('Ford', 'Mustang', 2021, 'Red'),
+		('Honda', 'Civic', 2020, 'Blue'),
+		('Tesla', 'Model 3', 2023, 'White'),
+		('Chevrolet', 'Impala', 2019, 'Silver');
+	`, tableName))
+}
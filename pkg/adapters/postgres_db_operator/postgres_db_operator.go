@@ -1,6 +1,7 @@
 package postgres_db_operator
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"ghostal/pkg/definitions"
@@ -10,7 +11,9 @@ import (
 )
 
 type PostgresDBOperator struct {
-	pgURL *PostgresURL
+	pgURL    *PostgresURL
+	reporter definitions.IProgressReporter
+	logger   definitions.ILogger
 }
 
 func CreatePostgresDBOperator(dbURL string) (*PostgresDBOperator, error) {
@@ -26,12 +29,34 @@ func CreatePostgresDBOperator(dbURL string) (*PostgresDBOperator, error) {
 	}, nil
 }
 
-func (p *PostgresDBOperator) connect(useDefault bool) (*sql.DB, func(), error) {
-	return createPostgresConnection(p.pgURL, useDefault)
+// SetProgressReporter reports the steps of snapshots and restores
+func (p *PostgresDBOperator) SetProgressReporter(reporter definitions.IProgressReporter) {
+	p.reporter = reporter
 }
 
-func (p *PostgresDBOperator) checkSnapshotName(snapshotName string) error {
-	list, err := p.ListSnapshots()
+func (p *PostgresDBOperator) reportPhase(phase string) {
+	if p.reporter != nil {
+		p.reporter.Report(definitions.Progress{Phase: phase})
+	}
+}
+
+// SetLogger warns about the databases that look like snapshots but can't be read
+func (p *PostgresDBOperator) SetLogger(logger definitions.ILogger) {
+	p.logger = logger
+}
+
+func (p *PostgresDBOperator) warnSkippedDB(dbName string, err error) {
+	if p.logger != nil {
+		p.logger.Warning("skipped database \"%s\", it looks like a snapshot but can't be read, it may still be being created or left behind by a killed snapshot: %s", dbName, err)
+	}
+}
+
+func (p *PostgresDBOperator) connect(ctx context.Context, useDefault bool) (*sql.DB, func(), error) {
+	return createPostgresConnection(ctx, p.pgURL, useDefault)
+}
+
+func (p *PostgresDBOperator) checkSnapshotName(ctx context.Context, snapshotName string) error {
+	list, err := p.ListSnapshots(ctx)
 	if err != nil {
 		return err
 	}
@@ -45,28 +70,57 @@ func (p *PostgresDBOperator) checkSnapshotName(snapshotName string) error {
 	return nil
 }
 
-func (p *PostgresDBOperator) Snapshot(snapshotName string) error {
-	if err := p.checkSnapshotName(snapshotName); err != nil {
+func (p *PostgresDBOperator) Snapshot(ctx context.Context, snapshotName string) error {
+	if err := p.checkSnapshotName(ctx, snapshotName); err != nil {
 		return err
 	}
-	db, close, err := p.connect(true)
+	db, close, err := p.connect(ctx, true)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 	defer close()
 	originalDBName := p.pgURL.DBName()
 	originalDBOwner := p.pgURL.Username()
-	return snapshotDB(db, originalDBName, originalDBOwner, snapshotName)
+	return snapshotDB(ctx, db, originalDBName, originalDBOwner, snapshotName, p.reportPhase)
+}
+
+// ReplaceSnapshot takes the new snapshot before dropping the previous one, so that a failure never loses both
+func (p *PostgresDBOperator) ReplaceSnapshot(ctx context.Context, snapshotName string) error {
+	return p.replace(ctx, snapshotName, func(db *sql.DB) error {
+		return snapshotDB(ctx, db, p.pgURL.DBName(), p.pgURL.Username(), snapshotName, p.reportPhase)
+	})
+}
+
+// replace runs `create`, then drops the snapshot databases that were named `snapshotName` before
+func (p *PostgresDBOperator) replace(ctx context.Context, snapshotName string, create func(db *sql.DB) error) error {
+	db, close, err := p.connect(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+	list, err := listSnapshots(ctx, db, p.pgURL.DBName(), p.warnSkippedDB)
+	if err != nil {
+		return err
+	}
+	if err := create(db); err != nil {
+		return err
+	}
+	for _, item := range list.Named(snapshotName) {
+		if err := dropDB(ctx, db, item.DBName); err != nil {
+			return fmt.Errorf("failed to drop the previous snapshot \"%s\": %w", snapshotName, err)
+		}
+	}
+	return nil
 }
 
-func (p *PostgresDBOperator) Restore(snapshotName string, fast bool) error {
-	db, close, err := p.connect(true)
+func (p *PostgresDBOperator) Restore(ctx context.Context, snapshotName string, fast bool) error {
+	db, close, err := p.connect(ctx, true)
 	if err != nil {
 		return err
 	}
 	defer close()
 
-	list, err := listSnapshots(db, p.pgURL.DBName())
+	list, err := listSnapshots(ctx, db, p.pgURL.DBName(), p.warnSkippedDB)
 	if err != nil {
 		return err
 	}
@@ -75,7 +129,7 @@ func (p *PostgresDBOperator) Restore(snapshotName string, fast bool) error {
 			originalDBName := p.pgURL.DBName()
 			snapshotDBName := item.DBName
 			originalDBOwner := p.pgURL.Username()
-			if err := restoreDB(db, originalDBName, snapshotDBName, originalDBOwner, fast); err != nil {
+			if err := restoreDB(ctx, db, originalDBName, snapshotDBName, originalDBOwner, fast, p.reportPhase); err != nil {
 				return err
 			}
 			return nil
@@ -84,20 +138,20 @@ func (p *PostgresDBOperator) Restore(snapshotName string, fast bool) error {
 	return values.SnapshotNotExistsErr
 }
 
-func (p *PostgresDBOperator) Delete(snapshotName string) error {
-	db, close, err := p.connect(true)
+func (p *PostgresDBOperator) Delete(ctx context.Context, snapshotName string) error {
+	db, close, err := p.connect(ctx, true)
 	if err != nil {
 		return err
 	}
 	defer close()
 
-	list, err := listSnapshots(db, p.pgURL.DBName())
+	list, err := listSnapshots(ctx, db, p.pgURL.DBName(), p.warnSkippedDB)
 	if err != nil {
 		return err
 	}
 	for _, item := range list {
 		if item.SnapshotName == snapshotName {
-			if err := dropDB(db, item.DBName); err != nil {
+			if err := dropDB(ctx, db, item.DBName); err != nil {
 				return err
 			}
 			return nil
@@ -106,12 +160,105 @@ func (p *PostgresDBOperator) Delete(snapshotName string) error {
 	return values.SnapshotNotExistsErr
 }
 
-func (p *PostgresDBOperator) ListSnapshots() (definitions.SnapshotList, error) {
-	db, close, err := p.connect(true)
+func (p *PostgresDBOperator) ListSnapshots(ctx context.Context) (definitions.SnapshotList, error) {
+	db, close, err := p.connect(ctx, true)
 	if err != nil {
 		return nil, err
 	}
 	defer close()
 
-	return listSnapshots(db, p.pgURL.DBName())
+	return listSnapshots(ctx, db, p.pgURL.DBName(), p.warnSkippedDB)
+}
+
+func (p *PostgresDBOperator) FindEmergencyBackup(ctx context.Context) (*definitions.EmergencyBackup, error) {
+	db, close, err := p.connect(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer close()
+
+	return findEmergencyBackup(ctx, db, p.pgURL.DBName())
+}
+
+func (p *PostgresDBOperator) RollBack(ctx context.Context) error {
+	db, close, err := p.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	return rollBackEmergencyBackup(ctx, db, p.pgURL.DBName())
+}
+
+func (p *PostgresDBOperator) RollForward(ctx context.Context) error {
+	db, close, err := p.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	return rollForwardEmergencyBackup(ctx, db, p.pgURL.DBName())
+}
+
+// Lock keeps other gho processes from working on the database until unlocked, even with another config
+// the advisory lock is held by its session until unlocked, so `ctx` is returned as is
+func (p *PostgresDBOperator) Lock(ctx context.Context, wait bool) (context.Context, func(), error) {
+	db, close, err := p.connect(ctx, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock, err := lockDB(ctx, db, p.pgURL.DBName(), wait)
+	if err != nil {
+		close()
+		return nil, nil, err
+	}
+	return ctx, func() {
+		unlock()
+		close()
+	}, nil
+}
+
+func (p *PostgresDBOperator) ExportSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveWriter) error {
+	list, err := p.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			entry, err := archive.CreateEntry(DumpArchiveEntry)
+			if err != nil {
+				return err
+			}
+			return dumpDB(ctx, p.pgURL, item.DBName, entry)
+		}
+	}
+	return values.SnapshotNotExistsErr
+}
+
+func (p *PostgresDBOperator) ImportSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveReader) error {
+	if err := p.checkSnapshotName(ctx, snapshotName); err != nil {
+		return err
+	}
+	entry, err := archive.OpenEntry(DumpArchiveEntry)
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	db, close, err := p.connect(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+	return importDB(ctx, db, p.pgURL, p.pgURL.DBName(), p.pgURL.Username(), snapshotName, entry)
+}
+
+func (p *PostgresDBOperator) ReplaceWithImportedSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveReader) error {
+	entry, err := archive.OpenEntry(DumpArchiveEntry)
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	return p.replace(ctx, snapshotName, func(db *sql.DB) error {
+		return importDB(ctx, db, p.pgURL, p.pgURL.DBName(), p.pgURL.Username(), snapshotName, entry)
+	})
 }
@@ -1,11 +1,16 @@
 package postgres_db_operator
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"io"
+	"os/exec"
 	"testing"
 )
 
@@ -75,21 +80,21 @@ func runTest(t *testing.T, dbUser string) {
 	assert.Equal(t, 5, getNumVehicles(dbURL))
 
 	{
-		assert.NoError(t, operator.Snapshot("v1"))
+		assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
 	}
 
 	{
-		allDatabases, err := operator.ListSnapshots()
+		allDatabases, err := operator.ListSnapshots(context.Background())
 		assert.NoError(t, err)
 		assert.Len(t, allDatabases, 1)
 	}
 
 	{
-		assert.NoError(t, operator.Snapshot("v2"))
+		assert.NoError(t, operator.Snapshot(context.Background(), "v2"))
 	}
 
 	{
-		allDatabases, err := operator.ListSnapshots()
+		allDatabases, err := operator.ListSnapshots(context.Background())
 		assert.NoError(t, err)
 		assert.Len(t, allDatabases, 2)
 	}
@@ -106,21 +111,164 @@ func runTest(t *testing.T, dbUser string) {
 	assert.Equal(t, 2, getNumVehicles(dbURL))
 
 	{
-		err := operator.Restore("v1", false)
+		err := operator.Restore(context.Background(), "v1", false)
 		assert.NoError(t, err)
 	}
 
 	assert.Equal(t, 5, getNumVehicles(dbURL))
 
 	{
-		err := operator.Delete("v2")
+		err := operator.Delete(context.Background(), "v2")
 		assert.NoError(t, err)
 	}
 
 	{
-		allDatabases, err := operator.ListSnapshots()
+		allDatabases, err := operator.ListSnapshots(context.Background())
 		assert.NoError(t, err)
 		assert.Len(t, allDatabases, 1)
 		assert.Equal(t, "v1", allDatabases[0].SnapshotName)
 	}
 }
+
+type memoryArchive map[string]*bytes.Buffer
+
+func (m memoryArchive) CreateEntry(name string) (io.Writer, error) {
+	m[name] = bytes.NewBuffer(nil)
+	return m[name], nil
+}
+
+func (m memoryArchive) Manifest() definitions.SnapshotArchiveManifest {
+	return definitions.SnapshotArchiveManifest{}
+}
+
+func (m memoryArchive) EntryNames() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m memoryArchive) OpenEntry(name string) (io.ReadCloser, error) {
+	entry, ok := m[name]
+	if !ok {
+		return nil, values.InvalidSnapshotArchiveErr
+	}
+	return io.NopCloser(bytes.NewReader(entry.Bytes())), nil
+}
+
+func TestIntegration_PostgresDBOperator_Export(t *testing.T) {
+	if _, err := exec.LookPath("pg_dump"); err != nil {
+		t.Skip("pg_dump is not installed")
+	}
+
+	dbURL, cleanup := createPostgresContainer(DBName, "postgres", DBPassword)
+	defer cleanup()
+
+	operator, err := CreatePostgresDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WritePostgresSeedData(dbURL, "vehicles")
+	assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+
+	archive := make(memoryArchive)
+	assert.ErrorIs(t, operator.ExportSnapshot(context.Background(), "v2", archive), values.SnapshotNotExistsErr)
+	assert.NoError(t, operator.ExportSnapshot(context.Background(), "v1", archive))
+
+	entry, ok := archive[DumpArchiveEntry]
+	assert.True(t, ok)
+	dump := entry.String()
+	assert.Contains(t, dump, "CREATE TABLE public.vehicles")
+	assert.Contains(t, dump, "Chevrolet")
+}
+
+func TestIntegration_PostgresDBOperator_Import(t *testing.T) {
+	if _, err := exec.LookPath("pg_dump"); err != nil {
+		t.Skip("pg_dump is not installed")
+	}
+	if _, err := exec.LookPath("psql"); err != nil {
+		t.Skip("psql is not installed")
+	}
+
+	dbURL, cleanup := createPostgresContainer(DBName, "postgres", DBPassword)
+	defer cleanup()
+
+	operator, err := CreatePostgresDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WritePostgresSeedData(dbURL, "vehicles")
+	assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+
+	archive := make(memoryArchive)
+	assert.NoError(t, operator.ExportSnapshot(context.Background(), "v1", archive))
+	assert.ErrorIs(t, operator.ImportSnapshot(context.Background(), "v1", archive), values.SnapshotNameTakenErr)
+	assert.NoError(t, operator.ImportSnapshot(context.Background(), "v2", archive))
+
+	// modify DB before restoring snapshot
+	PostgresRunQuery(dbURL, `
+		DELETE FROM vehicles
+	`)
+
+	assert.Equal(t, 0, getNumVehicles(dbURL))
+	assert.NoError(t, operator.Restore(context.Background(), "v2", false))
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+}
+
+func TestIntegration_PostgresDBOperator_RichSnapshotNames(t *testing.T) {
+	dbURL, cleanup := createPostgresContainer(DBName, "postgres", DBPassword)
+	defer cleanup()
+
+	operator, err := CreatePostgresDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WritePostgresSeedData(dbURL, "vehicles")
+	assert.NoError(t, operator.Snapshot(context.Background(), "Before-Migration_42"))
+	assert.NoError(t, operator.Snapshot(context.Background(), "v1.2"))
+
+	list, err := operator.ListSnapshots(context.Background())
+	assert.NoError(t, err)
+	names := make([]string, 0)
+	for _, item := range list {
+		names = append(names, item.SnapshotName)
+	}
+	assert.ElementsMatch(t, []string{"Before-Migration_42", "v1.2"}, names)
+
+	PostgresRunQuery(dbURL, `
+		DELETE FROM vehicles
+	`)
+	assert.NoError(t, operator.Restore(context.Background(), "Before-Migration_42", false))
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+	assert.NoError(t, operator.Delete(context.Background(), "v1.2"))
+}
+
+func TestIntegration_PostgresDBOperator_LongNames(t *testing.T) {
+	// 50 bytes, so that any snapshot database name exceeds the identifier length limit
+	longDBName := "inventory_service_development_database_for_tests_x"
+	dbURL, cleanup := createPostgresContainer(longDBName, "postgres", DBPassword)
+	defer cleanup()
+
+	operator, err := CreatePostgresDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WritePostgresSeedData(dbURL, "vehicles")
+	snapshotName := "before-migration_20240412_add_users_table"
+	assert.NoError(t, operator.Snapshot(context.Background(), snapshotName))
+	assert.ErrorIs(t, operator.Snapshot(context.Background(), snapshotName), values.SnapshotNameTakenErr)
+
+	list, err := operator.ListSnapshots(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, snapshotName, list[0].SnapshotName)
+	assert.LessOrEqual(t, len(list[0].DBName), maxIdentifierLength)
+
+	PostgresRunQuery(dbURL, `
+		DELETE FROM vehicles
+	`)
+	assert.NoError(t, operator.Restore(context.Background(), snapshotName, false))
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	assert.NoError(t, operator.Delete(context.Background(), snapshotName))
+	list, err = operator.ListSnapshots(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
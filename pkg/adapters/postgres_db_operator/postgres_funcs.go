@@ -1,17 +1,26 @@
 package postgres_db_operator
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"ghostal/pkg/definitions"
 	"ghostal/pkg/utils"
 	"ghostal/pkg/values"
 	"github.com/lib/pq"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
 	"strings"
 	"time"
 )
 
-func createPostgresConnection(postgresURL *PostgresURL, useDefault bool) (*sql.DB, func(), error) {
+// DumpArchiveEntry is the archive entry holding the plain SQL dump of an exported snapshot
+const DumpArchiveEntry = "dump.sql"
+
+func createPostgresConnection(ctx context.Context, postgresURL *PostgresURL, useDefault bool) (*sql.DB, func(), error) {
 	dbURL := postgresURL.dbURL.String()
 	if useDefault {
 		newPGURL := postgresURL.Clone()
@@ -23,7 +32,7 @@ func createPostgresConnection(postgresURL *PostgresURL, useDefault bool) (*sql.D
 		return nil, nil, fmt.Errorf("failed to open connection (%s): %w", dbURL, err)
 	}
 	// Attempt to ping the database to ensure connection is alive
-	err = db.Ping()
+	err = db.PingContext(ctx)
 	if err != nil {
 		_ = db.Close() // Ensure the connection is closed if not usable
 		sanitizedDBURL, _ := utils.SanitizeDBURL(dbURL)
@@ -34,42 +43,49 @@ func createPostgresConnection(postgresURL *PostgresURL, useDefault bool) (*sql.D
 	}, nil
 }
 
-func terminateConnections(db *sql.DB, targetDB string) error {
+func terminateConnections(ctx context.Context, db *sql.DB, targetDB string) error {
 	query := "SELECT pg_terminate_backend(pg_stat_activity.pid) FROM pg_stat_activity WHERE pg_stat_activity.datname = $1;"
-	_, err := db.Exec(query, targetDB)
+	_, err := db.ExecContext(ctx, query, targetDB)
 	if err != nil {
 		return fmt.Errorf("error terminating connections to database: %w", err)
 	}
 	return nil
 }
 
-func renameDB(db *sql.DB, currentName, newName string) error {
-	if err := terminateConnections(db, currentName); err != nil {
+func renameDB(ctx context.Context, db *sql.DB, currentName, newName string) error {
+	if err := terminateConnections(ctx, db, currentName); err != nil {
 		return fmt.Errorf("failed to terminate connection: %w", err)
 	}
 	query := fmt.Sprintf("ALTER DATABASE %s RENAME TO %s", pq.QuoteIdentifier(currentName), pq.QuoteIdentifier(newName))
-	_, err := db.Exec(query)
+	_, err := db.ExecContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error renaming database from %s to %s: %w", currentName, newName, err)
 	}
 	return nil
 }
 
-func dropDB(db *sql.DB, targetDB string) error {
-	if err := terminateConnections(db, targetDB); err != nil {
+func dropDB(ctx context.Context, db *sql.DB, targetDB string) error {
+	if err := terminateConnections(ctx, db, targetDB); err != nil {
 		return fmt.Errorf("failed to terminate connection: %w", err)
 	}
 	query := fmt.Sprintf("DROP DATABASE IF EXISTS %s", pq.QuoteIdentifier(targetDB))
-	_, err := db.Exec(query)
+	_, err := db.ExecContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func listSnapshots(db *sql.DB, sourceDBName string) (definitions.SnapshotList, error) {
-	query := "SELECT datname FROM pg_database"
-	rows, err := db.Query(query)
+func listSnapshots(ctx context.Context, db *sql.DB, sourceDBName string, onSkip func(dbName string, err error)) (definitions.SnapshotList, error) {
+	// the size of databases the user cannot connect to can't be read
+	query := `
+		SELECT
+			datname,
+			COALESCE(shobj_description(oid, 'pg_database'), ''),
+			CASE WHEN has_database_privilege(datname, 'CONNECT') THEN pg_database_size(datname) ELSE 0 END
+		FROM pg_database
+	`
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -79,16 +95,21 @@ func listSnapshots(db *sql.DB, sourceDBName string) (definitions.SnapshotList, e
 
 	for rows.Next() {
 		var dbName string
-		if err := rows.Scan(&dbName); err != nil {
+		var comment string
+		var sizeBytes int64
+		if err := rows.Scan(&dbName, &comment, &sizeBytes); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 		if !strings.HasPrefix(dbName, values.SnapshotDBPrefix) {
 			continue
 		}
 
-		snapshotDBNameParts, err := utils.ParseSnapshotDBName(dbName)
+		snapshotDBNameParts, err := parseSnapshotDBName(dbName, comment)
 		if err != nil {
-			return nil, err
+			// e.g. a shortened name whose comment isn't stored yet, since the snapshot is still being taken or was killed,
+			// it must not keep the snapshots of every project on the server from being listed
+			onSkip(dbName, err)
+			continue
 		}
 
 		if snapshotDBNameParts.SourceDBName != sourceDBName {
@@ -99,6 +120,7 @@ func listSnapshots(db *sql.DB, sourceDBName string) (definitions.SnapshotList, e
 			SnapshotName: snapshotDBNameParts.SnapshotName,
 			DBName:       dbName,
 			CreatedAt:    snapshotDBNameParts.Timestamp,
+			SizeBytes:    sizeBytes,
 		})
 	}
 
@@ -109,68 +131,219 @@ func listSnapshots(db *sql.DB, sourceDBName string) (definitions.SnapshotList, e
 	return list, nil
 }
 
-// backupDB backs up `sourceDB` and restores it if `fn` fails
-func backupDB(db *sql.DB, sourceDB string, fn func() error) error {
-	if err := terminateConnections(db, sourceDB); err != nil {
+// backupDB backs up `sourceDB` and restores it if `fn` fails, including when `ctx` is cancelled.
+// `onPhase` is called as the backup moves on to its next step.
+func backupDB(ctx context.Context, db *sql.DB, sourceDB string, onPhase func(phase string), fn func() error) error {
+	onPhase("terminating connections")
+	if err := terminateConnections(ctx, db, sourceDB); err != nil {
 		return fmt.Errorf("failed to terminate connection: %w", err)
 	}
-	backupDBName := "temp_emergency_backup_" + sourceDB
-	if err := renameDB(db, sourceDB, backupDBName); err != nil {
+	onPhase("backing up the database")
+	backupDBName := buildBackupDBName(sourceDB)
+	if err := renameDB(ctx, db, sourceDB, backupDBName); err != nil {
 		return fmt.Errorf("failed to rename snapshot: %w", err)
 	}
 	if err := fn(); err != nil {
-		// if error, drop current source and rename backup to source
-		_ = dropDB(db, sourceDB)
-		_ = renameDB(db, backupDBName, sourceDB)
+		// if error, drop current source and rename backup to source, even if cancelled
+		onPhase("rolling back to the backup")
+		rollbackCtx := context.WithoutCancel(ctx)
+		_ = dropDB(rollbackCtx, db, sourceDB)
+		_ = renameDB(rollbackCtx, db, backupDBName, sourceDB)
 		return err
 	}
 	// is success, drop backup
-	_ = dropDB(db, backupDBName)
+	onPhase("dropping the backup")
+	_ = dropDB(ctx, db, backupDBName)
 	return nil
 }
 
-func createTemplateDB(db *sql.DB, targetDBName, sourceDBName, dbOwner string) error {
-	if err := terminateConnections(db, sourceDBName); err != nil {
+func dbExists(ctx context.Context, db *sql.DB, dbName string) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)"
+	if err := db.QueryRowContext(ctx, query, dbName).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up database %s: %w", dbName, err)
+	}
+	return exists, nil
+}
+
+// findEmergencyBackup returns the backup left behind by a restore of `sourceDB` that was killed, nil if there is none
+func findEmergencyBackup(ctx context.Context, db *sql.DB, sourceDB string) (*definitions.EmergencyBackup, error) {
+	backupDBName := buildBackupDBName(sourceDB)
+	backupExists, err := dbExists(ctx, db, backupDBName)
+	if err != nil || !backupExists {
+		return nil, err
+	}
+	sourceExists, err := dbExists(ctx, db, sourceDB)
+	if err != nil {
+		return nil, err
+	}
+	// the backup is taken by renaming the database, so it is never partial
+	return &definitions.EmergencyBackup{
+		Name:     backupDBName,
+		Complete: true,
+		DBExists: sourceExists,
+	}, nil
+}
+
+// rollBackEmergencyBackup replaces `sourceDB` by its emergency backup, it can be run again if interrupted
+func rollBackEmergencyBackup(ctx context.Context, db *sql.DB, sourceDB string) error {
+	backup, err := findEmergencyBackup(ctx, db, sourceDB)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	if err := dropDB(ctx, db, sourceDB); err != nil {
+		return fmt.Errorf("failed to drop the database: %w", err)
+	}
+	return renameDB(ctx, db, backup.Name, sourceDB)
+}
+
+// rollForwardEmergencyBackup keeps `sourceDB` and drops its emergency backup
+func rollForwardEmergencyBackup(ctx context.Context, db *sql.DB, sourceDB string) error {
+	backup, err := findEmergencyBackup(ctx, db, sourceDB)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	if !backup.CanRollForward() {
+		return fmt.Errorf("database %s is missing, it can only be rolled back", sourceDB)
+	}
+	return dropDB(ctx, db, backup.Name)
+}
+
+func createTemplateDB(ctx context.Context, db *sql.DB, targetDBName, sourceDBName, dbOwner string) error {
+	if err := terminateConnections(ctx, db, sourceDBName); err != nil {
 		return fmt.Errorf("failed to terminate connection: %w", err)
 	}
-	query := fmt.Sprintf("CREATE DATABASE %s WITH TEMPLATE %s OWNER %s;", targetDBName, sourceDBName, dbOwner)
-	_, err := db.Exec(query)
+	query := fmt.Sprintf("CREATE DATABASE %s WITH TEMPLATE %s OWNER %s;", pq.QuoteIdentifier(targetDBName), pq.QuoteIdentifier(sourceDBName), pq.QuoteIdentifier(dbOwner))
+	_, err := db.ExecContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to create template database (%s): %w", query, err)
 	}
 	return nil
 }
 
-func restoreDB(db *sql.DB, originalDBName, snapshotDBName, originalDBOwner string, fast bool) error {
+func restoreDB(ctx context.Context, db *sql.DB, originalDBName, snapshotDBName, originalDBOwner string, fast bool, onPhase func(phase string)) error {
 
 	if fast {
-		if err := dropDB(db, originalDBName); err != nil {
+		onPhase("dropping the database")
+		if err := dropDB(ctx, db, originalDBName); err != nil {
 			return err
 		}
-		if err := createTemplateDB(db, originalDBName, snapshotDBName, originalDBOwner); err != nil {
+		onPhase("copying the snapshot")
+		if err := createTemplateDB(ctx, db, originalDBName, snapshotDBName, originalDBOwner); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	return backupDB(db, originalDBName, func() error {
-		if err := dropDB(db, originalDBName); err != nil {
+	return backupDB(ctx, db, originalDBName, onPhase, func() error {
+		onPhase("dropping the database")
+		if err := dropDB(ctx, db, originalDBName); err != nil {
 			return err
 		}
-		if err := createTemplateDB(db, originalDBName, snapshotDBName, originalDBOwner); err != nil {
+		onPhase("copying the snapshot")
+		if err := createTemplateDB(ctx, db, originalDBName, snapshotDBName, originalDBOwner); err != nil {
 			return err
 		}
 		return nil
 	})
 }
 
-func snapshotDB(db *sql.DB, originalDBName, originalDBOwner, snapshotName string) error {
-	if err := terminateConnections(db, originalDBName); err != nil {
+func snapshotDB(ctx context.Context, db *sql.DB, originalDBName, originalDBOwner, snapshotName string, onPhase func(phase string)) error {
+	onPhase("terminating connections")
+	if err := terminateConnections(ctx, db, originalDBName); err != nil {
+		return err
+	}
+	snapshotDBName, comment, err := buildSnapshotDBName(originalDBName, snapshotName, time.Now())
+	if err != nil {
+		return err
+	}
+	onPhase("copying the database")
+	if err := createTemplateDB(ctx, db, snapshotDBName, originalDBName, originalDBOwner); err != nil {
+		return err
+	}
+	if err := commentOnDB(ctx, db, snapshotDBName, comment); err != nil {
+		// a shortened name cannot be listed without its comment
+		_ = dropDB(context.WithoutCancel(ctx), db, snapshotDBName)
+		return err
+	}
+	return nil
+}
+
+// postgresClientCommand prepares a PostgreSQL client tool invocation against `dbName`,
+// passing the password through the environment so that it doesn't show up in the process list
+func postgresClientCommand(ctx context.Context, postgresURL *PostgresURL, dbName, tool string, args ...string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil, fmt.Errorf("%s is required for this operation, install the PostgreSQL client tools", tool)
+	}
+	dbURL := postgresURL.Clone()
+	dbURL.Path = dbName
+	env := os.Environ()
+	if dbURL.User != nil {
+		if password, ok := dbURL.User.Password(); ok {
+			env = append(env, "PGPASSWORD="+password)
+		}
+		dbURL.User = url.User(dbURL.User.Username())
+	}
+	cmd := exec.CommandContext(ctx, tool, append(args, "--dbname="+dbURL.String())...)
+	cmd.Env = env
+	return cmd, nil
+}
+
+// dumpDB streams a plain SQL dump of `dbName` into `w`
+func dumpDB(ctx context.Context, postgresURL *PostgresURL, dbName string, w io.Writer) error {
+	cmd, err := postgresClientCommand(ctx, postgresURL, dbName, "pg_dump", "--format=plain", "--no-owner", "--no-privileges")
+	if err != nil {
+		return err
+	}
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stdout = w
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_dump failed (%w): %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// loadDB runs the plain SQL dump read from `r` against `dbName` in a single transaction
+func loadDB(ctx context.Context, postgresURL *PostgresURL, dbName string, r io.Reader) error {
+	cmd, err := postgresClientCommand(ctx, postgresURL, dbName, "psql", "--quiet", "--no-psqlrc", "--set=ON_ERROR_STOP=1", "--single-transaction")
+	if err != nil {
 		return err
 	}
-	snapshotDBName, err := utils.BuildSnapshotDBName(originalDBName, snapshotName, time.Now())
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stdin = r
+	cmd.Stdout = io.Discard
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("psql failed (%w): %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// importDB creates a snapshot database from the plain SQL dump read from `r`
+func importDB(ctx context.Context, db *sql.DB, postgresURL *PostgresURL, originalDBName, originalDBOwner, snapshotName string, r io.Reader) error {
+	snapshotDBName, comment, err := buildSnapshotDBName(originalDBName, snapshotName, time.Now())
 	if err != nil {
 		return err
 	}
-	return createTemplateDB(db, snapshotDBName, originalDBName, originalDBOwner)
+	query := fmt.Sprintf("CREATE DATABASE %s WITH TEMPLATE template0 OWNER %s", pq.QuoteIdentifier(snapshotDBName), pq.QuoteIdentifier(originalDBOwner))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create snapshot database: %w", err)
+	}
+	err = commentOnDB(ctx, db, snapshotDBName, comment)
+	if err == nil {
+		err = loadDB(ctx, postgresURL, snapshotDBName, r)
+	}
+	if err != nil {
+		// don't leave a partial snapshot behind
+		_ = dropDB(context.WithoutCancel(ctx), db, snapshotDBName)
+		return err
+	}
+	return nil
 }
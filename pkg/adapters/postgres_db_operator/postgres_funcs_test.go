@@ -1,12 +1,17 @@
 package postgres_db_operator
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"ghostal/pkg/definitions"
 	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
+	"time"
 )
 
 func listAllDatabases(db *sql.DB) ([]string, error) {
@@ -41,7 +46,7 @@ func TestIntegration_PostgresBackup(t *testing.T) {
 	parsedURL, err := ParsePostgresURL(dbURL)
 	assert.NoError(t, err)
 
-	postgresClient, cleanupConnection, err := createPostgresConnection(parsedURL, true)
+	postgresClient, cleanupConnection, err := createPostgresConnection(context.Background(), parsedURL, true)
 	assert.NoError(t, err)
 	defer cleanupConnection()
 
@@ -50,8 +55,8 @@ func TestIntegration_PostgresBackup(t *testing.T) {
 
 	// attempt destructive operation with backup
 	didAttemptDrop := false
-	err = backupDB(postgresClient, parsedURL.DBName(), func() error {
-		if err := dropDB(postgresClient, parsedURL.DBName()); err != nil {
+	err = backupDB(context.Background(), postgresClient, parsedURL.DBName(), func(phase string) {}, func() error {
+		if err := dropDB(context.Background(), postgresClient, parsedURL.DBName()); err != nil {
 			panic(err)
 		}
 		didAttemptDrop = true
@@ -75,3 +80,87 @@ func TestIntegration_PostgresBackup(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 5, len(items))
 }
+
+func TestIntegration_PostgresRecovery(t *testing.T) {
+	dbURL, cleanupContainer := createPostgresContainer("gho_db", "gho_user", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParsePostgresURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	dbName := parsedURL.DBName()
+	backupDBName := buildBackupDBName(dbName)
+
+	postgresClient, cleanupConnection, err := createPostgresConnection(ctx, parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	WritePostgresSeedData(dbURL, "vehicles")
+
+	// nothing was interrupted
+	backup, err := findEmergencyBackup(ctx, postgresClient, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+	assert.ErrorIs(t, rollBackEmergencyBackup(ctx, postgresClient, dbName), values.NothingToRecoverErr)
+
+	// killed after backing up the database
+	assert.NoError(t, renameDB(ctx, postgresClient, dbName, backupDBName))
+	backup, err = findEmergencyBackup(ctx, postgresClient, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupDBName, Complete: true, DBExists: false}, backup)
+	assert.Error(t, rollForwardEmergencyBackup(ctx, postgresClient, dbName), "the backup is all that is left")
+	assert.NoError(t, rollBackEmergencyBackup(ctx, postgresClient, dbName))
+	assert.Len(t, PostgresRunQuery(dbURL, "SELECT * FROM vehicles"), 5)
+
+	// killed after copying the snapshot, before dropping the backup
+	assert.NoError(t, renameDB(ctx, postgresClient, dbName, backupDBName))
+	assert.NoError(t, createTemplateDB(ctx, postgresClient, dbName, backupDBName, parsedURL.Username()))
+	WritePostgresSeedData(dbURL, "planets")
+	backup, err = findEmergencyBackup(ctx, postgresClient, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupDBName, Complete: true, DBExists: true}, backup)
+	assert.NoError(t, rollForwardEmergencyBackup(ctx, postgresClient, dbName))
+	assert.Len(t, PostgresRunQuery(dbURL, "SELECT * FROM planets"), 5)
+	backup, err = findEmergencyBackup(ctx, postgresClient, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+
+	// same, rolled back this time
+	assert.NoError(t, renameDB(ctx, postgresClient, dbName, backupDBName))
+	assert.NoError(t, createTemplateDB(ctx, postgresClient, dbName, backupDBName, parsedURL.Username()))
+	PostgresRunQuery(dbURL, "DROP TABLE planets")
+	assert.NoError(t, rollBackEmergencyBackup(ctx, postgresClient, dbName))
+	assert.Len(t, PostgresRunQuery(dbURL, "SELECT * FROM planets"), 5)
+}
+
+func TestIntegration_PostgresListSnapshots_UnreadableName(t *testing.T) {
+	dbURL, cleanupContainer := createPostgresContainer("gho_db", "gho_user", "gho_pass")
+	defer cleanupContainer()
+
+	parsedURL, err := ParsePostgresURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	dbName := parsedURL.DBName()
+
+	postgresClient, cleanupConnection, err := createPostgresConnection(ctx, parsedURL, true)
+	assert.NoError(t, err)
+	defer cleanupConnection()
+
+	WritePostgresSeedData(dbURL, "vehicles")
+	assert.NoError(t, snapshotDB(ctx, postgresClient, dbName, parsedURL.Username(), "v1", func(phase string) {}))
+
+	// killed between creating a shortened snapshot database and storing its comment
+	hashedDBName, comment, err := buildSnapshotDBName(dbName, strings.Repeat("long-name_", 10), time.Now())
+	assert.NoError(t, err)
+	assert.NotNil(t, comment)
+	assert.NoError(t, createTemplateDB(ctx, postgresClient, hashedDBName, dbName, parsedURL.Username()))
+
+	skipped := make([]string, 0)
+	list, err := listSnapshots(ctx, postgresClient, dbName, func(dbName string, err error) {
+		skipped = append(skipped, dbName)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "v1", list[0].SnapshotName)
+	assert.Equal(t, []string{hashedDBName}, skipped)
+}
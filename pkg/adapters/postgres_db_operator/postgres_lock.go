@@ -0,0 +1,101 @@
+package postgres_db_operator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockNamespace prefixes the database names hashed into lock keys, so that they are unlikely to match the keys of the app
+const lockNamespace = "ghostal:"
+
+// lockAppPrefix starts the application_name of the session holding the lock, followed by the pid and host of gho
+const lockAppPrefix = "gho:"
+
+// lockKey is the bigint key of the advisory lock of `dbName`, a 64 bit hash so that two databases practically never share it
+func lockKey(dbName string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(lockNamespace + dbName))
+	return int64(hash.Sum64())
+}
+
+// findLockHolder reads the info of the gho process holding the lock from the application_name of its session
+func findLockHolder(ctx context.Context, conn *sql.Conn, dbName string) (definitions.LockInfo, error) {
+	// locks taken with a bigint key have objsubid 1, with its high half in classid and its low half in objid
+	query := `
+		SELECT a.application_name, a.backend_start
+		FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted AND l.classid = $1 AND l.objid = $2 AND l.objsubid = 1
+	`
+	key := uint64(lockKey(dbName))
+	var appName string
+	var since time.Time
+	err := conn.QueryRowContext(ctx, query, int64(key>>32), int64(key&0xffffffff)).Scan(&appName, &since)
+	if errors.Is(err, sql.ErrNoRows) {
+		// released in the meantime
+		return definitions.LockInfo{}, nil
+	}
+	if err != nil {
+		return definitions.LockInfo{}, fmt.Errorf("failed to look up the lock holder: %w", err)
+	}
+	info := definitions.LockInfo{Since: since}
+	parts := strings.SplitN(strings.TrimPrefix(appName, lockAppPrefix), ":", 2)
+	if pid, err := strconv.Atoi(parts[0]); err == nil {
+		info.PID = pid
+	}
+	if len(parts) > 1 {
+		info.Host = parts[1]
+	}
+	return info, nil
+}
+
+// lockDB takes an advisory lock on `dbName` on a session of its own, which must stay open as long as the lock is held.
+// The session is on the maintenance database, so that terminating the connections to `dbName` doesn't release it.
+func lockDB(ctx context.Context, db *sql.DB, dbName string, wait bool) (func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock session: %w", err)
+	}
+	self := definitions.NewLockInfo()
+	appName := fmt.Sprintf("%s%d:%s", lockAppPrefix, self.PID, self.Host)
+	if len(appName) > maxIdentifierLength {
+		appName = appName[:maxIdentifierLength]
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT set_config('application_name', $1, false)", appName); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to name lock session: %w", err)
+	}
+
+	err = utils.Poll(ctx, values.LockPollInterval, func() (bool, error) {
+		var acquired bool
+		query := "SELECT pg_try_advisory_lock($1)"
+		if err := conn.QueryRowContext(ctx, query, lockKey(dbName)).Scan(&acquired); err != nil {
+			return false, fmt.Errorf("failed to lock database: %w", err)
+		}
+		if acquired || wait {
+			return acquired, nil
+		}
+		holder, err := findLockHolder(ctx, conn, dbName)
+		if err != nil {
+			return false, err
+		}
+		return false, holder.LockedErr(fmt.Sprintf("database \"%s\"", dbName))
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return func() {
+		// closing the session would release the lock too, unlocking first keeps it from lingering in the pool
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey(dbName))
+		_ = conn.Close()
+	}, nil
+}
@@ -0,0 +1,42 @@
+package postgres_db_operator
+
+import (
+	"context"
+	"fmt"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIntegration_PostgresLock(t *testing.T) {
+	dbURL, cleanupContainer := createPostgresContainer("gho_db", "gho_user", "gho_pass")
+	defer cleanupContainer()
+
+	operator, err := CreatePostgresDBOperator(dbURL)
+	assert.NoError(t, err)
+	other, err := CreatePostgresDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	_, unlock, err := operator.Lock(context.Background(), false)
+	assert.NoError(t, err)
+	_, _, err = other.Lock(context.Background(), false)
+	assert.ErrorIs(t, err, values.LockedErr)
+	assert.Contains(t, err.Error(), fmt.Sprintf("database \"gho_db\" is locked by pid %d", os.Getpid()))
+
+	// the lock survives the connections to the database being terminated by a restore
+	WritePostgresSeedData(dbURL, "vehicles")
+	assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+	assert.NoError(t, operator.Restore(context.Background(), "v1", false))
+	_, _, err = other.Lock(context.Background(), false)
+	assert.ErrorIs(t, err, values.LockedErr)
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		unlock()
+	}()
+	_, unlockOther, err := other.Lock(context.Background(), true)
+	assert.NoError(t, err)
+	unlockOther()
+}
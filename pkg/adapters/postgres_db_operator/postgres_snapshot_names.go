@@ -0,0 +1,89 @@
+package postgres_db_operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"github.com/lib/pq"
+	"time"
+)
+
+// maxIdentifierLength is the number of bytes Postgres keeps of an identifier (NAMEDATALEN - 1),
+// anything longer is silently truncated
+const maxIdentifierLength = 63
+
+const backupDBPrefix = "temp_emergency_backup_"
+
+// snapshotComment is stored as the comment of a snapshot database whose full name doesn't fit in an identifier
+type snapshotComment struct {
+	SourceDBName string `json:"sourceDBName"`
+	SnapshotName string `json:"snapshotName"`
+	// CreatedAt is in unix milliseconds, like the timestamp of regular snapshot names
+	CreatedAt int64 `json:"createdAt"`
+}
+
+// shortIdentifier derives a stable identifier from `name` that fits in the identifier length limit
+func shortIdentifier(prefix, name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return prefix + hex.EncodeToString(sum[:])[:32]
+}
+
+// buildSnapshotDBName returns the name of the snapshot database, along with the comment to store on it
+// when the name had to be shortened
+func buildSnapshotDBName(sourceDBName, snapshotName string, timestamp time.Time) (string, *snapshotComment, error) {
+	fullName, err := utils.BuildSnapshotDBName(sourceDBName, snapshotName, timestamp)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(fullName) <= maxIdentifierLength {
+		return fullName, nil, nil
+	}
+	return shortIdentifier(values.SnapshotDBPrefix, fullName), &snapshotComment{
+		SourceDBName: sourceDBName,
+		SnapshotName: snapshotName,
+		CreatedAt:    timestamp.UnixMilli(),
+	}, nil
+}
+
+// parseSnapshotDBName reads the snapshot info from the database comment if there is one, or else from the name
+func parseSnapshotDBName(dbName, comment string) (utils.SnapshotDBNameParts, error) {
+	if comment != "" {
+		var c snapshotComment
+		if err := json.Unmarshal([]byte(comment), &c); err == nil && c.SnapshotName != "" {
+			return utils.SnapshotDBNameParts{
+				SourceDBName: c.SourceDBName,
+				SnapshotName: c.SnapshotName,
+				Timestamp:    time.UnixMilli(c.CreatedAt),
+			}, nil
+		}
+	}
+	return utils.ParseSnapshotDBName(dbName)
+}
+
+func buildBackupDBName(sourceDBName string) string {
+	name := backupDBPrefix + sourceDBName
+	if len(name) <= maxIdentifierLength {
+		return name
+	}
+	return shortIdentifier(backupDBPrefix, sourceDBName)
+}
+
+func commentOnDB(ctx context.Context, db *sql.DB, dbName string, comment *snapshotComment) error {
+	if comment == nil {
+		return nil
+	}
+	data, err := json.Marshal(comment)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("COMMENT ON DATABASE %s IS %s", pq.QuoteIdentifier(dbName), pq.QuoteLiteral(string(data)))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to store snapshot info: %w", err)
+	}
+	return nil
+}
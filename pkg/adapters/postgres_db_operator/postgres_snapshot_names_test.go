@@ -0,0 +1,56 @@
+package postgres_db_operator
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnit_BuildSnapshotDBName_Short(t *testing.T) {
+	timestamp := time.UnixMilli(1712976085060)
+	dbName, comment, err := buildSnapshotDBName("mydb", "v1", timestamp)
+	assert.NoError(t, err)
+	assert.Nil(t, comment)
+	assert.Equal(t, "ghostalsnapshot_mydb_v1_1712976085060", dbName)
+
+	parts, err := parseSnapshotDBName(dbName, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "mydb", parts.SourceDBName)
+	assert.Equal(t, "v1", parts.SnapshotName)
+}
+
+func TestUnit_BuildSnapshotDBName_Long(t *testing.T) {
+	timestamp := time.UnixMilli(1712976085060)
+	sourceDBName := strings.Repeat("inventory_", 5)
+	snapshotName := "before-migration_20240412_add_users_table"
+	dbName, comment, err := buildSnapshotDBName(sourceDBName, snapshotName, timestamp)
+	assert.NoError(t, err)
+	assert.NotNil(t, comment)
+	assert.LessOrEqual(t, len(dbName), maxIdentifierLength)
+	assert.True(t, strings.HasPrefix(dbName, "ghostalsnapshot_"))
+
+	// stable
+	sameDBName, _, err := buildSnapshotDBName(sourceDBName, snapshotName, timestamp)
+	assert.NoError(t, err)
+	assert.Equal(t, dbName, sameDBName)
+
+	data, err := json.Marshal(comment)
+	assert.NoError(t, err)
+	parts, err := parseSnapshotDBName(dbName, string(data))
+	assert.NoError(t, err)
+	assert.Equal(t, sourceDBName, parts.SourceDBName)
+	assert.Equal(t, snapshotName, parts.SnapshotName)
+	assert.Equal(t, timestamp, parts.Timestamp)
+
+	_, err = parseSnapshotDBName(dbName, "")
+	assert.Error(t, err, "shortened name cannot be parsed without the comment")
+}
+
+func TestUnit_BackupDBName(t *testing.T) {
+	assert.Equal(t, "temp_emergency_backup_mydb", buildBackupDBName("mydb"))
+	longName := buildBackupDBName(strings.Repeat("inventory_", 6))
+	assert.LessOrEqual(t, len(longName), maxIdentifierLength)
+	assert.True(t, strings.HasPrefix(longName, "temp_emergency_backup_"))
+}
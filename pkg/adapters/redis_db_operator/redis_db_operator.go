@@ -0,0 +1,197 @@
+package redis_db_operator
+
+import (
+	"context"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisDBOperator struct {
+	redisURL        *RedisURL
+	dbIndex         int
+	snapshotDBIndex int
+	logger          definitions.ILogger
+}
+
+func CreateRedisDBOperator(dbURL string) (*RedisDBOperator, error) {
+	redisURL, err := ParseRedisURL(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	if redisURL.dbURL.Scheme != "redis" && redisURL.dbURL.Scheme != "rediss" {
+		return nil, values.UnsupportedURLSchemeError
+	}
+	dbIndex, err := redisURL.DBIndex()
+	if err != nil {
+		return nil, err
+	}
+	snapshotDBIndex, err := redisURL.SnapshotDBIndex()
+	if err != nil {
+		return nil, err
+	}
+	if dbIndex == snapshotDBIndex {
+		return nil, fmt.Errorf("the snapshot database must differ from the source database, set \"%s\" to another index", SnapshotDBParam)
+	}
+	return &RedisDBOperator{
+		redisURL:        redisURL,
+		dbIndex:         dbIndex,
+		snapshotDBIndex: snapshotDBIndex,
+	}, nil
+}
+
+func (r *RedisDBOperator) SetLogger(logger definitions.ILogger) {
+	r.logger = logger
+}
+
+func (r *RedisDBOperator) warnSkippedKey(storeKey string, err error) {
+	if r.logger != nil {
+		r.logger.Warning("skipped key \"%s\", it looks like a snapshot but can't be read: %s", storeKey, err)
+	}
+}
+
+// connect returns clients for both the source database and the database holding the snapshots
+func (r *RedisDBOperator) connect(ctx context.Context) (*redis.Client, *redis.Client, func(), error) {
+	source, closeSource, err := createRedisConnection(ctx, r.redisURL, r.dbIndex)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	store, closeStore, err := createRedisConnection(ctx, r.redisURL, r.snapshotDBIndex)
+	if err != nil {
+		closeSource()
+		return nil, nil, nil, err
+	}
+	return source, store, func() {
+		closeSource()
+		closeStore()
+	}, nil
+}
+
+func (r *RedisDBOperator) checkSnapshotName(ctx context.Context, snapshotName string) error {
+	list, err := r.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = utils.Find(list, func(item definitions.SnapshotListResult) bool {
+		return item.SnapshotName == snapshotName
+	})
+	if err == nil {
+		// item found
+		return values.SnapshotNameTakenErr
+	}
+	return nil
+}
+
+func (r *RedisDBOperator) Snapshot(ctx context.Context, snapshotName string) error {
+	if err := r.checkSnapshotName(ctx, snapshotName); err != nil {
+		return err
+	}
+	source, store, close, err := r.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+	return snapshotDB(ctx, source, store, r.redisURL.DBName(), snapshotName)
+}
+
+// ReplaceSnapshot takes the new snapshot before deleting the previous one, so that a failure never loses both
+func (r *RedisDBOperator) ReplaceSnapshot(ctx context.Context, snapshotName string) error {
+	source, store, close, err := r.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+	list, err := listSnapshots(ctx, store, r.redisURL.DBName(), r.warnSkippedKey)
+	if err != nil {
+		return err
+	}
+	if err := snapshotDB(ctx, source, store, r.redisURL.DBName(), snapshotName); err != nil {
+		return err
+	}
+	for _, item := range list.Named(snapshotName) {
+		if err := store.Del(ctx, item.DBName).Err(); err != nil {
+			return fmt.Errorf("failed to delete the previous snapshot \"%s\": %w", snapshotName, err)
+		}
+	}
+	return nil
+}
+
+func (r *RedisDBOperator) Restore(ctx context.Context, snapshotName string, fast bool) error {
+	source, store, close, err := r.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+
+	list, err := listSnapshots(ctx, store, r.redisURL.DBName(), r.warnSkippedKey)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			return restoreDB(ctx, source, store, r.redisURL.DBName(), item.DBName, fast)
+		}
+	}
+	return values.SnapshotNotExistsErr
+}
+
+func (r *RedisDBOperator) Delete(ctx context.Context, snapshotName string) error {
+	_, store, close, err := r.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+
+	list, err := listSnapshots(ctx, store, r.redisURL.DBName(), r.warnSkippedKey)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			return store.Del(ctx, item.DBName).Err()
+		}
+	}
+	return values.SnapshotNotExistsErr
+}
+
+func (r *RedisDBOperator) FindEmergencyBackup(ctx context.Context) (*definitions.EmergencyBackup, error) {
+	_, store, close, err := r.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer close()
+
+	return findEmergencyBackup(ctx, store, r.redisURL.DBName())
+}
+
+func (r *RedisDBOperator) RollBack(ctx context.Context) error {
+	source, store, close, err := r.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	return rollBackEmergencyBackup(ctx, source, store, r.redisURL.DBName())
+}
+
+func (r *RedisDBOperator) RollForward(ctx context.Context) error {
+	_, store, close, err := r.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	return rollForwardEmergencyBackup(ctx, store, r.redisURL.DBName())
+}
+
+func (r *RedisDBOperator) ListSnapshots(ctx context.Context) (definitions.SnapshotList, error) {
+	_, store, close, err := r.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer close()
+
+	return listSnapshots(ctx, store, r.redisURL.DBName(), r.warnSkippedKey)
+}
@@ -0,0 +1,15 @@
+package redis_db_operator
+
+import (
+	"ghostal/pkg/definitions"
+)
+
+type RedisDBOperatorBuilder struct{}
+
+func (p *RedisDBOperatorBuilder) ID() string {
+	return "Redis"
+}
+
+func (p *RedisDBOperatorBuilder) BuildOperator(dbURL string) (definitions.IDBOperator, error) {
+	return CreateRedisDBOperator(dbURL)
+}
@@ -0,0 +1,162 @@
+package redis_db_operator
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"testing"
+	"time"
+)
+
+const DBName = "0"
+const DBPort = "6379"
+
+func createRedisContainer(dbName string) (string, func()) {
+	ctx := context.Background()
+	req := testcontainers.ContainerRequest{
+		Image:        "redis:7.2.4-alpine",
+		ExposedPorts: []string{DBPort + "/tcp"},
+		WaitingFor:   wait.ForListeningPort(DBPort + "/tcp"),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		panic(fmt.Errorf("failed to start container: %s", err))
+	}
+
+	host, err := container.Host(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	mappedPort, err := container.MappedPort(context.Background(), DBPort)
+	if err != nil {
+		panic(err)
+	}
+
+	dbURL := fmt.Sprintf("redis://%s:%s/%s", host, mappedPort.Port(), dbName)
+	return dbURL, func() {
+		_ = container.Terminate(ctx)
+	}
+}
+
+func getNumVehicles(dbURL string) int {
+	client, cleanup := GetRedisClient(dbURL)
+	defer cleanup()
+	keys, err := client.Keys(context.Background(), "vehicles:[0-9]").Result()
+	if err != nil {
+		panic(err)
+	}
+	return len(keys)
+}
+
+func TestIntegration_RedisDBOperator_Lifecycle(t *testing.T) {
+	dbURL, cleanup := createRedisContainer(DBName)
+	defer cleanup()
+
+	operator, err := CreateRedisDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	WriteRedisSeedData(dbURL, "vehicles")
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	{
+		assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 1)
+	}
+
+	{
+		assert.NoError(t, operator.Snapshot(context.Background(), "v2"))
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 2)
+	}
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	{
+		// modify DB before restoring snapshot
+		client, cleanup := GetRedisClient(dbURL)
+		defer cleanup()
+		assert.NoError(t, client.Del(context.Background(), "vehicles:3", "vehicles:5", "vehicles:2").Err())
+		assert.NoError(t, client.Set(context.Background(), "vehicles:count", 2, 0).Err())
+	}
+
+	assert.Equal(t, 2, getNumVehicles(dbURL))
+
+	{
+		err := operator.Restore(context.Background(), "v1", false)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	{
+		// types and expiry should survive the round-trip
+		client, cleanup := GetRedisClient(dbURL)
+		defer cleanup()
+		ctx := context.Background()
+		count, err := client.Get(ctx, "vehicles:count").Int()
+		assert.NoError(t, err)
+		assert.Equal(t, 5, count)
+		model, err := client.HGet(ctx, "vehicles:4", "model").Result()
+		assert.NoError(t, err)
+		assert.Equal(t, "Model 3", model)
+		queueLength, err := client.LLen(ctx, "vehicles:queue").Result()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 5, queueLength)
+		members, err := client.SCard(ctx, "vehicles:colors").Result()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 5, members)
+		newest, err := client.ZRevRange(ctx, "vehicles:by_year", 0, 0).Result()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"4"}, newest)
+		ttl, err := client.TTL(ctx, "vehicles:session").Result()
+		assert.NoError(t, err)
+		assert.Greater(t, ttl, 59*time.Minute)
+		ttl, err = client.TTL(ctx, "vehicles:count").Result()
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(-1), ttl)
+	}
+
+	{
+		// modify DB before restoring snapshot (fast)
+		client, cleanup := GetRedisClient(dbURL)
+		defer cleanup()
+		assert.NoError(t, client.FlushDB(context.Background()).Err())
+	}
+
+	assert.Equal(t, 0, getNumVehicles(dbURL))
+
+	{
+		err := operator.Restore(context.Background(), "v2", true)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+
+	{
+		err := operator.Delete(context.Background(), "v2")
+		assert.NoError(t, err)
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 1)
+		assert.Equal(t, "v1", allDatabases[0].SnapshotName)
+	}
+}
@@ -0,0 +1,293 @@
+package redis_db_operator
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+// every key is stored as a field of the snapshot hash, prefixed so that it cannot collide with `metaField`
+const keyFieldPrefix = "k:"
+
+// metaField guarantees that the snapshot hash exists even if the source database was empty
+const metaField = "meta"
+
+const scanBatchSize = 500
+
+const backupKeyPrefix = "temp_emergency_backup_"
+
+func createRedisConnection(ctx context.Context, redisURL *RedisURL, dbIndex int) (*redis.Client, func(), error) {
+	options, err := redisURL.Options(dbIndex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse redis url: %w", err)
+	}
+	client := redis.NewClient(options)
+	// Attempt to ping the database to ensure connection is alive
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		sanitizedDBURL, _ := utils.SanitizeDBURL(redisURL.dbURL.String())
+		return nil, nil, fmt.Errorf("failed to connect to database (%s): %w", sanitizedDBURL, err)
+	}
+	return client, func() {
+		_ = client.Close()
+	}, nil
+}
+
+// encodeEntry packs the remaining time-to-live in front of the DUMP payload, which already encodes the value type
+func encodeEntry(ttl time.Duration, payload string) string {
+	buf := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint64(buf, uint64(ttl.Milliseconds()))
+	copy(buf[8:], payload)
+	return string(buf)
+}
+
+func decodeEntry(entry string) (time.Duration, string, error) {
+	if len(entry) < 8 {
+		return 0, "", errors.New("snapshot entry is corrupted")
+	}
+	ttl := time.Duration(binary.BigEndian.Uint64([]byte(entry[:8]))) * time.Millisecond
+	return ttl, entry[8:], nil
+}
+
+func dumpKeys(ctx context.Context, source, store *redis.Client, storeKey string, keys []string) error {
+	pipe := source.Pipeline()
+	dumps := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for idx, key := range keys {
+		dumps[idx] = pipe.Dump(ctx, key)
+		ttls[idx] = pipe.PTTL(ctx, key)
+	}
+	// errors are checked per command, since a key may legitimately expire between SCAN and DUMP
+	_, _ = pipe.Exec(ctx)
+
+	fields := make([]interface{}, 0, len(keys)*2)
+	for idx, key := range keys {
+		payload, err := dumps[idx].Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to dump key %s: %w", key, err)
+		}
+		ttl, err := ttls[idx].Result()
+		if err != nil {
+			return fmt.Errorf("failed to read ttl of key %s: %w", key, err)
+		}
+		if ttl < 0 {
+			// no expiry
+			ttl = 0
+		}
+		fields = append(fields, keyFieldPrefix+key, encodeEntry(ttl, payload))
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return store.HSet(ctx, storeKey, fields...).Err()
+}
+
+// dumpDB serializes every key of `source` into the hash `storeKey` of `store`
+func dumpDB(ctx context.Context, source, store *redis.Client, storeKey string) error {
+	// build the snapshot under a temporary key so that a partial snapshot is never listed
+	tempKey := "temp_" + storeKey
+	if err := store.Del(ctx, tempKey).Err(); err != nil {
+		return err
+	}
+	if err := store.HSet(ctx, tempKey, metaField, time.Now().UnixMilli()).Err(); err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	batch := make([]string, 0, scanBatchSize)
+	iter := source.Scan(ctx, 0, "", scanBatchSize).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) < scanBatchSize {
+			continue
+		}
+		if err := dumpKeys(ctx, source, store, tempKey, batch); err != nil {
+			_ = store.Del(context.WithoutCancel(ctx), tempKey)
+			return err
+		}
+		batch = batch[:0]
+	}
+	if err := iter.Err(); err != nil {
+		_ = store.Del(context.WithoutCancel(ctx), tempKey)
+		return fmt.Errorf("failed to scan keys: %w", err)
+	}
+	if err := dumpKeys(ctx, source, store, tempKey, batch); err != nil {
+		_ = store.Del(context.WithoutCancel(ctx), tempKey)
+		return err
+	}
+
+	if err := store.Rename(ctx, tempKey, storeKey).Err(); err != nil {
+		_ = store.Del(context.WithoutCancel(ctx), tempKey)
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return nil
+}
+
+// loadDB replaces the contents of `target` with the keys stored in the hash `storeKey` of `store`
+func loadDB(ctx context.Context, target, store *redis.Client, storeKey string) error {
+	exists, err := store.Exists(ctx, storeKey).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return fmt.Errorf("snapshot data %s is missing", storeKey)
+	}
+	if err := dropDB(ctx, target); err != nil {
+		return fmt.Errorf("failed to drop original: %w", err)
+	}
+
+	pipe := target.Pipeline()
+	iter := store.HScan(ctx, storeKey, 0, keyFieldPrefix+"*", scanBatchSize).Iterator()
+	for iter.Next(ctx) {
+		field := iter.Val()
+		if !iter.Next(ctx) {
+			break
+		}
+		ttl, payload, err := decodeEntry(iter.Val())
+		if err != nil {
+			return err
+		}
+		pipe.RestoreReplace(ctx, strings.TrimPrefix(field, keyFieldPrefix), ttl, payload)
+		if pipe.Len() < scanBatchSize {
+			continue
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to restore keys: %w", err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan snapshot: %w", err)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to restore keys: %w", err)
+	}
+	return nil
+}
+
+func dropDB(ctx context.Context, db *redis.Client) error {
+	return db.FlushDB(ctx).Err()
+}
+
+func listSnapshots(ctx context.Context, store *redis.Client, sourceDBName string, onSkip func(storeKey string, err error)) (definitions.SnapshotList, error) {
+	list := make(definitions.SnapshotList, 0)
+	iter := store.Scan(ctx, 0, values.SnapshotDBPrefix+"*", scanBatchSize).Iterator()
+	for iter.Next(ctx) {
+		storeKey := iter.Val()
+		snapshotDBNameParts, err := utils.ParseSnapshotDBName(storeKey)
+		if err != nil {
+			// the snapshot database is shared by every project on the server, one key must not hide the others
+			onSkip(storeKey, err)
+			continue
+		}
+		if snapshotDBNameParts.SourceDBName != sourceDBName {
+			continue
+		}
+		sizeBytes, err := store.MemoryUsage(ctx, storeKey).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to read snapshot size: %w", err)
+		}
+		list = append(list, definitions.SnapshotListResult{
+			SnapshotName: snapshotDBNameParts.SnapshotName,
+			DBName:       storeKey,
+			CreatedAt:    snapshotDBNameParts.Timestamp,
+			SizeBytes:    sizeBytes,
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	return list, nil
+}
+
+// backupDB backs up `source` and restores it if `fn` fails, including when `ctx` is cancelled
+func backupDB(ctx context.Context, source, store *redis.Client, sourceDBName string, fn func() error) error {
+	backupKey := backupKeyPrefix + sourceDBName
+	// the backup key only appears once the dump is complete, a partial dump is left under a temporary key
+	if err := dumpDB(ctx, source, store, backupKey); err != nil {
+		return fmt.Errorf("failed to dump original to backup: %w", err)
+	}
+	if err := fn(); err != nil {
+		// if error, load backup back into source, even if cancelled
+		rollbackCtx := context.WithoutCancel(ctx)
+		_ = loadDB(rollbackCtx, source, store, backupKey)
+		// after emergency restore, drop backup
+		_ = store.Del(rollbackCtx, backupKey)
+		return err
+	}
+	// is success, drop backup
+	_ = store.Del(ctx, backupKey)
+	return nil
+}
+
+// findEmergencyBackup returns the backup left behind by a restore of `sourceDBName` that was killed, nil if there is none
+func findEmergencyBackup(ctx context.Context, store *redis.Client, sourceDBName string) (*definitions.EmergencyBackup, error) {
+	backupKey := backupKeyPrefix + sourceDBName
+	exists, err := store.Exists(ctx, backupKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up backup: %w", err)
+	}
+	if exists == 0 {
+		return nil, nil
+	}
+	// the backup is renamed into place once dumped, so it is never partial, and a Redis database always exists
+	return &definitions.EmergencyBackup{
+		Name:     backupKey,
+		Complete: true,
+		DBExists: true,
+	}, nil
+}
+
+// rollBackEmergencyBackup replaces the keys of `source` by its emergency backup, the backup is only dropped once loaded,
+// so it can be run again if interrupted
+func rollBackEmergencyBackup(ctx context.Context, source, store *redis.Client, sourceDBName string) error {
+	backup, err := findEmergencyBackup(ctx, store, sourceDBName)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	if err := loadDB(ctx, source, store, backup.Name); err != nil {
+		return fmt.Errorf("failed to load backup into original: %w", err)
+	}
+	return store.Del(ctx, backup.Name).Err()
+}
+
+// rollForwardEmergencyBackup keeps the keys of `source` and drops its emergency backup
+func rollForwardEmergencyBackup(ctx context.Context, store *redis.Client, sourceDBName string) error {
+	backup, err := findEmergencyBackup(ctx, store, sourceDBName)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	return store.Del(ctx, backup.Name).Err()
+}
+
+func restoreDB(ctx context.Context, source, store *redis.Client, sourceDBName, snapshotKey string, fast bool) error {
+	if fast {
+		return loadDB(ctx, source, store, snapshotKey)
+	}
+
+	return backupDB(ctx, source, store, sourceDBName, func() error {
+		return loadDB(ctx, source, store, snapshotKey)
+	})
+}
+
+func snapshotDB(ctx context.Context, source, store *redis.Client, sourceDBName, snapshotName string) error {
+	snapshotKey, err := utils.BuildSnapshotDBName(sourceDBName, snapshotName, time.Now())
+	if err != nil {
+		return err
+	}
+	return dumpDB(ctx, source, store, snapshotKey)
+}
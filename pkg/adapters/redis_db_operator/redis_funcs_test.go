@@ -0,0 +1,143 @@
+package redis_db_operator
+
+import (
+	"context"
+	"errors"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIntegration_RedisBackup(t *testing.T) {
+	dbURL, cleanupContainer := createRedisContainer("2")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseRedisURL(dbURL)
+	assert.NoError(t, err)
+
+	source, cleanupSource, err := createRedisConnection(context.Background(), parsedURL, 2)
+	assert.NoError(t, err)
+	defer cleanupSource()
+
+	store, cleanupStore, err := createRedisConnection(context.Background(), parsedURL, DefaultSnapshotDB)
+	assert.NoError(t, err)
+	defer cleanupStore()
+
+	// write data to DB
+	WriteRedisSeedData(dbURL, "vehicles")
+
+	// attempt destructive operation with backup
+	didAttemptDrop := false
+	err = backupDB(context.Background(), source, store, parsedURL.DBName(), func() error {
+		if err := dropDB(context.Background(), source); err != nil {
+			panic(err)
+		}
+		didAttemptDrop = true
+		return errors.New("test err")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "test err", err.Error())
+	assert.True(t, didAttemptDrop)
+
+	// verify that original DB is intact and the backup was cleaned up
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+	numStoredKeys, err := store.DBSize(context.Background()).Result()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, numStoredKeys)
+}
+
+func TestIntegration_RedisRecovery(t *testing.T) {
+	dbURL, cleanupContainer := createRedisContainer("2")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseRedisURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	dbName := parsedURL.DBName()
+	backupKey := backupKeyPrefix + dbName
+
+	source, cleanupSource, err := createRedisConnection(ctx, parsedURL, 2)
+	assert.NoError(t, err)
+	defer cleanupSource()
+
+	store, cleanupStore, err := createRedisConnection(ctx, parsedURL, DefaultSnapshotDB)
+	assert.NoError(t, err)
+	defer cleanupStore()
+
+	WriteRedisSeedData(dbURL, "vehicles")
+	countKeys := func(pattern string) int {
+		keys, err := source.Keys(ctx, pattern).Result()
+		assert.NoError(t, err)
+		return len(keys)
+	}
+
+	// nothing was interrupted
+	backup, err := findEmergencyBackup(ctx, store, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+	assert.ErrorIs(t, rollBackEmergencyBackup(ctx, source, store, dbName), values.NothingToRecoverErr)
+
+	// killed while backing up the database, the partial dump isn't a backup yet
+	assert.NoError(t, store.HSet(ctx, "temp_"+backupKey, metaField, time.Now().UnixMilli()).Err())
+	backup, err = findEmergencyBackup(ctx, store, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+
+	// killed while loading the snapshot
+	assert.NoError(t, dumpDB(ctx, source, store, backupKey))
+	assert.NoError(t, dropDB(ctx, source))
+	WriteRedisSeedData(dbURL, "planets")
+	backup, err = findEmergencyBackup(ctx, store, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: backupKey, Complete: true, DBExists: true}, backup)
+	assert.NoError(t, rollBackEmergencyBackup(ctx, source, store, dbName))
+	assert.Equal(t, 5, getNumVehicles(dbURL))
+	assert.Equal(t, 0, countKeys("planets:*"))
+
+	// killed before dropping the backup
+	assert.NoError(t, dumpDB(ctx, source, store, backupKey))
+	assert.NoError(t, dropDB(ctx, source))
+	WriteRedisSeedData(dbURL, "planets")
+	assert.NoError(t, rollForwardEmergencyBackup(ctx, store, dbName))
+	assert.Equal(t, 0, getNumVehicles(dbURL))
+	assert.Greater(t, countKeys("planets:*"), 0)
+	backup, err = findEmergencyBackup(ctx, store, dbName)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+}
+
+func TestIntegration_RedisListSnapshots_UnreadableName(t *testing.T) {
+	dbURL, cleanupContainer := createRedisContainer("2")
+	defer cleanupContainer()
+
+	parsedURL, err := ParseRedisURL(dbURL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	dbName := parsedURL.DBName()
+
+	source, cleanupSource, err := createRedisConnection(ctx, parsedURL, 2)
+	assert.NoError(t, err)
+	defer cleanupSource()
+
+	store, cleanupStore, err := createRedisConnection(ctx, parsedURL, DefaultSnapshotDB)
+	assert.NoError(t, err)
+	defer cleanupStore()
+
+	WriteRedisSeedData(dbURL, "vehicles")
+	assert.NoError(t, snapshotDB(ctx, source, store, dbName, "v1"))
+
+	// e.g. a snapshot key copied by hand
+	unreadableKey := values.SnapshotDBPrefix + "copy"
+	assert.NoError(t, store.Set(ctx, unreadableKey, "vehicles", 0).Err())
+
+	skipped := make([]string, 0)
+	list, err := listSnapshots(ctx, store, dbName, func(storeKey string, err error) {
+		skipped = append(skipped, storeKey)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "v1", list[0].SnapshotName)
+	assert.Equal(t, []string{unreadableKey}, skipped)
+}
@@ -0,0 +1,74 @@
+package redis_db_operator
+
+import (
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SnapshotDBParam selects the logical database in which ghostal stores snapshots
+const SnapshotDBParam = "ghostalSnapshotDB"
+const DefaultSnapshotDB = 15
+
+type RedisURL struct {
+	dbURL *url.URL
+}
+
+func (p *RedisURL) Clone() *url.URL {
+	clone := *p.dbURL
+	if p.dbURL.User != nil {
+		u := *p.dbURL.User
+		clone.User = &u
+	}
+	return &clone
+}
+
+func (p *RedisURL) DBName() string {
+	dbName := strings.TrimPrefix(p.dbURL.Path, "/")
+	if dbName == "" {
+		return "0"
+	}
+	return dbName
+}
+
+func (p *RedisURL) DBIndex() (int, error) {
+	dbIndex, err := strconv.Atoi(p.DBName())
+	if err != nil {
+		return 0, fmt.Errorf("invalid redis database index \"%s\"", p.DBName())
+	}
+	return dbIndex, nil
+}
+
+func (p *RedisURL) SnapshotDBIndex() (int, error) {
+	value := p.dbURL.Query().Get(SnapshotDBParam)
+	if value == "" {
+		return DefaultSnapshotDB, nil
+	}
+	dbIndex, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s \"%s\"", SnapshotDBParam, value)
+	}
+	return dbIndex, nil
+}
+
+// Options builds client options connected to the logical database `dbIndex`
+func (p *RedisURL) Options(dbIndex int) (*redis.Options, error) {
+	clone := p.Clone()
+	query := clone.Query()
+	query.Del(SnapshotDBParam)
+	clone.RawQuery = query.Encode()
+	clone.Path = "/" + strconv.Itoa(dbIndex)
+	return redis.ParseURL(clone.String())
+}
+
+func ParseRedisURL(dbURL string) (*RedisURL, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisURL{
+		dbURL: u,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package redis_db_operator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const ValidRedisURL = "redis://:pw@localhost:6379/3?ghostalSnapshotDB=12&dial_timeout=5s"
+
+func TestUnit_ParseRedisURL(t *testing.T) {
+	redisURL, err := ParseRedisURL(ValidRedisURL)
+	assert.NoError(t, err)
+	assert.NotNil(t, redisURL)
+
+	assert.Equal(t, "3", redisURL.DBName())
+	dbIndex, err := redisURL.DBIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, dbIndex)
+	snapshotDBIndex, err := redisURL.SnapshotDBIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, 12, snapshotDBIndex)
+}
+
+func TestUnit_RedisURL_Options(t *testing.T) {
+	redisURL, err := ParseRedisURL(ValidRedisURL)
+	assert.NoError(t, err)
+	options, err := redisURL.Options(12)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, options.DB)
+	assert.Equal(t, "pw", options.Password)
+	assert.Equal(t, "localhost:6379", options.Addr)
+}
+
+func TestUnit_RedisURL_DefaultDB(t *testing.T) {
+	redisURL, err := ParseRedisURL("redis://localhost:6379")
+	assert.NoError(t, err)
+	assert.Equal(t, "0", redisURL.DBName())
+	snapshotDBIndex, err := redisURL.SnapshotDBIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultSnapshotDB, snapshotDBIndex)
+}
+
+func TestUnit_CreateRedisDBOperator_Errors(t *testing.T) {
+	{
+		_, err := CreateRedisDBOperator("postgresql://admin:pw@localhost/main")
+		assert.Error(t, err)
+	}
+	{
+		_, err := CreateRedisDBOperator("redis://localhost/notanumber")
+		assert.Error(t, err)
+	}
+	{
+		_, err := CreateRedisDBOperator("redis://localhost/15")
+		assert.Error(t, err, "should reject using the snapshot database as the source")
+	}
+}
+
+func TestUnit_EncodeDecodeEntry(t *testing.T) {
+	encoded := encodeEntry(90000*1000*1000, "\x00payload")
+	ttl, payload, err := decodeEntry(encoded)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 90000*1000*1000, ttl)
+	assert.Equal(t, "\x00payload", payload)
+
+	_, _, err = decodeEntry("short")
+	assert.Error(t, err)
+}
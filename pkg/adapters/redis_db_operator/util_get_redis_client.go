@@ -0,0 +1,30 @@
+package redis_db_operator
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+)
+
+func GetRedisClient(dbURL string) (*redis.Client, func()) {
+	parsedURL, err := ParseRedisURL(dbURL)
+	if err != nil {
+		panic(err)
+	}
+	dbIndex, err := parsedURL.DBIndex()
+	if err != nil {
+		panic(err)
+	}
+	options, err := parsedURL.Options(dbIndex)
+	if err != nil {
+		panic(err)
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(context.TODO()).Err(); err != nil {
+		panic(err)
+	}
+
+	return client, func() {
+		_ = client.Close()
+	}
+}
@@ -0,0 +1,35 @@
+package redis_db_operator
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"time"
+)
+
+func WriteRedisSeedData(dbURL, keyPrefix string) {
+	client, cleanup := GetRedisClient(dbURL)
+	defer cleanup()
+
+	ctx := context.TODO()
+	pipe := client.Pipeline()
+	pipe.Set(ctx, keyPrefix+":count", 5, 0)
+	pipe.Set(ctx, keyPrefix+":session", "abc123", time.Hour)
+	pipe.HSet(ctx, keyPrefix+":1", "make", "Toyota", "model", "Camry", "year", 2022, "color", "Black")
+	pipe.HSet(ctx, keyPrefix+":2", "make", "Ford", "model", "Mustang", "year", 2021, "color", "Red")
+	pipe.HSet(ctx, keyPrefix+":3", "make", "Honda", "model", "Civic", "year", 2020, "color", "Blue")
+	pipe.HSet(ctx, keyPrefix+":4", "make", "Tesla", "model", "Model 3", "year", 2023, "color", "White")
+	pipe.HSet(ctx, keyPrefix+":5", "make", "Chevrolet", "model", "Impala", "year", 2019, "color", "Silver")
+	pipe.RPush(ctx, keyPrefix+":queue", 1, 2, 3, 4, 5)
+	pipe.SAdd(ctx, keyPrefix+":colors", "Black", "Red", "Blue", "White", "Silver")
+	pipe.ZAdd(ctx, keyPrefix+":by_year",
+		redis.Z{Score: 2022, Member: "1"},
+		redis.Z{Score: 2021, Member: "2"},
+		redis.Z{Score: 2020, Member: "3"},
+		redis.Z{Score: 2023, Member: "4"},
+		redis.Z{Score: 2019, Member: "5"},
+	)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
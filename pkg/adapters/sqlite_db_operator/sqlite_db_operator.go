@@ -0,0 +1,167 @@
+package sqlite_db_operator
+
+import (
+	"context"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"path/filepath"
+	"time"
+)
+
+type SQLiteDBOperator struct {
+	sqliteURL *SQLiteURL
+	logger    definitions.ILogger
+}
+
+func CreateSQLiteDBOperator(dbURL string) (*SQLiteDBOperator, error) {
+	sqliteURL, err := ParseSQLiteURL(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	if sqliteURL.dbURL.Scheme != "sqlite" && sqliteURL.dbURL.Scheme != "file" {
+		return nil, values.UnsupportedURLSchemeError
+	}
+	return &SQLiteDBOperator{
+		sqliteURL: sqliteURL,
+	}, nil
+}
+
+func (s *SQLiteDBOperator) SetLogger(logger definitions.ILogger) {
+	s.logger = logger
+}
+
+func (s *SQLiteDBOperator) warnSkippedFile(fileName string, err error) {
+	if s.logger != nil {
+		s.logger.Warning("skipped file \"%s\", it looks like a snapshot but can't be read: %s", fileName, err)
+	}
+}
+
+// SetBaseDir makes a relative path of the database file relative to `dir`
+func (s *SQLiteDBOperator) SetBaseDir(dir string) {
+	s.sqliteURL.baseDir = dir
+}
+
+func (s *SQLiteDBOperator) checkSnapshotName(ctx context.Context, snapshotName string) error {
+	list, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = utils.Find(list, func(item definitions.SnapshotListResult) bool {
+		return item.SnapshotName == snapshotName
+	})
+	if err == nil {
+		// item found
+		return values.SnapshotNameTakenErr
+	}
+	return nil
+}
+
+func (s *SQLiteDBOperator) Snapshot(ctx context.Context, snapshotName string) error {
+	if err := s.checkSnapshotName(ctx, snapshotName); err != nil {
+		return err
+	}
+	return snapshotDB(ctx, s.sqliteURL.Path(), snapshotName)
+}
+
+// ReplaceSnapshot takes the new snapshot before removing the previous one, so that a failure never loses both
+func (s *SQLiteDBOperator) ReplaceSnapshot(ctx context.Context, snapshotName string) error {
+	return s.replace(ctx, snapshotName, func() error {
+		return snapshotDB(ctx, s.sqliteURL.Path(), snapshotName)
+	})
+}
+
+// replace runs `create`, then removes the snapshot files that were named `snapshotName` before
+func (s *SQLiteDBOperator) replace(ctx context.Context, snapshotName string, create func() error) error {
+	list, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	if err := create(); err != nil {
+		return err
+	}
+	for _, item := range list.Named(snapshotName) {
+		if err := removeDBFiles(filepath.Join(s.sqliteURL.Dir(), item.DBName)); err != nil {
+			return fmt.Errorf("failed to remove the previous snapshot \"%s\": %w", snapshotName, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteDBOperator) Restore(ctx context.Context, snapshotName string, fast bool) error {
+	list, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			snapshotPath := filepath.Join(s.sqliteURL.Dir(), item.DBName)
+			return restoreDB(ctx, s.sqliteURL.Path(), snapshotPath, fast)
+		}
+	}
+	return values.SnapshotNotExistsErr
+}
+
+func (s *SQLiteDBOperator) Delete(ctx context.Context, snapshotName string) error {
+	list, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			return removeDBFiles(filepath.Join(s.sqliteURL.Dir(), item.DBName))
+		}
+	}
+	return values.SnapshotNotExistsErr
+}
+
+func (s *SQLiteDBOperator) ListSnapshots(ctx context.Context) (definitions.SnapshotList, error) {
+	return listSnapshots(s.sqliteURL.Dir(), s.sqliteURL.DBName(), s.warnSkippedFile)
+}
+
+func (s *SQLiteDBOperator) FindEmergencyBackup(ctx context.Context) (*definitions.EmergencyBackup, error) {
+	return findEmergencyBackup(s.sqliteURL.Path())
+}
+
+func (s *SQLiteDBOperator) RollBack(ctx context.Context) error {
+	return rollBackEmergencyBackup(s.sqliteURL.Path())
+}
+
+func (s *SQLiteDBOperator) RollForward(ctx context.Context) error {
+	return rollForwardEmergencyBackup(s.sqliteURL.Path())
+}
+
+func (s *SQLiteDBOperator) ExportSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveWriter) error {
+	list, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			return exportDB(ctx, filepath.Join(s.sqliteURL.Dir(), item.DBName), archive)
+		}
+	}
+	return values.SnapshotNotExistsErr
+}
+
+func (s *SQLiteDBOperator) ImportSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveReader) error {
+	if err := s.checkSnapshotName(ctx, snapshotName); err != nil {
+		return err
+	}
+	return s.importSnapshot(ctx, snapshotName, archive)
+}
+
+func (s *SQLiteDBOperator) ReplaceWithImportedSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveReader) error {
+	return s.replace(ctx, snapshotName, func() error {
+		return s.importSnapshot(ctx, snapshotName, archive)
+	})
+}
+
+func (s *SQLiteDBOperator) importSnapshot(ctx context.Context, snapshotName string, archive definitions.ISnapshotArchiveReader) error {
+	snapshotFileName, err := utils.BuildSnapshotDBName(s.sqliteURL.DBName(), snapshotName, time.Now())
+	if err != nil {
+		return err
+	}
+	return importDB(ctx, filepath.Join(s.sqliteURL.Dir(), snapshotFileName), archive)
+}
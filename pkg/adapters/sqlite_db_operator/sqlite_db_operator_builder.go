@@ -0,0 +1,15 @@
+package sqlite_db_operator
+
+import (
+	"ghostal/pkg/definitions"
+)
+
+type SQLiteDBOperatorBuilder struct{}
+
+func (p *SQLiteDBOperatorBuilder) ID() string {
+	return "SQLite"
+}
+
+func (p *SQLiteDBOperatorBuilder) BuildOperator(dbURL string) (definitions.IDBOperator, error) {
+	return CreateSQLiteDBOperator(dbURL)
+}
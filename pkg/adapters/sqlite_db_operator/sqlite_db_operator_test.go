@@ -0,0 +1,119 @@
+package sqlite_db_operator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createSQLiteDatabase(t *testing.T) (string, string) {
+	dbPath := filepath.Join(t.TempDir(), "gho.db")
+	WriteSQLiteSeedData(dbPath, "vehicles:5")
+	return fmt.Sprintf("sqlite://%s", dbPath), dbPath
+}
+
+// openWALDatabase switches the database at `dbPath` to WAL mode, its writes stay in the WAL until it is closed
+func openWALDatabase(t *testing.T, dbPath string) *sql.DB {
+	db, err := openDB(dbPath)
+	assert.NoError(t, err)
+	_, err = db.Exec("PRAGMA journal_mode=WAL")
+	assert.NoError(t, err)
+	_, err = db.Exec("PRAGMA wal_autocheckpoint=0")
+	assert.NoError(t, err)
+	return db
+}
+
+func TestUnit_SQLiteDBOperator_Lifecycle(t *testing.T) {
+	dbURL, dbPath := createSQLiteDatabase(t)
+
+	operator, err := CreateSQLiteDBOperator(dbURL)
+	assert.NoError(t, err)
+
+	{
+		assert.NoError(t, operator.Snapshot(context.Background(), "v1"))
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 1)
+	}
+
+	{
+		assert.ErrorIs(t, operator.Snapshot(context.Background(), "v1"), values.SnapshotNameTakenErr)
+	}
+
+	{
+		// writes still in the write-ahead log must be part of the snapshot
+		db := openWALDatabase(t, dbPath)
+		_, err := db.Exec("INSERT INTO seed (content) VALUES ('bikes:3')")
+		assert.NoError(t, err)
+		assert.FileExists(t, dbPath+"-wal")
+		assert.NoError(t, operator.Snapshot(context.Background(), "v2"))
+		assert.NoError(t, db.Close())
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 2)
+	}
+
+	{
+		// modify DB before restoring snapshot
+		WriteSQLiteSeedData(dbPath, "vehicles:2")
+		assert.NoError(t, os.WriteFile(dbPath+"-shm", []byte("stale"), 0644))
+	}
+
+	{
+		err := operator.Restore(context.Background(), "v1", false)
+		assert.NoError(t, err)
+		assert.Equal(t, "vehicles:5", ReadSQLiteSeedData(dbPath))
+		assert.NoFileExists(t, dbPath+"-shm")
+		assert.NoFileExists(t, dbPath+"-wal")
+	}
+
+	{
+		err := operator.Restore(context.Background(), "v2", true)
+		assert.NoError(t, err)
+		assert.Equal(t, "vehicles:5,bikes:3", ReadSQLiteSeedData(dbPath))
+	}
+
+	{
+		err := operator.Restore(context.Background(), "v3", false)
+		assert.Error(t, err)
+	}
+
+	{
+		err := operator.Delete(context.Background(), "v2")
+		assert.NoError(t, err)
+	}
+
+	{
+		allDatabases, err := operator.ListSnapshots(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, allDatabases, 1)
+		assert.Equal(t, "v1", allDatabases[0].SnapshotName)
+	}
+
+	{
+		// only the original and the remaining snapshot should be left behind
+		entries, err := os.ReadDir(filepath.Dir(dbPath))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+	}
+}
+
+func TestUnit_SQLiteDBOperator_NotADatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "gho.db")
+	assert.NoError(t, os.WriteFile(dbPath, []byte("definitely not a database file"), 0644))
+
+	operator, err := CreateSQLiteDBOperator("sqlite://" + dbPath)
+	assert.NoError(t, err)
+	assert.Error(t, operator.Snapshot(context.Background(), "v1"))
+}
@@ -0,0 +1,375 @@
+package sqlite_db_operator
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"io"
+	_ "modernc.org/sqlite"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const sqliteHeader = "SQLite format 3\x00"
+
+// the rollback journal and write-ahead log hold data that is not yet in the main
+// database file, so they are exported and sized along with it
+var dataSuffixes = []string{"", "-wal", "-journal"}
+
+// the shared-memory index is derived from the WAL and must never outlive it
+var allSuffixes = []string{"", "-wal", "-journal", "-shm"}
+
+// busyTimeout is how long a copy waits for the writers holding the database
+const busyTimeout = 5 * time.Second
+
+// DatabaseArchiveEntry is the archive entry holding the database file of an exported snapshot,
+// with its journal/WAL stored alongside using the same suffixes
+const DatabaseArchiveEntry = "database.sqlite"
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func checkSQLiteFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("database file %s does not exist", path)
+		}
+		return err
+	}
+	defer file.Close()
+	header := make([]byte, len(sqliteHeader))
+	n, err := io.ReadFull(file, header)
+	if n == 0 && err == io.EOF {
+		// an empty file is a valid, empty database
+		return nil
+	}
+	if err != nil || !bytes.Equal(header, []byte(sqliteHeader)) {
+		return fmt.Errorf("%s is not a SQLite database file", path)
+	}
+	return nil
+}
+
+// dbSize sums up the size of the database file and its journal/WAL
+func dbSize(path string) (int64, error) {
+	var size int64
+	for _, suffix := range dataSuffixes {
+		info, err := os.Stat(path + suffix)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+func removeDBFiles(path string) error {
+	for _, suffix := range allSuffixes {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path+suffix, err)
+		}
+	}
+	return nil
+}
+
+func renameDBFiles(sourcePath, targetPath string) error {
+	for _, suffix := range allSuffixes {
+		exists, err := fileExists(sourcePath + suffix)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if err := os.Rename(sourcePath+suffix, targetPath+suffix); err != nil {
+			return fmt.Errorf("failed to rename %s to %s: %w", sourcePath+suffix, targetPath+suffix, err)
+		}
+	}
+	return nil
+}
+
+// openDB opens the database at `path`, which must already exist
+func openDB(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?mode=rw&_pragma=busy_timeout(%d)", (&url.URL{Path: path}).EscapedPath(), busyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func syncFile(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyDB writes a consistent point-in-time copy of the database at `sourcePath` to `targetPath` with VACUUM INTO.
+// SQLite reads the database in a single transaction, so the copy has the writes that are still in the WAL, and none
+// of the writes committed while it runs.
+func copyDB(ctx context.Context, sourcePath, targetPath string) error {
+	if err := checkSQLiteFile(sourcePath); err != nil {
+		return err
+	}
+	tempPath := filepath.Join(filepath.Dir(targetPath), ".tmp_"+filepath.Base(targetPath))
+	if err := removeDBFiles(tempPath); err != nil {
+		return err
+	}
+	db, err := openDB(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", sourcePath, err)
+	}
+	_, err = db.ExecContext(ctx, "VACUUM INTO ?", tempPath)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// VACUUM INTO doesn't sync the file it writes
+		err = syncFile(tempPath)
+	}
+	if err != nil {
+		_ = removeDBFiles(tempPath)
+		return fmt.Errorf("failed to copy %s: %w", sourcePath, err)
+	}
+	if err := removeDBFiles(targetPath); err != nil {
+		_ = removeDBFiles(tempPath)
+		return err
+	}
+	return renameDBFiles(tempPath, targetPath)
+}
+
+func listSnapshots(dir, sourceDBName string, onSkip func(fileName string, err error)) (definitions.SnapshotList, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	list := make(definitions.SnapshotList, 0)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(fileName, values.SnapshotDBPrefix) {
+			continue
+		}
+		isSidecar := false
+		for _, suffix := range allSuffixes[1:] {
+			if strings.HasSuffix(fileName, suffix) {
+				isSidecar = true
+				break
+			}
+		}
+		if isSidecar {
+			continue
+		}
+		snapshotDBNameParts, err := utils.ParseSnapshotDBName(fileName)
+		if err != nil {
+			// e.g. a file copied or renamed by hand, it must not keep the snapshots from being listed
+			onSkip(fileName, err)
+			continue
+		}
+		if snapshotDBNameParts.SourceDBName != sourceDBName {
+			continue
+		}
+		sizeBytes, err := dbSize(filepath.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot size: %w", err)
+		}
+		list = append(list, definitions.SnapshotListResult{
+			SnapshotName: snapshotDBNameParts.SnapshotName,
+			DBName:       fileName,
+			CreatedAt:    snapshotDBNameParts.Timestamp,
+			SizeBytes:    sizeBytes,
+		})
+	}
+	return list, nil
+}
+
+func buildBackupPath(sourcePath string) string {
+	return filepath.Join(filepath.Dir(sourcePath), "temp_emergency_backup_"+filepath.Base(sourcePath))
+}
+
+// backupDB backs up `sourcePath` and restores it if `fn` fails
+func backupDB(sourcePath string, fn func() error) error {
+	backupPath := buildBackupPath(sourcePath)
+	if err := renameDBFiles(sourcePath, backupPath); err != nil {
+		return fmt.Errorf("failed to move original to backup: %w", err)
+	}
+	if err := fn(); err != nil {
+		// if error, remove current source and move backup back to source
+		_ = removeDBFiles(sourcePath)
+		_ = renameDBFiles(backupPath, sourcePath)
+		return err
+	}
+	// is success, remove backup
+	_ = removeDBFiles(backupPath)
+	return nil
+}
+
+// findEmergencyBackup returns the backup left behind by a restore of `sourcePath` that was killed, nil if there is none
+func findEmergencyBackup(sourcePath string) (*definitions.EmergencyBackup, error) {
+	backupPath := buildBackupPath(sourcePath)
+	backupExists, err := fileExists(backupPath)
+	if err != nil || !backupExists {
+		return nil, err
+	}
+	sourceExists, err := fileExists(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	// the backup is taken by renaming the database file first, so it is never partial
+	return &definitions.EmergencyBackup{
+		Name:     filepath.Base(backupPath),
+		Complete: true,
+		DBExists: sourceExists,
+	}, nil
+}
+
+// rollBackEmergencyBackup replaces `sourcePath` by its emergency backup
+func rollBackEmergencyBackup(sourcePath string) error {
+	backup, err := findEmergencyBackup(sourcePath)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	// when the database file is missing, the files left next to it weren't moved to the backup yet, so they are kept
+	if backup.DBExists {
+		if err := removeDBFiles(sourcePath); err != nil {
+			return err
+		}
+	}
+	return renameDBFiles(buildBackupPath(sourcePath), sourcePath)
+}
+
+// rollForwardEmergencyBackup keeps `sourcePath` and removes its emergency backup
+func rollForwardEmergencyBackup(sourcePath string) error {
+	backup, err := findEmergencyBackup(sourcePath)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return values.NothingToRecoverErr
+	}
+	if !backup.CanRollForward() {
+		return fmt.Errorf("database file %s is missing, it can only be rolled back", sourcePath)
+	}
+	return removeDBFiles(buildBackupPath(sourcePath))
+}
+
+func restoreDB(ctx context.Context, originalPath, snapshotPath string, fast bool) error {
+	// NOTE: any process holding the database open must be stopped beforehand,
+	//		 since SQLite has no server that can terminate its connections
+
+	if fast {
+		return copyDB(ctx, snapshotPath, originalPath)
+	}
+
+	return backupDB(originalPath, func() error {
+		return copyDB(ctx, snapshotPath, originalPath)
+	})
+}
+
+func snapshotDB(ctx context.Context, originalPath, snapshotName string) error {
+	snapshotFileName, err := utils.BuildSnapshotDBName(filepath.Base(originalPath), snapshotName, time.Now())
+	if err != nil {
+		return err
+	}
+	return copyDB(ctx, originalPath, filepath.Join(filepath.Dir(originalPath), snapshotFileName))
+}
+
+// exportDB copies the database file and its journal/WAL into `archive`
+func exportDB(ctx context.Context, path string, archive definitions.ISnapshotArchiveWriter) error {
+	for _, suffix := range dataSuffixes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		exists, err := fileExists(path + suffix)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		entry, err := archive.CreateEntry(DatabaseArchiveEntry + suffix)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path + suffix)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(entry, file)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", path+suffix, err)
+		}
+	}
+	return nil
+}
+
+// importDB writes the database file and its journal/WAL stored in `archive` to `targetPath`
+func importDB(ctx context.Context, targetPath string, archive definitions.ISnapshotArchiveReader) error {
+	tempPath := filepath.Join(filepath.Dir(targetPath), ".tmp_"+filepath.Base(targetPath))
+	for _, suffix := range dataSuffixes {
+		if err := ctx.Err(); err != nil {
+			_ = removeDBFiles(tempPath)
+			return err
+		}
+		entry, err := archive.OpenEntry(DatabaseArchiveEntry + suffix)
+		if err != nil {
+			if suffix == "" {
+				return err
+			}
+			// journal and WAL are optional
+			continue
+		}
+		file, err := os.OpenFile(tempPath+suffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			_ = entry.Close()
+			_ = removeDBFiles(tempPath)
+			return err
+		}
+		_, err = io.Copy(file, entry)
+		_ = entry.Close()
+		if err == nil {
+			err = file.Sync()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = removeDBFiles(tempPath)
+			return fmt.Errorf("failed to import %s: %w", DatabaseArchiveEntry+suffix, err)
+		}
+	}
+	if err := checkSQLiteFile(tempPath); err != nil {
+		_ = removeDBFiles(tempPath)
+		return err
+	}
+	return renameDBFiles(tempPath, targetPath)
+}
@@ -0,0 +1,149 @@
+package sqlite_db_operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestUnit_SQLiteBackup(t *testing.T) {
+	_, dbPath := createSQLiteDatabase(t)
+
+	// attempt destructive operation with backup
+	didAttemptRemove := false
+	err := backupDB(dbPath, func() error {
+		assert.NoFileExists(t, dbPath)
+		WriteSQLiteSeedData(dbPath, "partially restored")
+		didAttemptRemove = true
+		return errors.New("test err")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "test err", err.Error())
+	assert.True(t, didAttemptRemove)
+
+	// verify that original DB is intact
+	assert.Equal(t, "vehicles:5", ReadSQLiteSeedData(dbPath))
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(dbPath), "temp_emergency_backup_gho.db"))
+}
+
+func TestUnit_SQLiteRecovery(t *testing.T) {
+	_, dbPath := createSQLiteDatabase(t)
+	backupPath := buildBackupPath(dbPath)
+
+	// nothing was interrupted
+	backup, err := findEmergencyBackup(dbPath)
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+	assert.ErrorIs(t, rollBackEmergencyBackup(dbPath), values.NothingToRecoverErr)
+
+	// killed after backing up the database
+	assert.NoError(t, renameDBFiles(dbPath, backupPath))
+	backup, err = findEmergencyBackup(dbPath)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: "temp_emergency_backup_gho.db", Complete: true, DBExists: false}, backup)
+	assert.Error(t, rollForwardEmergencyBackup(dbPath), "the backup is all that is left")
+	assert.NoError(t, rollBackEmergencyBackup(dbPath))
+	assert.Equal(t, "vehicles:5", ReadSQLiteSeedData(dbPath))
+
+	// killed after copying the snapshot, before removing the backup
+	assert.NoError(t, renameDBFiles(dbPath, backupPath))
+	WriteSQLiteSeedData(dbPath, "planets")
+	backup, err = findEmergencyBackup(dbPath)
+	assert.NoError(t, err)
+	assert.Equal(t, &definitions.EmergencyBackup{Name: "temp_emergency_backup_gho.db", Complete: true, DBExists: true}, backup)
+	assert.NoError(t, rollBackEmergencyBackup(dbPath))
+	assert.Equal(t, "vehicles:5", ReadSQLiteSeedData(dbPath))
+
+	// same, rolled forward this time
+	assert.NoError(t, renameDBFiles(dbPath, backupPath))
+	WriteSQLiteSeedData(dbPath, "planets")
+	assert.NoError(t, rollForwardEmergencyBackup(dbPath))
+	assert.Equal(t, "planets", ReadSQLiteSeedData(dbPath))
+	assert.NoFileExists(t, backupPath)
+}
+
+func TestUnit_SQLiteCopyDB(t *testing.T) {
+	_, dbPath := createSQLiteDatabase(t)
+	db := openWALDatabase(t, dbPath)
+	defer db.Close()
+	_, err := db.Exec("CREATE TABLE debits (amount INTEGER NOT NULL)")
+	assert.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE credits (amount INTEGER NOT NULL)")
+	assert.NoError(t, err)
+
+	writePair := func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if _, err := tx.Exec("INSERT INTO debits (amount) VALUES (1)"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO credits (amount) VALUES (1)"); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	assert.NoError(t, writePair())
+
+	// keep committing while the copies are made, every copy must see whole transactions only
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			assert.NoError(t, writePair())
+		}
+	}()
+	copyPaths := make([]string, 5)
+	for idx := range copyPaths {
+		copyPaths[idx] = filepath.Join(filepath.Dir(dbPath), fmt.Sprintf("copy%d.db", idx))
+		assert.NoError(t, copyDB(context.Background(), dbPath, copyPaths[idx]))
+	}
+	cancel()
+	wg.Wait()
+	// nothing was checkpointed, the copies got the writes from the WAL
+	info, err := os.Stat(dbPath + "-wal")
+	assert.NoError(t, err)
+	assert.Positive(t, info.Size())
+
+	for _, copyPath := range copyPaths {
+		assert.Equal(t, "vehicles:5", ReadSQLiteSeedData(copyPath))
+		copied, err := openDB(copyPath)
+		assert.NoError(t, err)
+		var debits, credits int
+		assert.NoError(t, copied.QueryRow("SELECT COUNT(*) FROM debits").Scan(&debits))
+		assert.NoError(t, copied.QueryRow("SELECT COUNT(*) FROM credits").Scan(&credits))
+		assert.NoError(t, copied.Close())
+		assert.Positive(t, debits)
+		assert.Equal(t, debits, credits)
+	}
+}
+
+func TestUnit_SQLiteListSnapshots_UnreadableName(t *testing.T) {
+	_, dbPath := createSQLiteDatabase(t)
+	dir := filepath.Dir(dbPath)
+	assert.NoError(t, snapshotDB(context.Background(), dbPath, "v1"))
+
+	// e.g. a snapshot file copied by hand
+	unreadableFileName := values.SnapshotDBPrefix + "copy.db"
+	WriteSQLiteSeedData(filepath.Join(dir, unreadableFileName), "vehicles:5")
+
+	skipped := make([]string, 0)
+	list, err := listSnapshots(dir, "gho.db", func(fileName string, err error) {
+		skipped = append(skipped, fileName)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "v1", list[0].SnapshotName)
+	assert.Equal(t, []string{unreadableFileName}, skipped)
+}
@@ -0,0 +1,43 @@
+package sqlite_db_operator
+
+import (
+	"net/url"
+	"path/filepath"
+)
+
+type SQLiteURL struct {
+	dbURL *url.URL
+	// baseDir is what relative paths are relative to, the working directory if empty
+	baseDir string
+}
+
+// Path returns the location of the database file, supporting both
+// `sqlite:///abs/path.db`, `sqlite://rel/path.db` and `file:rel/path.db` forms
+func (p *SQLiteURL) Path() string {
+	path := p.dbURL.Host + p.dbURL.Path
+	if p.dbURL.Opaque != "" {
+		path = p.dbURL.Opaque
+	}
+	if p.baseDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.baseDir, path)
+}
+
+func (p *SQLiteURL) Dir() string {
+	return filepath.Dir(p.Path())
+}
+
+func (p *SQLiteURL) DBName() string {
+	return filepath.Base(p.Path())
+}
+
+func ParseSQLiteURL(dbURL string) (*SQLiteURL, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteURL{
+		dbURL: u,
+	}, nil
+}
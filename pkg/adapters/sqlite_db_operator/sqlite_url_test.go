@@ -0,0 +1,43 @@
+package sqlite_db_operator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_ParseSQLiteURL(t *testing.T) {
+	inputs := map[string]string{
+		"sqlite:///var/data/main.db":   "/var/data/main.db",
+		"sqlite://data/main.db":        "data/main.db",
+		"sqlite://./main.db?mode=rwc":  "./main.db",
+		"file:main.db":                 "main.db",
+		"file:///var/data/main.db":     "/var/data/main.db",
+		"file:data/main.db?cache=priv": "data/main.db",
+	}
+	for input, expectedPath := range inputs {
+		sqliteURL, err := ParseSQLiteURL(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedPath, sqliteURL.Path(), input)
+		assert.Equal(t, "main.db", sqliteURL.DBName(), input)
+	}
+}
+
+func TestUnit_SQLiteURL_BaseDir(t *testing.T) {
+	inputs := map[string]string{
+		"sqlite:///var/data/main.db": "/var/data/main.db",
+		"sqlite://data/main.db":      "/home/me/project/data/main.db",
+		"sqlite://./main.db":         "/home/me/project/main.db",
+		"file:main.db":               "/home/me/project/main.db",
+	}
+	for input, expectedPath := range inputs {
+		sqliteURL, err := ParseSQLiteURL(input)
+		assert.NoError(t, err)
+		sqliteURL.baseDir = "/home/me/project"
+		assert.Equal(t, expectedPath, sqliteURL.Path(), input)
+	}
+}
+
+func TestUnit_CreateSQLiteDBOperator_UnsupportedScheme(t *testing.T) {
+	_, err := CreateSQLiteDBOperator("postgresql://admin:pw@localhost/main")
+	assert.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package sqlite_db_operator
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// WriteSQLiteSeedData replaces the database at `path` by one holding `content` in its `seed` table
+func WriteSQLiteSeedData(path, content string) {
+	if err := removeDBFiles(path); err != nil {
+		panic(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE seed (content TEXT NOT NULL)"); err != nil {
+		panic(err)
+	}
+	if _, err := db.Exec("INSERT INTO seed (content) VALUES (?)", content); err != nil {
+		panic(err)
+	}
+}
+
+// ReadSQLiteSeedData returns the rows of the `seed` table of the database at `path`, joined by commas
+func ReadSQLiteSeedData(path string) string {
+	db, err := openDB(path)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT content FROM seed ORDER BY rowid")
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	contents := make([]string, 0)
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			panic(err)
+		}
+		contents = append(contents, content)
+	}
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+	return strings.Join(contents, ",")
+}
@@ -0,0 +1,106 @@
+package structured_printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"ghostal/pkg/utils"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+type errorInfo struct {
+	Code    string `json:"code" yaml:"code"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// document is everything a command prints, rendered at once so that the output stays a single valid document
+type document struct {
+	// Result holds the table records, it is nil if the command doesn't print a table but never an empty list
+	Result   *[]map[string]any `json:"result,omitempty" yaml:"result,omitempty"`
+	Messages []string          `json:"messages,omitempty" yaml:"messages,omitempty"`
+	Error    *errorInfo        `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type StructuredPrinter struct {
+	marshal  func(value any) ([]byte, error)
+	document document
+}
+
+func NewJSONPrinter() *StructuredPrinter {
+	return &StructuredPrinter{
+		marshal: func(value any) ([]byte, error) {
+			return json.MarshalIndent(value, "", "  ")
+		},
+	}
+}
+
+func NewYAMLPrinter() *StructuredPrinter {
+	return &StructuredPrinter{
+		marshal: func(value any) ([]byte, error) {
+			buffer := bytes.NewBuffer(nil)
+			encoder := yaml.NewEncoder(buffer)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(value); err != nil {
+				return nil, err
+			}
+			if err := encoder.Close(); err != nil {
+				return nil, err
+			}
+			return buffer.Bytes(), nil
+		},
+	}
+}
+
+// AddTable turns every row into a record keyed by the camel cased column names
+func (p *StructuredPrinter) AddTable(columns []string, rows [][]string) {
+	data := make([][]any, len(rows))
+	for idx, row := range rows {
+		data[idx] = make([]any, len(row))
+		for cellIdx, cell := range row {
+			data[idx][cellIdx] = cell
+		}
+	}
+	p.AddData(columns, data)
+}
+
+// AddData is AddTable for rows whose values keep their type, e.g. numbers and lists
+func (p *StructuredPrinter) AddData(columns []string, rows [][]any) {
+	keys := make([]string, len(columns))
+	for idx, column := range columns {
+		keys[idx] = utils.ToCamelCase(column)
+	}
+	records := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		record := make(map[string]any, len(keys))
+		for idx, key := range keys {
+			if idx < len(row) {
+				record[key] = row[idx]
+			}
+		}
+		records = append(records, record)
+	}
+	if p.document.Result == nil {
+		p.document.Result = &records
+		return
+	}
+	*p.document.Result = append(*p.document.Result, records...)
+}
+
+func (p *StructuredPrinter) AddMessage(msg string) {
+	p.document.Messages = append(p.document.Messages, msg)
+}
+
+func (p *StructuredPrinter) SetError(code string, err error) {
+	p.document.Error = &errorInfo{
+		Code:    code,
+		Message: err.Error(),
+	}
+}
+
+func (p *StructuredPrinter) Render() (string, error) {
+	data, err := p.marshal(p.document)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
@@ -0,0 +1,85 @@
+package terminal_progress_reporter
+
+import (
+	"fmt"
+	"ghostal/pkg/definitions"
+	"io"
+	"strings"
+	"sync"
+)
+
+const barWidth = 20
+
+// clearLine moves the cursor back to the start of the line and erases it
+const clearLine = "\r\033[K"
+
+// TerminalProgressReporter draws a live progress bar on an interactive terminal, and logs the phases and every 10% of
+// progress otherwise, so that piped or redirected output stays readable
+type TerminalProgressReporter struct {
+	out         io.Writer
+	interactive bool
+	logger      definitions.ILogger
+	mu          sync.Mutex
+	drawn       bool
+	// logged is the last progress logged for every project when not interactive
+	logged map[string]definitions.Progress
+}
+
+func NewTerminalProgressReporter(out io.Writer, interactive bool, logger definitions.ILogger) *TerminalProgressReporter {
+	return &TerminalProgressReporter{
+		out:         out,
+		interactive: interactive,
+		logger:      logger,
+		logged:      make(map[string]definitions.Progress),
+	}
+}
+
+func (r *TerminalProgressReporter) Report(progress definitions.Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.interactive {
+		_, _ = fmt.Fprint(r.out, clearLine+renderBar(progress))
+		r.drawn = true
+		return
+	}
+	last, ok := r.logged[progress.Project]
+	if ok && last.Phase == progress.Phase && progress.Percent()/10 <= last.Percent()/10 {
+		return
+	}
+	r.logged[progress.Project] = progress
+	r.logger.Info("%s", renderLine(progress))
+}
+
+func (r *TerminalProgressReporter) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.drawn {
+		_, _ = fmt.Fprint(r.out, clearLine)
+		r.drawn = false
+	}
+}
+
+func renderPrefix(progress definitions.Progress) string {
+	if progress.Project == "" {
+		return progress.Phase
+	}
+	return fmt.Sprintf("[%s] %s", progress.Project, progress.Phase)
+}
+
+func renderLine(progress definitions.Progress) string {
+	percent := progress.Percent()
+	if percent < 0 {
+		return renderPrefix(progress)
+	}
+	return fmt.Sprintf("%s %d%% (%d/%d)", renderPrefix(progress), percent, progress.Current, progress.Total)
+}
+
+func renderBar(progress definitions.Progress) string {
+	percent := progress.Percent()
+	if percent < 0 {
+		return renderPrefix(progress) + "..."
+	}
+	filled := percent * barWidth / 100
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled)
+	return fmt.Sprintf("%s [%s] %d%% (%d/%d)", renderPrefix(progress), bar, percent, progress.Current, progress.Total)
+}
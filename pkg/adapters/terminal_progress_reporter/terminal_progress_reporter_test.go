@@ -0,0 +1,60 @@
+package terminal_progress_reporter
+
+import (
+	"bytes"
+	"ghostal/pkg/adapters/memory_logger"
+	"ghostal/pkg/definitions"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_TerminalProgressReporter_Interactive(t *testing.T) {
+	out := &bytes.Buffer{}
+	reporter := NewTerminalProgressReporter(out, true, memory_logger.NewMemoryLogger())
+
+	reporter.Clear()
+	assert.Empty(t, out.String())
+
+	reporter.Report(definitions.Progress{Project: "app", Phase: "copying", Current: 42, Total: 100})
+	assert.Equal(t, clearLine+"[app] copying [########............] 42% (42/100)", out.String())
+
+	out.Reset()
+	reporter.Report(definitions.Progress{Phase: "dropping the backup"})
+	assert.Equal(t, clearLine+"dropping the backup...", out.String())
+
+	out.Reset()
+	reporter.Clear()
+	assert.Equal(t, clearLine, out.String())
+	out.Reset()
+	reporter.Clear()
+	assert.Empty(t, out.String())
+}
+
+func TestUnit_TerminalProgressReporter_NotInteractive(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := memory_logger.NewMemoryLogger()
+	reporter := NewTerminalProgressReporter(out, false, logger)
+
+	reporter.Report(definitions.Progress{Project: "app", Phase: "backing up the database"})
+	reporter.Report(definitions.Progress{Project: "app", Phase: "backing up the database"})
+	for current := int64(0); current <= 25; current++ {
+		reporter.Report(definitions.Progress{Project: "app", Phase: "copying", Current: current, Total: 25})
+	}
+	reporter.Report(definitions.Progress{Project: "web", Phase: "copying", Current: 1, Total: 25})
+	reporter.Clear()
+
+	assert.Empty(t, out.String())
+	assert.Equal(t, `[app] backing up the database
+[app] copying 0% (0/25)
+[app] copying 12% (3/25)
+[app] copying 20% (5/25)
+[app] copying 32% (8/25)
+[app] copying 40% (10/25)
+[app] copying 52% (13/25)
+[app] copying 60% (15/25)
+[app] copying 72% (18/25)
+[app] copying 80% (20/25)
+[app] copying 92% (23/25)
+[app] copying 100% (25/25)
+[web] copying 4% (1/25)`, logger.GetFullLog())
+}
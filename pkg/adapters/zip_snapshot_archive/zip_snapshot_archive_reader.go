@@ -0,0 +1,81 @@
+package zip_snapshot_archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
+	"io"
+)
+
+type ZipSnapshotArchiveReader struct {
+	reader   *zip.ReadCloser
+	manifest definitions.SnapshotArchiveManifest
+	entries  map[string]*zip.File
+}
+
+func OpenZipSnapshotArchiveReader(filePath string) (*ZipSnapshotArchiveReader, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", values.InvalidSnapshotArchiveErr, err)
+	}
+	archive := &ZipSnapshotArchiveReader{
+		reader:  reader,
+		entries: make(map[string]*zip.File),
+	}
+	for _, file := range reader.File {
+		archive.entries[file.Name] = file
+	}
+	if err := archive.readManifest(); err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (z *ZipSnapshotArchiveReader) readManifest() error {
+	file, ok := z.entries[values.SnapshotArchiveManifestName]
+	if !ok {
+		return fmt.Errorf("%w: manifest is missing", values.InvalidSnapshotArchiveErr)
+	}
+	entry, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	if err := json.NewDecoder(entry).Decode(&z.manifest); err != nil {
+		return fmt.Errorf("%w: failed to read manifest: %s", values.InvalidSnapshotArchiveErr, err)
+	}
+	if z.manifest.Version < 1 || z.manifest.Version > values.SnapshotArchiveVersion {
+		return fmt.Errorf("%w: unsupported archive version %d", values.InvalidSnapshotArchiveErr, z.manifest.Version)
+	}
+	return nil
+}
+
+func (z *ZipSnapshotArchiveReader) Manifest() definitions.SnapshotArchiveManifest {
+	return z.manifest
+}
+
+func (z *ZipSnapshotArchiveReader) EntryNames() []string {
+	names := make([]string, 0, len(z.reader.File))
+	for _, file := range z.reader.File {
+		if file.Name == values.SnapshotArchiveManifestName {
+			continue
+		}
+		names = append(names, file.Name)
+	}
+	return names
+}
+
+func (z *ZipSnapshotArchiveReader) OpenEntry(name string) (io.ReadCloser, error) {
+	file, ok := z.entries[name]
+	if !ok || name == values.SnapshotArchiveManifestName {
+		return nil, fmt.Errorf("archive entry \"%s\" not found", name)
+	}
+	return file.Open()
+}
+
+func (z *ZipSnapshotArchiveReader) Close() error {
+	return z.reader.Close()
+}
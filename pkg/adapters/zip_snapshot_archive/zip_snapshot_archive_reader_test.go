@@ -0,0 +1,76 @@
+package zip_snapshot_archive
+
+import (
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestArchive(t *testing.T, filePath string, manifest definitions.SnapshotArchiveManifest) {
+	file, err := os.Create(filePath)
+	assert.NoError(t, err)
+	defer file.Close()
+	archive := NewZipSnapshotArchiveWriter(file)
+	entry, err := archive.CreateEntry("dump.sql")
+	assert.NoError(t, err)
+	_, err = entry.Write([]byte("SELECT 1;"))
+	assert.NoError(t, err)
+	assert.NoError(t, archive.Close(manifest))
+}
+
+func TestUnit_ZipSnapshotArchiveReader(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "archive.zip")
+	manifest := definitions.SnapshotArchiveManifest{
+		Version:      values.SnapshotArchiveVersion,
+		DBType:       "Postgres",
+		DBName:       "main",
+		SnapshotName: "v1",
+		CreatedAt:    time.UnixMilli(1712976085060).UTC(),
+		ExportedAt:   time.UnixMilli(1712976095060).UTC(),
+	}
+	writeTestArchive(t, filePath, manifest)
+
+	archive, err := OpenZipSnapshotArchiveReader(filePath)
+	assert.NoError(t, err)
+	defer archive.Close()
+
+	assert.Equal(t, manifest, archive.Manifest())
+	assert.Equal(t, []string{"dump.sql"}, archive.EntryNames())
+
+	entry, err := archive.OpenEntry("dump.sql")
+	assert.NoError(t, err)
+	defer entry.Close()
+	data, err := io.ReadAll(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT 1;", string(data))
+
+	_, err = archive.OpenEntry("missing.sql")
+	assert.Error(t, err)
+	_, err = archive.OpenEntry(values.SnapshotArchiveManifestName)
+	assert.Error(t, err)
+}
+
+func TestUnit_ZipSnapshotArchiveReader_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	{
+		filePath := filepath.Join(dir, "not_a_zip.zip")
+		assert.NoError(t, os.WriteFile(filePath, []byte("hello"), 0644))
+		_, err := OpenZipSnapshotArchiveReader(filePath)
+		assert.ErrorIs(t, err, values.InvalidSnapshotArchiveErr)
+	}
+
+	{
+		filePath := filepath.Join(dir, "future.zip")
+		writeTestArchive(t, filePath, definitions.SnapshotArchiveManifest{
+			Version: values.SnapshotArchiveVersion + 1,
+		})
+		_, err := OpenZipSnapshotArchiveReader(filePath)
+		assert.ErrorIs(t, err, values.InvalidSnapshotArchiveErr)
+	}
+}
@@ -0,0 +1,48 @@
+package zip_snapshot_archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
+	"io"
+	"time"
+)
+
+type ZipSnapshotArchiveWriter struct {
+	writer *zip.Writer
+}
+
+func NewZipSnapshotArchiveWriter(w io.Writer) *ZipSnapshotArchiveWriter {
+	return &ZipSnapshotArchiveWriter{
+		writer: zip.NewWriter(w),
+	}
+}
+
+func (z *ZipSnapshotArchiveWriter) CreateEntry(name string) (io.Writer, error) {
+	if name == values.SnapshotArchiveManifestName {
+		return nil, fmt.Errorf("archive entry name \"%s\" is reserved", name)
+	}
+	return z.writer.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+}
+
+// Close writes the manifest and finalizes the archive
+func (z *ZipSnapshotArchiveWriter) Close(manifest definitions.SnapshotArchiveManifest) error {
+	entry, err := z.writer.Create(values.SnapshotArchiveManifestName)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := entry.Write(data); err != nil {
+		return err
+	}
+	return z.writer.Close()
+}
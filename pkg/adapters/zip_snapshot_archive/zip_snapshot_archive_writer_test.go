@@ -0,0 +1,54 @@
+package zip_snapshot_archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestUnit_ZipSnapshotArchiveWriter(t *testing.T) {
+	buffer := bytes.NewBuffer(nil)
+	archive := NewZipSnapshotArchiveWriter(buffer)
+
+	entry, err := archive.CreateEntry("data/vehicles.bson")
+	assert.NoError(t, err)
+	_, err = entry.Write([]byte("vehicles"))
+	assert.NoError(t, err)
+
+	_, err = archive.CreateEntry(values.SnapshotArchiveManifestName)
+	assert.Error(t, err, "manifest entry name should be reserved")
+
+	manifest := definitions.SnapshotArchiveManifest{
+		Version:      values.SnapshotArchiveVersion,
+		DBType:       "MongoDB",
+		DBName:       "main",
+		SnapshotName: "v1",
+		CreatedAt:    time.UnixMilli(1712976085060).UTC(),
+		ExportedAt:   time.UnixMilli(1712976095060).UTC(),
+	}
+	assert.NoError(t, archive.Close(manifest))
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	assert.NoError(t, err)
+	assert.Len(t, reader.File, 2)
+
+	contents := make(map[string]string)
+	for _, file := range reader.File {
+		f, err := file.Open()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		contents[file.Name] = string(data)
+	}
+	assert.Equal(t, "vehicles", contents["data/vehicles.bson"])
+
+	var readManifest definitions.SnapshotArchiveManifest
+	assert.NoError(t, json.Unmarshal([]byte(contents[values.SnapshotArchiveManifestName]), &readManifest))
+	assert.Equal(t, manifest, readManifest)
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
+	"time"
+)
+
+// projectResult is the outcome of running a command on one of the projects of --all-projects
+type projectResult struct {
+	project  definitions.Project
+	duration time.Duration
+	err      error
+}
+
+func (r projectResult) outcome(success string) string {
+	if r.err != nil {
+		return r.err.Error()
+	}
+	return success
+}
+
+func (a *App) getWorkers(args ProgramArgs) (int, error) {
+	if !args.Flags.IsSet(WorkersFlag) {
+		return values.DefaultWorkers, nil
+	}
+	return utils.StringAsPositiveInt(args.Flags.Get(WorkersFlag))
+}
+
+// forEachProject calls `fn` for every project using at most `workers` goroutines, so that the whole run takes as long as
+// the slowest project. A failing project doesn't stop the others, the results are in the same order as `projects`.
+func (a *App) forEachProject(projects definitions.ProjectsList, workers int, fn func(idx int, project definitions.Project) error) []projectResult {
+	results := make([]projectResult, len(projects))
+	indexes := make([]int, len(projects))
+	for idx := range projects {
+		indexes[idx] = idx
+	}
+	// fn errors are kept in the results, so every project is started
+	_ = utils.RunConcurrently(indexes, workers, func(idx int) error {
+		start := time.Now()
+		err := fn(idx, projects[idx])
+		results[idx] = projectResult{
+			project:  projects[idx],
+			duration: time.Since(start),
+			err:      err,
+		}
+		return nil
+	})
+	return results
+}
+
+// joinProjectErrors returns nil if every project succeeded
+func joinProjectErrors(results []projectResult) error {
+	errs := make([]error, 0)
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("project \"%s\": %w", result.project.Name, result.err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d projects failed: %w", len(errs), len(results), errors.Join(errs...))
+}
+
+func (a *App) getAllProjects(cfg definitions.IConfig, args ProgramArgs) (definitions.ProjectsList, int, error) {
+	workers, err := a.getWorkers(args)
+	if err != nil {
+		return nil, 0, err
+	}
+	projects, err := cfg.GetAllProjects()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(projects) == 0 {
+		return nil, 0, fmt.Errorf("%w: no projects in the config", values.ProjectNotFoundErr)
+	}
+	return projects, workers, nil
+}
+
+func (a *App) allProjectsSnapshotCommand(ctx context.Context, cfg definitions.IConfig, journal definitions.IJournal, args ProgramArgs, operation string) error {
+	projects, workers, err := a.getAllProjects(cfg, args)
+	if err != nil {
+		return err
+	}
+	results := a.forEachProject(projects, workers, func(_ int, project definitions.Project) error {
+		return a.snapshotProject(ctx, cfg, journal, project, args, operation)
+	})
+
+	columns := []string{"Project", "Outcome", "Duration"}
+	rows := make([][]string, len(results))
+	for idx, result := range results {
+		rows[idx] = []string{result.project.Name, result.outcome(operation + "d"), result.duration.Round(time.Millisecond).String()}
+	}
+	a.printTable(columns, rows)
+	return joinProjectErrors(results)
+}
+
+func (a *App) allProjectsListSnapshots(ctx context.Context, cfg definitions.IConfig, args ProgramArgs) error {
+	projects, workers, err := a.getAllProjects(cfg, args)
+	if err != nil {
+		return err
+	}
+	lists := make([]definitions.SnapshotList, len(projects))
+	results := a.forEachProject(projects, workers, func(idx int, project definitions.Project) error {
+		ctx, cancel, err := a.operationContext(ctx, project)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		dbOperator, err := a.createProjectOperator(project)
+		if err != nil {
+			return err
+		}
+		list, err := a.visibleSnapshots(ctx, project, dbOperator)
+		if err != nil {
+			return err
+		}
+		lists[idx] = list
+		return nil
+	})
+
+	tableInfo := func() ([]string, [][]string) {
+		listColumns, _ := definitions.SnapshotList{}.TableInfo()
+		rows := make([][]string, 0)
+		for idx, list := range lists {
+			_, listRows := list.TableInfo()
+			for _, row := range listRows {
+				rows = append(rows, append([]string{projects[idx].Name}, row...))
+			}
+		}
+		return append([]string{"Project"}, listColumns...), rows
+	}
+	dataInfo := func() ([]string, [][]any) {
+		listColumns, _ := definitions.SnapshotList{}.DataInfo()
+		rows := make([][]any, 0)
+		for idx, list := range lists {
+			_, listRows := list.DataInfo()
+			for _, row := range listRows {
+				rows = append(rows, append([]any{projects[idx].Name}, row...))
+			}
+		}
+		return append([]string{"Project"}, listColumns...), rows
+	}
+	a.printData(tableInfo, dataInfo)
+	return joinProjectErrors(results)
+}
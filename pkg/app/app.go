@@ -1,12 +1,24 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"ghostal/pkg/adapters/file_data_store"
 	"ghostal/pkg/adapters/json_file_config"
+	"ghostal/pkg/adapters/json_file_history"
+	"ghostal/pkg/adapters/json_file_journal"
+	"ghostal/pkg/adapters/structured_printer"
+	"ghostal/pkg/adapters/zip_snapshot_archive"
 	"ghostal/pkg/definitions"
 	"ghostal/pkg/utils"
 	"ghostal/pkg/values"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 type App struct {
@@ -14,6 +26,17 @@ type App struct {
 	dbOperatorBuilders []definitions.IDBOperatorBuilder
 	logger             definitions.ILogger
 	tableBuilder       definitions.ITableBuilder
+	// printer collects the output of the command when it is printed as JSON or YAML, nil for tables
+	printer      *structured_printer.StructuredPrinter
+	outputFormat string
+	// outputMu keeps the output of the projects of --all-projects from interleaving
+	outputMu sync.Mutex
+	// progress receives the progress of the operators that report it, nil to ignore it
+	progress definitions.IProgressReporter
+	// lockStore keeps the locks of the projects of the config of the run
+	lockStore definitions.IDataStore
+	// configDir is the directory of the config of the run, relative database paths are relative to it
+	configDir string
 }
 
 func NewApp(
@@ -27,12 +50,66 @@ func NewApp(
 		dbOperatorBuilders: dbOperatorBuilders,
 		logger:             logger,
 		tableBuilder:       tableBuilder,
+		outputFormat:       values.TableOutputFormat,
 	}
 }
 
+// SetProgressReporter shows the progress of long snapshots and restores
+func (a *App) SetProgressReporter(reporter definitions.IProgressReporter) {
+	a.progress = reporter
+}
+
+// OutputFormat is the format of the output of the last run, errors are part of the output unless it is a table
+func (a *App) OutputFormat() string {
+	return a.outputFormat
+}
+
+func (a *App) printTable(columns []string, rows [][]string) {
+	a.outputMu.Lock()
+	defer a.outputMu.Unlock()
+	a.clearProgress()
+	if a.printer != nil {
+		a.printer.AddTable(columns, rows)
+		return
+	}
+	a.logger.Passthrough(a.tableBuilder.BuildTable(columns, rows))
+}
+
+// printData prints the typed rows of DataInfo when the output is structured, and the rows of TableInfo otherwise
+func (a *App) printData(tableInfo func() ([]string, [][]string), dataInfo func() ([]string, [][]any)) {
+	if a.printer == nil {
+		a.printTable(tableInfo())
+		return
+	}
+	a.outputMu.Lock()
+	defer a.outputMu.Unlock()
+	a.clearProgress()
+	a.printer.AddData(dataInfo())
+}
+
+func (a *App) printMessage(msg string, keysAndValues ...interface{}) {
+	a.outputMu.Lock()
+	defer a.outputMu.Unlock()
+	a.clearProgress()
+	if a.printer != nil {
+		if len(keysAndValues) > 0 {
+			msg = fmt.Sprintf(msg, keysAndValues...)
+		}
+		if msg = strings.TrimSpace(msg); msg != "" {
+			a.printer.AddMessage(msg)
+		}
+		return
+	}
+	a.logger.Passthrough(msg, keysAndValues...)
+}
+
 func (a *App) createOperator(dbURL string) (definitions.IDBOperator, error) {
 	for _, builder := range a.dbOperatorBuilders {
 		dbOperator, err := builder.BuildOperator(dbURL)
+		if errors.Is(err, values.MissingDatabaseNameErr) {
+			// the URL is meant for this operator
+			return nil, err
+		}
 		if err != nil {
 			if err != values.UnsupportedURLSchemeError {
 				a.logger.Warning("unexpected error while attempting to build %s operator: %s", builder.ID(), err)
@@ -44,46 +121,111 @@ func (a *App) createOperator(dbURL string) (definitions.IDBOperator, error) {
 	return nil, errors.New("no supported database operator found for the given database URL")
 }
 
-func (a *App) parseProgramArgs(args []string) (ProgramArgs, error) {
-	if len(args) == 0 || len(args[0]) == 0 {
-		return ProgramArgs{}, values.NoProgramArgsProvidedError
+// createProjectOperator creates the operator of `project`, reporting its progress under the name of the project
+func (a *App) createProjectOperator(project definitions.Project) (definitions.IDBOperator, error) {
+	dbOperator, err := a.createOperator(project.DBURL)
+	if err != nil {
+		return nil, err
 	}
-	command := Command(args[0])
-	options := make([]string, 0)
-	if len(args) > 1 {
-		options = args[1:]
+	if reportingOperator, ok := dbOperator.(definitions.IProgressReportingOperator); ok && a.progress != nil {
+		reportingOperator.SetProgressReporter(&projectProgressReporter{project: project.Name, reporter: a.progress})
 	}
-	return ProgramArgs{
-		Command: command,
-		Options: options,
-	}, nil
+	if loggingOperator, ok := dbOperator.(definitions.ILoggingOperator); ok {
+		loggingOperator.SetLogger(a.logger)
+	}
+	if relativePathOperator, ok := dbOperator.(definitions.IRelativePathOperator); ok && a.configDir != "" {
+		relativePathOperator.SetBaseDir(a.configDir)
+	}
+	return dbOperator, nil
+}
+
+func (a *App) clearProgress() {
+	if a.progress != nil {
+		a.progress.Clear()
+	}
+}
+
+// projectProgressReporter tells which project the progress of an operator belongs to
+type projectProgressReporter struct {
+	project  string
+	reporter definitions.IProgressReporter
+}
+
+func (r *projectProgressReporter) Report(progress definitions.Progress) {
+	progress.Project = r.project
+	r.reporter.Report(progress)
+}
+
+func (r *projectProgressReporter) Clear() {
+	r.reporter.Clear()
+}
+
+// operationContext bounds `ctx` by the operationTimeout of the project, the returned cancel must always be called
+func (a *App) operationContext(ctx context.Context, project definitions.Project) (context.Context, context.CancelFunc, error) {
+	timeout, err := project.Timeout()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid operationTimeout of project \"%s\": %w", project.Name, err)
+	}
+	if timeout == 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, nil
 }
 
 func (a *App) printVersion(executable string) error {
-	a.logger.Passthrough("%s version %s\n", executable, a.version)
+	a.printMessage("%s version %s\n", executable, a.version)
 	return nil
 }
 
-func (a *App) printHelp(executable string) error {
-	appDescription := "\nGhostal (gho) is a database snapshot/restore tool for MongoDB and Postgres."
-	a.logger.Passthrough(appDescription)
-	a.logger.Passthrough("")
+func (a *App) printHelp(executable string, args ProgramArgs) error {
+	if len(args.Args) > 0 {
+		return a.printCommandHelp(executable, Command(args.Args[0]))
+	}
+	appDescription := "\nGhostal (gho) is a database snapshot/restore tool for MongoDB, Postgres, MySQL, SQLite and Redis."
+	a.printMessage(appDescription)
+	a.printMessage("")
 	columns := []string{"Command", "Description"}
 	rows := make([][]string, 0)
-	for _, command := range AllCommands(executable) {
-		rows = append(rows, command.Row())
+	for _, command := range AllCommands {
+		rows = append(rows, command.Row(executable))
 	}
-	a.logger.Passthrough(a.tableBuilder.BuildTable(columns, rows))
-	a.logger.Passthrough("")
+	a.printTable(columns, rows)
+	a.printMessage("")
+	globalFlags := make([]string, 0, len(GlobalFlags))
+	for _, flag := range GlobalFlags {
+		globalFlags = append(globalFlags, flag.Usage())
+	}
+	a.printMessage("Flags accepted by every command: %s\n", strings.Join(globalFlags, ", "))
+	a.printMessage("Run \"%s %s <command>\" for the arguments and flags of a command.\n", executable, HelpCommand)
+	return nil
+}
+
+func (a *App) printCommandHelp(executable string, name Command) error {
+	command, ok := FindCommand(name)
+	if !ok {
+		return fmt.Errorf("%w \"%s\" - run \"%s %s\" for help", values.UnknownCommandErr, name, executable, HelpCommand)
+	}
+	a.printMessage("\nUsage: %s\n\n%s\n", command.Usage(executable), command.Description)
+	columns := []string{"Argument", "Description"}
+	rows := make([][]string, 0)
+	for _, arg := range command.Args {
+		rows = append(rows, []string{arg.Usage(), arg.Description})
+	}
+	for _, flag := range append(command.Flags, GlobalFlags...) {
+		rows = append(rows, flag.Row())
+	}
+	a.printTable(columns, rows)
 	return nil
 }
 
 func (a *App) initProject(cfg definitions.IConfig, args ProgramArgs) error {
-	projectName, err := args.Options.Get(0, "project name")
+	projectName, err := args.Args.Get(0, "project name")
 	if err != nil {
 		return err
 	}
-	dbURL, err := args.Options.Get(1, "database URL")
+	dbURL, err := args.Args.Get(1, "database URL")
 	if err != nil {
 		return err
 	}
@@ -98,19 +240,19 @@ func (a *App) initProject(cfg definitions.IConfig, args ProgramArgs) error {
 	if err != nil {
 		return fmt.Errorf("failed to sanitize database url: %w", err)
 	}
-	a.logger.Passthrough("Created project \"%s\" with database \"%s\"\n", projectName, sanitizedDBURL)
+	a.printMessage("Created project \"%s\" with database \"%s\"\n", projectName, sanitizedDBURL)
 	return nil
 }
 
 func (a *App) selectProject(cfg definitions.IConfig, args ProgramArgs) error {
-	projectName, err := args.Options.Get(0, "project name")
+	projectName, err := args.Args.Get(0, "project name")
 	if err != nil {
 		return err
 	}
 	if err := cfg.SelectProject(projectName); err != nil {
 		return err
 	}
-	a.logger.Passthrough("Selected project \"%s\"\n", projectName)
+	a.printMessage("Selected project \"%s\"\n", projectName)
 	return nil
 }
 
@@ -119,14 +261,21 @@ func (a *App) setProjectConfigKeyValue(cfg definitions.IConfig, args ProgramArgs
 	if err != nil {
 		return err
 	}
-	key, err := args.Options.Get(0, "project config key")
+	key, err := args.Args.Get(0, "project config key")
 	if err != nil {
 		return err
 	}
-	value, err := args.Options.Get(1, "project config value")
+	value, err := args.Args.Get(1, "project config value")
 	if err != nil {
 		return err
 	}
+	return cfg.UpdateProject(selectedProject.Name, func(project *definitions.Project) error {
+		return setProjectConfigValue(project, key, value)
+	})
+}
+
+// setProjectConfigValue validates `value` and sets it as `key` of `project`
+func setProjectConfigValue(project *definitions.Project, key, value string) error {
 	switch key {
 	case "fastRestore":
 		{
@@ -134,14 +283,76 @@ func (a *App) setProjectConfigKeyValue(cfg definitions.IConfig, args ProgramArgs
 			if err != nil {
 				return err
 			}
-			selectedProject.FastRestore = utils.ToPointer(asBool)
+			project.FastRestore = utils.ToPointer(asBool)
+		}
+	case "keepLast":
+		{
+			if value == noneValue {
+				project.KeepLast = nil
+				break
+			}
+			asInt, err := utils.StringAsPositiveInt(value)
+			if err != nil {
+				return err
+			}
+			project.KeepLast = utils.ToPointer(asInt)
+		}
+	case "maxAge":
+		{
+			if value == noneValue {
+				project.MaxAge = nil
+				break
+			}
+			if _, err := utils.StringAsDuration(value); err != nil {
+				return err
+			}
+			project.MaxAge = utils.ToPointer(value)
+		}
+	case "autoSnapshotBeforeRestore":
+		{
+			asBool, err := utils.StringAsBool(value)
+			if err != nil {
+				return err
+			}
+			project.AutoSnapshotBeforeRestore = utils.ToPointer(asBool)
+		}
+	case "autoSnapshotKeepLast":
+		{
+			if value == noneValue {
+				project.AutoSnapshotKeepLast = nil
+				break
+			}
+			asInt, err := utils.StringAsPositiveInt(value)
+			if err != nil {
+				return err
+			}
+			project.AutoSnapshotKeepLast = utils.ToPointer(asInt)
+		}
+	case "operationTimeout":
+		{
+			if value == noneValue {
+				project.OperationTimeout = nil
+				break
+			}
+			if _, err := utils.StringAsDuration(value); err != nil {
+				return err
+			}
+			project.OperationTimeout = utils.ToPointer(value)
+		}
+	case "maxTotalSize":
+		{
+			if value == noneValue {
+				project.MaxTotalSize = nil
+				break
+			}
+			if _, err := utils.StringAsBytes(value); err != nil {
+				return err
+			}
+			project.MaxTotalSize = utils.ToPointer(value)
 		}
 	default:
 		return fmt.Errorf("invalid key: \"%s\"", key)
 	}
-	if err := cfg.SetProject(utils.ToPointer(selectedProject.Name), selectedProject); err != nil {
-		return err
-	}
 	return nil
 }
 
@@ -154,92 +365,1112 @@ func (a *App) printStatus(cfg definitions.IConfig) error {
 	if err != nil {
 		return err
 	}
-	columns, rows := allProjects.TableInfo(selectedProject.Name, a.dbOperatorBuilders)
-	a.logger.Passthrough(a.tableBuilder.BuildTable(columns, rows))
+	if a.printer != nil {
+		a.printTable(allProjects.DataInfo(selectedProject.Name, a.dbOperatorBuilders))
+		return nil
+	}
+	a.printTable(allProjects.TableInfo(selectedProject.Name, a.dbOperatorBuilders))
 	return nil
 }
 
-func (a *App) getDBOperator(cfg definitions.IConfig) (definitions.IDBOperator, error) {
-	selectedProject, err := cfg.GetProject(nil)
+// getProject returns the project named by the --project flag, the selected project otherwise
+func (a *App) getProject(cfg definitions.IConfig, args ProgramArgs) (definitions.Project, error) {
+	if !args.Flags.IsSet(ProjectFlag) {
+		return cfg.GetProject(nil)
+	}
+	projectName := args.Flags.Get(ProjectFlag)
+	project, err := cfg.GetProject(&projectName)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, values.ProjectNotFoundErr) {
+			return definitions.Project{}, fmt.Errorf("%w: \"%s\"", values.ProjectNotFoundErr, projectName)
+		}
+		return definitions.Project{}, err
+	}
+	return project, nil
+}
+
+func (a *App) snapshotCommand(ctx context.Context, cfg definitions.IConfig, journal definitions.IJournal, args ProgramArgs, operation string) error {
+	if args.Flags.IsSet(AllProjectsFlag) {
+		if args.Flags.IsSet(ProjectFlag) || args.Flags.IsSet(GroupFlag) {
+			return fmt.Errorf("%w: %s can't be combined with %s or %s", values.InvalidUsageErr, AllProjectsFlag, ProjectFlag, GroupFlag)
+		}
+		return a.allProjectsSnapshotCommand(ctx, cfg, journal, args, operation)
+	}
+	if args.Flags.IsSet(GroupFlag) {
+		if args.Flags.IsSet(ProjectFlag) || args.Flags.IsSet(ForceFlag) {
+			return fmt.Errorf("%w: %s can't be combined with %s or %s", values.InvalidUsageErr, GroupFlag, ProjectFlag, ForceFlag)
+		}
+		return a.groupSnapshotCommand(ctx, cfg, journal, args, operation)
 	}
-	dbOperator, err := a.createOperator(selectedProject.DBURL)
+	selectedProject, err := a.getProject(cfg, args)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return dbOperator, nil
+	if err := a.snapshotProject(ctx, cfg, journal, selectedProject, args, operation); err != nil {
+		return err
+	}
+	a.printMessage("Snapshot \"%s\" %sd.\n", args.Args[0], operation)
+	if operation == "delete" && !args.Flags.IsSet(ForceFlag) {
+		a.printMessage("It takes up space until the next \"rm\" or \"prune\" so that the removal can be undone, use %s to delete it right away.\n", ForceFlag)
+	}
+	return nil
 }
 
-func (a *App) snapshotCommand(cfg definitions.IConfig, args ProgramArgs, operation string) error {
-	selectedProject, err := cfg.GetProject(nil)
+// snapshotProject creates, restores or deletes a snapshot of `selectedProject` and records it in the journal
+func (a *App) snapshotProject(ctx context.Context, cfg definitions.IConfig, journal definitions.IJournal, selectedProject definitions.Project, args ProgramArgs, operation string) error {
+	ctx, cancel, err := a.operationContext(ctx, selectedProject)
 	if err != nil {
-		return nil
+		return err
+	}
+	defer cancel()
+	snapshotName, err := args.Args.Get(0, "snapshot name")
+	if err != nil {
+		return err
 	}
-	snapshotName, err := args.Options.Get(0, "project name")
+	dbOperator, err := a.createProjectOperator(selectedProject)
 	if err != nil {
 		return err
 	}
-	dbOperator, err := a.getDBOperator(cfg)
+	ctx, unlock, err := a.lockProject(ctx, args, selectedProject, dbOperator)
 	if err != nil {
 		return err
 	}
+	defer unlock()
+	if operation != "delete" {
+		if err := a.checkEmergencyBackup(ctx, selectedProject, dbOperator); err != nil {
+			return err
+		}
+	}
+	entry := definitions.JournalEntry{
+		Project:      selectedProject.Name,
+		SnapshotName: snapshotName,
+	}
 	switch operation {
 	case "create":
-		if err := dbOperator.Snapshot(snapshotName); err != nil {
+		if selectedProject.Snapshots[snapshotName].Trashed {
+			// the name is free again once removed
+			if err := a.emptyTrash(ctx, cfg, selectedProject, dbOperator); err != nil {
+				return err
+			}
+		}
+		if args.Flags.IsSet(ForceFlag) {
+			// the previous snapshot is only deleted once the new one exists
+			replacer, ok := dbOperator.(definitions.IReplacingOperator)
+			if !ok {
+				return fmt.Errorf("%w: %s is not supported for %s projects, remove the snapshot first", values.InvalidUsageErr, ForceFlag, selectedProject.DBType(a.dbOperatorBuilders))
+			}
+			err = replacer.ReplaceSnapshot(ctx, snapshotName)
+		} else {
+			err = dbOperator.Snapshot(ctx, snapshotName)
+		}
+		if err != nil {
 			return err
 		}
+		if err := a.setSnapshotMetadata(cfg, selectedProject.Name, snapshotName, newSnapshotMetadata(strings.Join(args.Args.Rest(1), " "))); err != nil {
+			return err
+		}
+		entry.Operation = definitions.JournalSnapshot
 	case "restore":
-		fastRestore := false
+		if _, err := a.findSnapshot(ctx, selectedProject, dbOperator, snapshotName); err != nil {
+			return err
+		}
+		fastRestore := args.Flags.IsSet(FastFlag)
 		if selectedProject.FastRestore != nil && *selectedProject.FastRestore {
 			fastRestore = true
 		}
-		if err := dbOperator.Restore(snapshotName, fastRestore); err != nil {
+		if selectedProject.AutoSnapshotBeforeRestore != nil && *selectedProject.AutoSnapshotBeforeRestore {
+			autoSnapshotName, err := a.autoSnapshotBeforeRestore(ctx, cfg, selectedProject, dbOperator, snapshotName)
+			if err != nil {
+				return err
+			}
+			entry.AutoSnapshotName = autoSnapshotName
+		}
+		if err := dbOperator.Restore(ctx, snapshotName, fastRestore); err != nil {
 			return err
 		}
+		entry.Operation = definitions.JournalRestore
 	case "delete":
-		if err := dbOperator.Delete(snapshotName); err != nil {
+		if _, err := a.findSnapshot(ctx, selectedProject, dbOperator, snapshotName); err != nil {
 			return err
 		}
+		if args.Flags.IsSet(ForceFlag) {
+			if err := dbOperator.Delete(ctx, snapshotName); err != nil {
+				return err
+			}
+			if err := a.setSnapshotMetadata(cfg, selectedProject.Name, snapshotName, definitions.SnapshotMetadata{}); err != nil {
+				return err
+			}
+		} else {
+			// keep the removed snapshot until the next removal, so that it can be undone
+			if err := a.emptyTrash(ctx, cfg, selectedProject, dbOperator); err != nil {
+				return err
+			}
+			if err := a.setSnapshotTrashed(cfg, selectedProject.Name, snapshotName, true); err != nil {
+				return err
+			}
+		}
+		entry.Operation = definitions.JournalDelete
 	default:
 		return errors.New("invalid operation")
 	}
-	a.logger.Passthrough("Snapshot \"%s\" %sd.\n", snapshotName, operation)
+	entry.CreatedAt = time.Now()
+	if err := journal.Append(entry); err != nil {
+		return fmt.Errorf("failed to record operation in the journal: %w", err)
+	}
 	return nil
 }
 
-func (a *App) listSnapshots(cfg definitions.IConfig) error {
-	dbOperator, err := a.getDBOperator(cfg)
-	if err != nil {
+func (a *App) groupCommand(cfg definitions.IConfig, args ProgramArgs) error {
+	if len(args.Args) == 0 {
+		groups, err := cfg.GetAllGroups()
+		if err != nil {
+			return err
+		}
+		a.printTable(groups.TableInfo())
+		return nil
+	}
+	groupName := args.Args[0]
+	if len(args.Args) == 1 {
+		group, err := cfg.GetGroup(groupName)
+		if err != nil {
+			return err
+		}
+		a.printTable(definitions.GroupsList{group}.TableInfo())
+		return nil
+	}
+	projectNames := make([]string, 0)
+	for _, projectName := range args.Args.Rest(1) {
+		if !slices.Contains(projectNames, projectName) {
+			projectNames = append(projectNames, projectName)
+		}
+	}
+	if err := cfg.SetGroup(definitions.Group{Name: groupName, Projects: projectNames}); err != nil {
 		return err
 	}
-	listItems, err := dbOperator.ListSnapshots()
+	a.printMessage("Group \"%s\" set to %s.\n", groupName, strings.Join(projectNames, ", "))
+	return nil
+}
+
+func (a *App) ungroupCommand(cfg definitions.IConfig, args ProgramArgs) error {
+	groupName, err := args.Args.Get(0, "group name")
 	if err != nil {
 		return err
 	}
-	columns, rows := listItems.TableInfo()
-	a.logger.Passthrough(a.tableBuilder.BuildTable(columns, rows))
+	if err := cfg.DeleteGroup(groupName); err != nil {
+		return err
+	}
+	a.printMessage("Group \"%s\" deleted.\n", groupName)
 	return nil
 }
 
-func (a *App) Run(dataStore definitions.IDataStore, executable string, programArgs []string) error {
-	args, err := a.parseProgramArgs(programArgs)
+// groupMember is a project of a group along with its operator
+type groupMember struct {
+	project    definitions.Project
+	dbOperator definitions.IDBOperator
+}
+
+func (a *App) getGroupMembers(cfg definitions.IConfig, groupName string) ([]groupMember, error) {
+	group, err := cfg.GetGroup(groupName)
 	if err != nil {
-		if err == values.NoProgramArgsProvidedError {
-			return a.printHelp(executable)
+		return nil, err
+	}
+	members := make([]groupMember, 0, len(group.Projects))
+	for _, projectName := range group.Projects {
+		project, err := cfg.GetProject(&projectName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid project \"%s\" in group \"%s\": %w", projectName, groupName, err)
+		}
+		dbOperator, err := a.createProjectOperator(project)
+		if err != nil {
+			return nil, fmt.Errorf("invalid project \"%s\" in group \"%s\": %w", projectName, groupName, err)
 		}
+		members = append(members, groupMember{project: project, dbOperator: dbOperator})
+	}
+	return members, nil
+}
+
+// groupSnapshotCommand snapshots or restores every project of the group, the projects done before a failure are rolled back
+func (a *App) groupSnapshotCommand(ctx context.Context, cfg definitions.IConfig, journal definitions.IJournal, args ProgramArgs, operation string) error {
+	groupName := args.Flags.Get(GroupFlag)
+	snapshotName, err := args.Args.Get(0, "snapshot name")
+	if err != nil {
 		return err
 	}
+	members, err := a.getGroupMembers(cfg, groupName)
+	if err != nil {
+		return err
+	}
+	// held until the whole group is done, taken in the order of the names,
+	// so that two processes waiting for overlapping groups don't wait for each other forever
+	lockOrder := slices.Clone(members)
+	slices.SortFunc(lockOrder, func(first, second groupMember) int {
+		return strings.Compare(first.project.Name, second.project.Name)
+	})
+	for _, member := range lockOrder {
+		var unlock func()
+		ctx, unlock, err = a.lockProject(ctx, args, member.project, member.dbOperator)
+		if err != nil {
+			return fmt.Errorf("project \"%s\": %w", member.project.Name, err)
+		}
+		defer unlock()
+	}
+	// checked up front, so that nothing needs to be rolled back for an obvious mistake
+	for _, member := range members {
+		if err := a.checkEmergencyBackup(ctx, member.project, member.dbOperator); err != nil {
+			return err
+		}
+		_, err := a.findSnapshot(ctx, member.project, member.dbOperator, snapshotName)
+		if operation == "create" && err == nil {
+			return fmt.Errorf("project \"%s\": %w", member.project.Name, values.SnapshotNameTakenErr)
+		}
+		if operation == "restore" && err != nil {
+			return fmt.Errorf("project \"%s\": %w", member.project.Name, err)
+		}
+	}
 
-	switch args.Command {
-	case VersionCommand:
-		return a.printVersion(executable)
-	case HelpCommand:
-		return a.printHelp(executable)
+	entries := make([]definitions.JournalEntry, 0, len(members))
+	switch operation {
+	case "create":
+		for idx := range members {
+			if err := a.snapshotGroupMember(ctx, cfg, members[idx], snapshotName); err != nil {
+				return errors.Join(err, a.rollbackGroupSnapshot(ctx, members[:idx], snapshotName))
+			}
+		}
+		for _, member := range members {
+			if err := a.setSnapshotMetadata(cfg, member.project.Name, snapshotName, newSnapshotMetadata(strings.Join(args.Args.Rest(1), " "))); err != nil {
+				return err
+			}
+			entries = append(entries, definitions.JournalEntry{Project: member.project.Name, Operation: definitions.JournalSnapshot, SnapshotName: snapshotName})
+		}
+	case "restore":
+		// the automatic snapshots are taken regardless of autoSnapshotBeforeRestore, they are what the group is rolled back to
+		autoSnapshotNames := make([]string, len(members))
+		for idx, member := range members {
+			autoSnapshotName, err := a.restoreGroupMember(ctx, cfg, member, snapshotName, args.Flags.IsSet(FastFlag))
+			autoSnapshotNames[idx] = autoSnapshotName
+			if err != nil && autoSnapshotName == "" {
+				return errors.Join(err, a.rollbackGroupRestore(ctx, members[:idx], autoSnapshotNames[:idx]))
+			}
+			if err != nil {
+				// including the failed project, a fast restore may have left it half done
+				return errors.Join(err, a.rollbackGroupRestore(ctx, members[:idx+1], autoSnapshotNames[:idx+1]))
+			}
+		}
+		for idx, member := range members {
+			entries = append(entries, definitions.JournalEntry{Project: member.project.Name, Operation: definitions.JournalRestore, SnapshotName: snapshotName, AutoSnapshotName: autoSnapshotNames[idx]})
+		}
+	default:
+		return errors.New("invalid operation")
+	}
+	for _, entry := range entries {
+		entry.CreatedAt = time.Now()
+		if err := journal.Append(entry); err != nil {
+			return fmt.Errorf("failed to record operation in the journal: %w", err)
+		}
 	}
+	a.printMessage("Snapshot \"%s\" %sd in group \"%s\" (%d projects).\n", snapshotName, operation, groupName, len(members))
+	return nil
+}
 
-	cfg := json_file_config.NewJSONFileConfig(dataStore)
+// snapshotGroupMember snapshots one project of the group within its operationTimeout
+func (a *App) snapshotGroupMember(ctx context.Context, cfg definitions.IConfig, member groupMember, snapshotName string) error {
+	ctx, cancel, err := a.operationContext(ctx, member.project)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if member.project.Snapshots[snapshotName].Trashed {
+		if err := a.emptyTrash(ctx, cfg, member.project, member.dbOperator); err != nil {
+			return err
+		}
+	}
+	if err := member.dbOperator.Snapshot(ctx, snapshotName); err != nil {
+		return fmt.Errorf("failed to snapshot project \"%s\": %w", member.project.Name, err)
+	}
+	return nil
+}
+
+// restoreGroupMember restores one project of the group within its operationTimeout,
+// the automatic snapshot is returned as soon as it is taken, even if the restore fails
+func (a *App) restoreGroupMember(ctx context.Context, cfg definitions.IConfig, member groupMember, snapshotName string, fast bool) (string, error) {
+	ctx, cancel, err := a.operationContext(ctx, member.project)
+	if err != nil {
+		return "", err
+	}
+	defer cancel()
+	autoSnapshotName, err := a.autoSnapshotBeforeRestore(ctx, cfg, member.project, member.dbOperator, snapshotName)
+	if err != nil {
+		return "", fmt.Errorf("project \"%s\": %w", member.project.Name, err)
+	}
+	if member.project.FastRestore != nil && *member.project.FastRestore {
+		fast = true
+	}
+	if err := member.dbOperator.Restore(ctx, snapshotName, fast); err != nil {
+		return autoSnapshotName, fmt.Errorf("failed to restore project \"%s\": %w", member.project.Name, err)
+	}
+	return autoSnapshotName, nil
+}
+
+func (a *App) rollbackGroupSnapshot(ctx context.Context, members []groupMember, snapshotName string) error {
+	// the rollback must run even if the group was interrupted
+	ctx = context.WithoutCancel(ctx)
+	errs := make([]error, 0)
+	for _, member := range members {
+		if err := member.dbOperator.Delete(ctx, snapshotName); err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back snapshot of project \"%s\": %w", member.project.Name, err))
+		}
+	}
+	if len(errs) == 0 {
+		a.logger.Warning("rolled back snapshot \"%s\" in %d project(s)", snapshotName, len(members))
+	}
+	return errors.Join(errs...)
+}
+
+func (a *App) rollbackGroupRestore(ctx context.Context, members []groupMember, autoSnapshotNames []string) error {
+	// the rollback must run even if the group was interrupted
+	ctx = context.WithoutCancel(ctx)
+	errs := make([]error, 0)
+	for idx, member := range members {
+		if err := member.dbOperator.Restore(ctx, autoSnapshotNames[idx], false); err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back project \"%s\" to automatic snapshot \"%s\": %w", member.project.Name, autoSnapshotNames[idx], err))
+		}
+	}
+	if len(errs) == 0 {
+		a.logger.Warning("rolled back restore in %d project(s)", len(members))
+	}
+	return errors.Join(errs...)
+}
+
+// newSnapshotMetadata describes a snapshot taken now from the current directory
+func newSnapshotMetadata(description string) definitions.SnapshotMetadata {
+	workingDir, _ := os.Getwd()
+	gitInfo := utils.GetGitInfo(workingDir)
+	return definitions.SnapshotMetadata{
+		Description: description,
+		GitCommit:   gitInfo.Commit,
+		GitBranch:   gitInfo.Branch,
+	}
+}
+
+// visibleSnapshots lists the snapshots of the project with their metadata, leaving out the removed ones
+func (a *App) visibleSnapshots(ctx context.Context, project definitions.Project, dbOperator definitions.IDBOperator) (definitions.SnapshotList, error) {
+	list, err := dbOperator.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return list.WithMetadata(project.Snapshots).WithoutTrashed(), nil
+}
+
+func (a *App) findSnapshot(ctx context.Context, project definitions.Project, dbOperator definitions.IDBOperator, snapshotName string) (definitions.SnapshotListResult, error) {
+	list, err := a.visibleSnapshots(ctx, project, dbOperator)
+	if err != nil {
+		return definitions.SnapshotListResult{}, err
+	}
+	snapshot, err := utils.Find(list, func(item definitions.SnapshotListResult) bool {
+		return item.SnapshotName == snapshotName
+	})
+	if err != nil {
+		return definitions.SnapshotListResult{}, values.SnapshotNotExistsErr
+	}
+	return snapshot, nil
+}
+
+// emptyTrash permanently deletes the removed snapshots
+func (a *App) emptyTrash(ctx context.Context, cfg definitions.IConfig, project definitions.Project, dbOperator definitions.IDBOperator) error {
+	list, err := dbOperator.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range list.WithMetadata(project.Snapshots) {
+		if !item.Metadata.Trashed {
+			continue
+		}
+		if err := dbOperator.Delete(ctx, item.SnapshotName); err != nil {
+			return fmt.Errorf("failed to delete removed snapshot \"%s\": %w", item.SnapshotName, err)
+		}
+	}
+	err = cfg.UpdateProject(project.Name, func(project *definitions.Project) error {
+		for snapshotName, metadata := range project.Snapshots {
+			if metadata.Trashed {
+				delete(project.Snapshots, snapshotName)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot metadata: %w", err)
+	}
+	return nil
+}
+
+// setSnapshotMetadata saves the metadata of a snapshot, empty metadata drops it
+func (a *App) setSnapshotMetadata(cfg definitions.IConfig, projectName, snapshotName string, metadata definitions.SnapshotMetadata) error {
+	return a.updateSnapshotMetadata(cfg, projectName, snapshotName, func(current *definitions.SnapshotMetadata) {
+		*current = metadata
+	})
+}
+
+// setSnapshotTrashed flags a snapshot as removed, or restores it, keeping the rest of its metadata
+func (a *App) setSnapshotTrashed(cfg definitions.IConfig, projectName, snapshotName string, trashed bool) error {
+	return a.updateSnapshotMetadata(cfg, projectName, snapshotName, func(current *definitions.SnapshotMetadata) {
+		current.Trashed = trashed
+	})
+}
+
+// updateSnapshotMetadata saves what `fn` makes of the latest metadata of a snapshot
+func (a *App) updateSnapshotMetadata(cfg definitions.IConfig, projectName, snapshotName string, fn func(metadata *definitions.SnapshotMetadata)) error {
+	err := cfg.UpdateProject(projectName, func(project *definitions.Project) error {
+		metadata := project.Snapshots[snapshotName]
+		fn(&metadata)
+		project.SetSnapshotMetadata(snapshotName, metadata)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot metadata: %w", err)
+	}
+	return nil
+}
+
+func (a *App) listSnapshots(ctx context.Context, cfg definitions.IConfig, args ProgramArgs) error {
+	if args.Flags.IsSet(AllProjectsFlag) {
+		if args.Flags.IsSet(ProjectFlag) {
+			return fmt.Errorf("%w: %s can't be combined with %s", values.InvalidUsageErr, AllProjectsFlag, ProjectFlag)
+		}
+		return a.allProjectsListSnapshots(ctx, cfg, args)
+	}
+	selectedProject, err := a.getProject(cfg, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel, err := a.operationContext(ctx, selectedProject)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	dbOperator, err := a.createProjectOperator(selectedProject)
+	if err != nil {
+		return err
+	}
+	listItems, err := a.visibleSnapshots(ctx, selectedProject, dbOperator)
+	if err != nil {
+		return err
+	}
+	a.printData(listItems.TableInfo, listItems.DataInfo)
+	return nil
+}
+
+func (a *App) undo(ctx context.Context, cfg definitions.IConfig, journal definitions.IJournal, args ProgramArgs) error {
+	selectedProject, err := a.getProject(cfg, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel, err := a.operationContext(ctx, selectedProject)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	entry, err := journal.Last(selectedProject.Name)
+	if err != nil {
+		return err
+	}
+	dbOperator, err := a.createProjectOperator(selectedProject)
+	if err != nil {
+		return err
+	}
+	ctx, unlock, err := a.lockProject(ctx, args, selectedProject, dbOperator)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// cannotUndo drops an entry that will never be undoable, so that the next undo reaches the one before
+	cannotUndo := func(reason string) error {
+		if err := journal.RemoveLast(selectedProject.Name); err != nil {
+			return err
+		}
+		return fmt.Errorf("cannot undo %s of snapshot \"%s\": %s", entry.Operation, entry.SnapshotName, reason)
+	}
+	switch entry.Operation {
+	case definitions.JournalSnapshot:
+		if _, err := a.findSnapshot(ctx, selectedProject, dbOperator, entry.SnapshotName); err != nil {
+			return cannotUndo("the snapshot no longer exists")
+		}
+		if err := dbOperator.Delete(ctx, entry.SnapshotName); err != nil {
+			return err
+		}
+		if err := a.setSnapshotMetadata(cfg, selectedProject.Name, entry.SnapshotName, definitions.SnapshotMetadata{}); err != nil {
+			return err
+		}
+		a.printMessage("Undid snapshot \"%s\", the snapshot was deleted.\n", entry.SnapshotName)
+	case definitions.JournalRestore:
+		if entry.AutoSnapshotName == "" {
+			return cannotUndo("no snapshot was taken before it, set autoSnapshotBeforeRestore to make restores undoable")
+		}
+		if _, err := a.findSnapshot(ctx, selectedProject, dbOperator, entry.AutoSnapshotName); err != nil {
+			return cannotUndo(fmt.Sprintf("the automatic snapshot \"%s\" no longer exists", entry.AutoSnapshotName))
+		}
+		// not fast, the automatic snapshot is kept so that the undo can be reverted with a restore
+		if err := dbOperator.Restore(ctx, entry.AutoSnapshotName, false); err != nil {
+			return err
+		}
+		a.printMessage("Undid restore of snapshot \"%s\", restored automatic snapshot \"%s\".\n", entry.SnapshotName, entry.AutoSnapshotName)
+	case definitions.JournalDelete:
+		if !selectedProject.Snapshots[entry.SnapshotName].Trashed {
+			return cannotUndo("the snapshot was permanently deleted by a later removal or prune")
+		}
+		if err := a.setSnapshotTrashed(cfg, selectedProject.Name, entry.SnapshotName, false); err != nil {
+			return err
+		}
+		a.printMessage("Undid removal of snapshot \"%s\".\n", entry.SnapshotName)
+	default:
+		return cannotUndo("unknown operation")
+	}
+	return journal.RemoveLast(selectedProject.Name)
+}
+
+// autoSnapshotBeforeRestore snapshots the current state of the database so that restoring `snapshotName` can be undone,
+// then drops the automatic snapshots beyond their own retention limit
+func (a *App) autoSnapshotBeforeRestore(ctx context.Context, cfg definitions.IConfig, project definitions.Project, dbOperator definitions.IDBOperator, snapshotName string) (string, error) {
+	autoSnapshotName := fmt.Sprintf("%s%d", values.AutoSnapshotPrefix, time.Now().UnixMilli())
+	if err := dbOperator.Snapshot(ctx, autoSnapshotName); err != nil {
+		return "", fmt.Errorf("failed to take automatic snapshot: %w", err)
+	}
+	metadata := newSnapshotMetadata(fmt.Sprintf("before restoring \"%s\"", snapshotName))
+	metadata.Automatic = true
+	if err := a.setSnapshotMetadata(cfg, project.Name, autoSnapshotName, metadata); err != nil {
+		return "", err
+	}
+	// the copy of the project is only used to list the automatic snapshots with their metadata
+	project.Snapshots = maps.Clone(project.Snapshots)
+	project.SetSnapshotMetadata(autoSnapshotName, metadata)
+	a.printMessage("Automatic snapshot \"%s\" created.\n", autoSnapshotName)
+
+	list, err := a.visibleSnapshots(ctx, project, dbOperator)
+	if err != nil {
+		return "", err
+	}
+	automaticSnapshots := list.FilterAutomatic(true)
+	for _, item := range project.AutoSnapshotRetentionPolicy().Evaluate(automaticSnapshots, time.Now()) {
+		if item.SnapshotName == snapshotName {
+			// about to be restored
+			continue
+		}
+		if err := dbOperator.Delete(ctx, item.SnapshotName); err != nil {
+			return "", fmt.Errorf("failed to delete automatic snapshot \"%s\": %w", item.SnapshotName, err)
+		}
+		if err := a.setSnapshotMetadata(cfg, project.Name, item.SnapshotName, definitions.SnapshotMetadata{}); err != nil {
+			return "", err
+		}
+	}
+	return autoSnapshotName, nil
+}
+
+func (a *App) pruneSnapshots(ctx context.Context, cfg definitions.IConfig, args ProgramArgs) error {
+	dryRun := args.Flags.IsSet(DryRunFlag)
+	selectedProject, err := a.getProject(cfg, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel, err := a.operationContext(ctx, selectedProject)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	policy, err := selectedProject.RetentionPolicy()
+	if err != nil {
+		return err
+	}
+	if policy.IsEmpty() {
+		return errors.New("no retention policy set, set keepLast, maxAge or maxTotalSize first")
+	}
+	dbOperator, err := a.createProjectOperator(selectedProject)
+	if err != nil {
+		return err
+	}
+	ctx, unlock, err := a.lockProject(ctx, args, selectedProject, dbOperator)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	allSnapshots, err := dbOperator.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	allSnapshots = allSnapshots.WithMetadata(selectedProject.Snapshots)
+	list := allSnapshots.WithoutTrashed()
+	// automatic snapshots have their own limit
+	now := time.Now()
+	pruned := append(
+		policy.Evaluate(list.FilterAutomatic(false), now),
+		selectedProject.AutoSnapshotRetentionPolicy().Evaluate(list.FilterAutomatic(true), now)...,
+	)
+	// removed snapshots are only kept for undo, pruning frees their space too
+	pruned = append(pruned, allSnapshots.OnlyTrashed()...)
+	if len(pruned) == 0 {
+		a.printMessage("No snapshots to prune.\n")
+		return nil
+	}
+	if !dryRun {
+		for _, item := range pruned {
+			if err := dbOperator.Delete(ctx, item.SnapshotName); err != nil {
+				return fmt.Errorf("failed to delete snapshot \"%s\": %w", item.SnapshotName, err)
+			}
+			if err := a.setSnapshotMetadata(cfg, selectedProject.Name, item.SnapshotName, definitions.SnapshotMetadata{}); err != nil {
+				return err
+			}
+		}
+	}
+	a.printData(pruned.TableInfo, pruned.DataInfo)
+	if dryRun {
+		a.printMessage("%d snapshot(s) would be pruned.\n", len(pruned))
+	} else {
+		a.printMessage("%d snapshot(s) pruned.\n", len(pruned))
+	}
+	return nil
+}
+
+func (a *App) tagSnapshot(ctx context.Context, cfg definitions.IConfig, args ProgramArgs) error {
+	snapshotName, err := args.Args.Get(0, "snapshot name")
+	if err != nil {
+		return err
+	}
+	selectedProject, err := a.getProject(cfg, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel, err := a.operationContext(ctx, selectedProject)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	dbOperator, err := a.createProjectOperator(selectedProject)
+	if err != nil {
+		return err
+	}
+	ctx, unlock, err := a.lockProject(ctx, args, selectedProject, dbOperator)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := a.findSnapshot(ctx, selectedProject, dbOperator, snapshotName); err != nil {
+		return err
+	}
+
+	tags := make([]string, 0)
+	for _, tag := range args.Args.Rest(1) {
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	var metadata definitions.SnapshotMetadata
+	err = a.updateSnapshotMetadata(cfg, selectedProject.Name, snapshotName, func(current *definitions.SnapshotMetadata) {
+		current.Tags = tags
+		metadata = *current
+	})
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		a.printMessage("Tags removed from snapshot \"%s\".\n", snapshotName)
+		return nil
+	}
+	a.printMessage("Snapshot \"%s\" tagged with %s.\n", snapshotName, metadata.FormattedTags())
+	return nil
+}
+
+func (a *App) exportSnapshot(ctx context.Context, cfg definitions.IConfig, args ProgramArgs) error {
+	snapshotName, err := args.Args.Get(0, "snapshot name")
+	if err != nil {
+		return err
+	}
+	filePath, err := args.Args.Get(1, "file path")
+	if err != nil {
+		return err
+	}
+	selectedProject, err := a.getProject(cfg, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel, err := a.operationContext(ctx, selectedProject)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	dbOperator, err := a.createProjectOperator(selectedProject)
+	if err != nil {
+		return err
+	}
+	dbType := selectedProject.DBType(a.dbOperatorBuilders)
+	exporter, ok := dbOperator.(definitions.ISnapshotExporter)
+	if !ok {
+		return fmt.Errorf("exporting snapshots is not supported for %s projects", dbType)
+	}
+	ctx, unlock, err := a.lockProject(ctx, args, selectedProject, dbOperator)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	snapshot, err := a.findSnapshot(ctx, selectedProject, dbOperator, snapshotName)
+	if err != nil {
+		return err
+	}
+
+	var metadata *definitions.SnapshotMetadata
+	if item, ok := selectedProject.Snapshots[snapshotName]; ok {
+		metadata = &item
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	archive := zip_snapshot_archive.NewZipSnapshotArchiveWriter(file)
+	err = exporter.ExportSnapshot(ctx, snapshotName, archive)
+	if err == nil {
+		err = archive.Close(definitions.SnapshotArchiveManifest{
+			Version:      values.SnapshotArchiveVersion,
+			DBType:       dbType,
+			DBName:       selectedProject.DBName(),
+			SnapshotName: snapshot.SnapshotName,
+			CreatedAt:    snapshot.CreatedAt,
+			ExportedAt:   time.Now(),
+			Metadata:     metadata,
+		})
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// don't leave a partial archive behind
+		_ = os.Remove(filePath)
+		return fmt.Errorf("failed to export snapshot: %w", err)
+	}
+	a.printMessage("Snapshot \"%s\" exported to \"%s\".\n", snapshotName, filePath)
+	return nil
+}
+
+func (a *App) importSnapshot(ctx context.Context, cfg definitions.IConfig, args ProgramArgs) error {
+	filePath, err := args.Args.Get(0, "file path")
+	if err != nil {
+		return err
+	}
+	archive, err := zip_snapshot_archive.OpenZipSnapshotArchiveReader(filePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	manifest := archive.Manifest()
+	snapshotName := manifest.SnapshotName
+	if len(args.Args) > 1 {
+		snapshotName = args.Args[1]
+	}
+
+	_, err = utils.Find(a.dbOperatorBuilders, func(builder definitions.IDBOperatorBuilder) bool {
+		return builder.ID() == manifest.DBType
+	})
+	if err != nil {
+		return fmt.Errorf("archive contains a snapshot of unknown database type \"%s\"", manifest.DBType)
+	}
+	selectedProject, err := a.getProject(cfg, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel, err := a.operationContext(ctx, selectedProject)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	dbType := selectedProject.DBType(a.dbOperatorBuilders)
+	if manifest.DBType != dbType {
+		return fmt.Errorf("archive contains a %s snapshot but project \"%s\" is a %s database", manifest.DBType, selectedProject.Name, dbType)
+	}
+	dbOperator, err := a.createProjectOperator(selectedProject)
+	if err != nil {
+		return err
+	}
+	importer, ok := dbOperator.(definitions.ISnapshotImporter)
+	if !ok {
+		return fmt.Errorf("importing snapshots is not supported for %s projects", dbType)
+	}
+	ctx, unlock, err := a.lockProject(ctx, args, selectedProject, dbOperator)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if selectedProject.Snapshots[snapshotName].Trashed {
+		// the name is free again once removed
+		if err := a.emptyTrash(ctx, cfg, selectedProject, dbOperator); err != nil {
+			return err
+		}
+	}
+	if args.Flags.IsSet(ForceFlag) {
+		// the previous snapshot is only deleted once the archive is imported
+		replacer, ok := dbOperator.(definitions.IReplacingImporter)
+		if !ok {
+			return fmt.Errorf("%w: %s is not supported for %s projects, remove the snapshot first", values.InvalidUsageErr, ForceFlag, dbType)
+		}
+		err = replacer.ReplaceWithImportedSnapshot(ctx, snapshotName, archive)
+	} else {
+		err = importer.ImportSnapshot(ctx, snapshotName, archive)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to import snapshot: %w", err)
+	}
+	// a replaced snapshot doesn't keep the metadata of the previous one
+	metadata := definitions.SnapshotMetadata{}
+	if manifest.Metadata != nil {
+		metadata = *manifest.Metadata
+	}
+	if err := a.setSnapshotMetadata(cfg, selectedProject.Name, snapshotName, metadata); err != nil {
+		return err
+	}
+	a.printMessage("Snapshot \"%s\" imported from \"%s\".\n", snapshotName, filePath)
+	return nil
+}
+
+// acquireLock fails if another process holds the lock, unless --wait is set
+func (a *App) acquireLock(ctx context.Context, args ProgramArgs, lock func(ctx context.Context, wait bool) (func(), error)) (func(), error) {
+	unlock, err := lock(ctx, false)
+	if err == nil || !errors.Is(err, values.LockedErr) || !args.Flags.IsSet(WaitFlag) {
+		return unlock, err
+	}
+	a.logger.Info("%s, waiting for it to be released", err)
+	return lock(ctx, true)
+}
+
+// lockProject keeps the other gho processes using the config away from `project`,
+// and away from its database even the ones using another config, the returned unlock must always be called.
+// The other projects of the config stay free to use.
+// The work done under the lock uses the returned context, it is cancelled if the lock of the database is lost.
+func (a *App) lockProject(ctx context.Context, args ProgramArgs, project definitions.Project, dbOperator definitions.IDBOperator) (context.Context, func(), error) {
+	unlockProject, err := a.acquireLock(ctx, args, func(ctx context.Context, wait bool) (func(), error) {
+		return a.lockStore.Lock(ctx, project.Name, wait)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	locker, ok := dbOperator.(definitions.ILockingOperator)
+	if !ok {
+		return ctx, unlockProject, nil
+	}
+	lockedCtx := ctx
+	unlockDB, err := a.acquireLock(ctx, args, func(ctx context.Context, wait bool) (func(), error) {
+		var unlock func()
+		var err error
+		lockedCtx, unlock, err = locker.Lock(ctx, wait)
+		return unlock, err
+	})
+	if err != nil {
+		unlockProject()
+		return nil, nil, err
+	}
+	return lockedCtx, func() {
+		if lockedCtx.Err() != nil && ctx.Err() == nil {
+			// the operation only fails with a cancellation, the reason is worth telling
+			a.logger.Error("project \"%s\": %s", project.Name, context.Cause(lockedCtx))
+		}
+		unlockDB()
+		unlockProject()
+	}, nil
+}
+
+// checkEmergencyBackup keeps snapshots and restores from running on a database whose last restore was killed,
+// they would work on a missing or incomplete database, or collide with the backup
+func (a *App) checkEmergencyBackup(ctx context.Context, project definitions.Project, dbOperator definitions.IDBOperator) error {
+	recoverer, ok := dbOperator.(definitions.IRecoveringOperator)
+	if !ok {
+		return nil
+	}
+	backup, err := recoverer.FindEmergencyBackup(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look for an emergency backup: %w", err)
+	}
+	if backup != nil {
+		return fmt.Errorf("%w in project \"%s\", %s: run \"%s\" first", values.EmergencyBackupFoundErr, project.Name, backup.State(), RecoverCommand)
+	}
+	return nil
+}
+
+func (a *App) recoverCommand(ctx context.Context, cfg definitions.IConfig, executable string, args ProgramArgs) error {
+	if args.Flags.IsSet(RollBackFlag) && args.Flags.IsSet(RollForwardFlag) {
+		return fmt.Errorf("%w: %s can't be combined with %s", values.InvalidUsageErr, RollBackFlag, RollForwardFlag)
+	}
+	selectedProject, err := a.getProject(cfg, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel, err := a.operationContext(ctx, selectedProject)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	dbOperator, err := a.createProjectOperator(selectedProject)
+	if err != nil {
+		return err
+	}
+	recoverer, ok := dbOperator.(definitions.IRecoveringOperator)
+	if !ok {
+		return fmt.Errorf("recovering interrupted restores is not supported for %s projects", selectedProject.DBType(a.dbOperatorBuilders))
+	}
+	ctx, unlock, err := a.lockProject(ctx, args, selectedProject, dbOperator)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	backup, err := recoverer.FindEmergencyBackup(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look for an emergency backup: %w", err)
+	}
+	if backup == nil {
+		a.printMessage("Nothing to recover in project \"%s\".\n", selectedProject.Name)
+		return nil
+	}
+
+	switch {
+	case args.Flags.IsSet(RollBackFlag):
+		if !backup.CanRollBack() {
+			return fmt.Errorf("%w: %s, the backup can't be rolled back, use %s", values.InvalidUsageErr, backup.State(), RollForwardFlag)
+		}
+		if err := recoverer.RollBack(ctx); err != nil {
+			return fmt.Errorf("failed to roll back to the emergency backup: %w", err)
+		}
+		a.printMessage("Project \"%s\" rolled back to its emergency backup \"%s\".\n", selectedProject.Name, backup.Name)
+	case args.Flags.IsSet(RollForwardFlag):
+		if !backup.CanRollForward() {
+			return fmt.Errorf("%w: %s, the database can't be kept, use %s", values.InvalidUsageErr, backup.State(), RollBackFlag)
+		}
+		if err := recoverer.RollForward(ctx); err != nil {
+			return fmt.Errorf("failed to drop the emergency backup: %w", err)
+		}
+		a.printMessage("Project \"%s\" kept as it is, emergency backup \"%s\" dropped.\n", selectedProject.Name, backup.Name)
+	default:
+		a.printTable([]string{"Project", "Emergency Backup", "State"}, [][]string{{selectedProject.Name, backup.Name, backup.State()}})
+		if backup.CanRollBack() {
+			a.printMessage("Run \"%s %s %s\" to put the backup back, as if the restore never happened.\n", executable, RecoverCommand, RollBackFlag)
+		}
+		if backup.CanRollForward() {
+			a.printMessage("Run \"%s %s %s\" to keep the database as it is and drop the backup.\n", executable, RecoverCommand, RollForwardFlag)
+		}
+	}
+	return nil
+}
+
+// recordHistory never fails the command, the history is informative only
+func (a *App) recordHistory(cfg definitions.IConfig, history definitions.IHistory, args ProgramArgs, start time.Time, commandErr error) {
+	entry := definitions.HistoryEntry{
+		Time:       start,
+		User:       utils.GetUsername(),
+		Command:    string(args.Command),
+		DurationMs: time.Since(start).Milliseconds(),
+		Outcome:    definitions.HistoryOutcomeOK,
+	}
+	if commandErr != nil {
+		entry.Outcome = commandErr.Error()
+	}
+	switch {
+	case args.Command == InitCommand || args.Command == SelectCommand:
+		entry.Project, _ = args.Args.Get(0, "project name")
+	case args.Flags.IsSet(GroupFlag):
+		entry.Project = "group:" + args.Flags.Get(GroupFlag)
+	case args.Flags.IsSet(AllProjectsFlag):
+		entry.Project = "all-projects"
+	default:
+		if selectedProject, err := a.getProject(cfg, args); err == nil {
+			entry.Project = selectedProject.Name
+		}
+	}
+	switch args.Command {
+	case SnapshotCommand, RestoreCommand, DeleteCommand, TagCommand:
+		entry.SnapshotName, _ = args.Args.Get(0, "snapshot name")
+	case ImportCommand:
+		entry.SnapshotName, _ = args.Args.Get(1, "snapshot name")
+	}
+	if err := history.Append(entry); err != nil {
+		a.logger.Warning("failed to record command in the history: %s", err)
+	}
+}
+
+func (a *App) printHistory(history definitions.IHistory, args ProgramArgs) error {
+	projectName := args.Flags.Get(ProjectFlag)
+	limit := values.DefaultHistoryLimit
+	if args.Flags.IsSet(LimitFlag) {
+		var err error
+		if limit, err = utils.StringAsPositiveInt(args.Flags.Get(LimitFlag)); err != nil {
+			return err
+		}
+	}
+	list, err := history.List()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	filtered := list.Filter(projectName, limit)
+	a.printData(filtered.TableInfo, filtered.DataInfo)
+	return nil
+}
+
+func (a *App) Run(ctx context.Context, dataStore definitions.IDataStore, executable string, programArgs []string) error {
+	// parsed first, so that even invalid usage is reported in the requested format
+	args, err := parseProgramArgs(executable, programArgs)
+	outputFormat := args.Flags.Get(OutputFlag)
+	a.printer = nil
+	switch outputFormat {
+	case "", values.TableOutputFormat:
+		outputFormat = values.TableOutputFormat
+	case values.JSONOutputFormat:
+		a.printer = structured_printer.NewJSONPrinter()
+	case values.YAMLOutputFormat:
+		a.printer = structured_printer.NewYAMLPrinter()
+	default:
+		return fmt.Errorf("%w \"%s\", use %s, %s or %s", values.UnknownOutputFormatErr, outputFormat, values.TableOutputFormat, values.JSONOutputFormat, values.YAMLOutputFormat)
+	}
+	a.outputFormat = outputFormat
+
+	if err == nil {
+		if configPath := args.Flags.Get(ConfigFlag); configPath != "" {
+			dataStore = file_data_store.NewFixedFileDataStore(configPath)
+		}
+		err = a.run(ctx, dataStore, executable, args)
+	} else if errors.Is(err, values.NoProgramArgsProvidedError) {
+		err = a.printHelp(executable, args)
+	}
+	if a.printer != nil {
+		if err != nil {
+			a.printer.SetError(values.ErrorCode(err), err)
+		}
+		output, renderErr := a.printer.Render()
+		if renderErr != nil {
+			return fmt.Errorf("failed to render output: %w", renderErr)
+		}
+		a.logger.Passthrough(output)
+	}
+	return err
+}
+
+func (a *App) run(ctx context.Context, dataStore definitions.IDataStore, executable string, args ProgramArgs) error {
+	switch args.Command {
+	case VersionCommand:
+		return a.printVersion(executable)
+	case HelpCommand:
+		return a.printHelp(executable, args)
+	}
+
+	cfg := json_file_config.NewJSONFileConfig(dataStore)
+	journal := json_file_journal.NewJSONFileJournal(dataStore.Sibling(values.JournalFileSuffix))
+	history := json_file_history.NewJSONFileHistory(dataStore.Sibling(values.HistoryFileSuffix))
+
+	// the projects are locked by the commands, once they know which ones they work on
+	a.lockStore = dataStore
+	a.configDir = dataStore.Dir()
+
+	start := time.Now()
+	err := a.runCommand(ctx, cfg, journal, history, executable, args)
+	if slices.Contains(MutatingCommands, args.Command) {
+		a.recordHistory(cfg, history, args, start, err)
+	}
+	return err
+}
 
+func (a *App) runCommand(ctx context.Context, cfg definitions.IConfig, journal definitions.IJournal, history definitions.IHistory, executable string, args ProgramArgs) error {
 	switch args.Command {
 	case InitCommand:
 		return a.initProject(cfg, args)
@@ -249,19 +1480,37 @@ func (a *App) Run(dataStore definitions.IDataStore, executable string, programAr
 		return a.setProjectConfigKeyValue(cfg, args)
 	case StatusCommand:
 		return a.printStatus(cfg)
+	case HistoryCommand:
+		return a.printHistory(history, args)
+	case GroupCommand:
+		return a.groupCommand(cfg, args)
+	case UngroupCommand:
+		return a.ungroupCommand(cfg, args)
 	}
 
 	switch args.Command {
 	case SnapshotCommand:
-		return a.snapshotCommand(cfg, args, "create")
+		return a.snapshotCommand(ctx, cfg, journal, args, "create")
 	case RestoreCommand:
-		return a.snapshotCommand(cfg, args, "restore")
+		return a.snapshotCommand(ctx, cfg, journal, args, "restore")
 	case DeleteCommand:
-		return a.snapshotCommand(cfg, args, "delete")
+		return a.snapshotCommand(ctx, cfg, journal, args, "delete")
 	case ListCommand:
-		return a.listSnapshots(cfg)
+		return a.listSnapshots(ctx, cfg, args)
+	case ExportCommand:
+		return a.exportSnapshot(ctx, cfg, args)
+	case ImportCommand:
+		return a.importSnapshot(ctx, cfg, args)
+	case TagCommand:
+		return a.tagSnapshot(ctx, cfg, args)
+	case PruneCommand:
+		return a.pruneSnapshots(ctx, cfg, args)
+	case UndoCommand:
+		return a.undo(ctx, cfg, journal, args)
+	case RecoverCommand:
+		return a.recoverCommand(ctx, cfg, executable, args)
 	}
 
 	fullHelpCommand := fmt.Sprintf("%s help", executable)
-	return fmt.Errorf("unknown command \"%s\" - run \"%s\" for help", args.Command, fullHelpCommand)
+	return fmt.Errorf("%w \"%s\" - run \"%s\" for help", values.UnknownCommandErr, args.Command, fullHelpCommand)
 }
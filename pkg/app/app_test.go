@@ -1,19 +1,34 @@
 package app
 
 import (
+	"archive/zip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"ghostal/pkg/adapters/file_data_store"
 	"ghostal/pkg/adapters/memory_data_store"
 	"ghostal/pkg/adapters/memory_logger"
 	"ghostal/pkg/adapters/mongo_db_operator"
+	"ghostal/pkg/adapters/mysql_db_operator"
 	"ghostal/pkg/adapters/postgres_db_operator"
 	"ghostal/pkg/adapters/pretty_table_builder"
+	"ghostal/pkg/adapters/redis_db_operator"
+	"ghostal/pkg/adapters/sqlite_db_operator"
+	"ghostal/pkg/adapters/zip_snapshot_archive"
 	"ghostal/pkg/definitions"
+	"ghostal/pkg/utils"
+	"ghostal/pkg/values"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -21,6 +36,76 @@ import (
 var testDBOperatorBuilders = []definitions.IDBOperatorBuilder{
 	&postgres_db_operator.PostgresDBOperatorBuilder{},
 	&mongo_db_operator.MongoDBOperatorBuilder{},
+	&mysql_db_operator.MySQLDBOperatorBuilder{},
+	&sqlite_db_operator.SQLiteDBOperatorBuilder{},
+	&redis_db_operator.RedisDBOperatorBuilder{},
+	&flakyDBOperatorBuilder{},
+	&lostLockDBOperatorBuilder{},
+}
+
+// flakyDBOperatorBuilder builds SQLite operators for "flaky+sqlite://" URLs, whose restores fail except for automatic snapshots
+type flakyDBOperatorBuilder struct{}
+
+func (b *flakyDBOperatorBuilder) ID() string {
+	return "Flaky"
+}
+
+func (b *flakyDBOperatorBuilder) BuildOperator(dbURL string) (definitions.IDBOperator, error) {
+	if !strings.HasPrefix(dbURL, "flaky+") {
+		return nil, values.UnsupportedURLSchemeError
+	}
+	dbOperator, err := (&sqlite_db_operator.SQLiteDBOperatorBuilder{}).BuildOperator(strings.TrimPrefix(dbURL, "flaky+"))
+	if err != nil {
+		return nil, err
+	}
+	return &flakyDBOperator{IDBOperator: dbOperator}, nil
+}
+
+type flakyDBOperator struct {
+	definitions.IDBOperator
+	reporter definitions.IProgressReporter
+}
+
+func (o *flakyDBOperator) SetProgressReporter(reporter definitions.IProgressReporter) {
+	o.reporter = reporter
+}
+
+func (o *flakyDBOperator) Restore(ctx context.Context, snapshotName string, fast bool) error {
+	if o.reporter != nil {
+		o.reporter.Report(definitions.Progress{Phase: "restoring", Current: 1, Total: 2})
+	}
+	if !strings.HasPrefix(snapshotName, values.AutoSnapshotPrefix) {
+		return errors.New("flaky restore")
+	}
+	return o.IDBOperator.Restore(ctx, snapshotName, fast)
+}
+
+// lostLockDBOperatorBuilder builds SQLite operators for "lostlock+sqlite://" URLs, whose lock is lost right away
+type lostLockDBOperatorBuilder struct{}
+
+func (b *lostLockDBOperatorBuilder) ID() string {
+	return "LostLock"
+}
+
+func (b *lostLockDBOperatorBuilder) BuildOperator(dbURL string) (definitions.IDBOperator, error) {
+	if !strings.HasPrefix(dbURL, "lostlock+") {
+		return nil, values.UnsupportedURLSchemeError
+	}
+	dbOperator, err := (&sqlite_db_operator.SQLiteDBOperatorBuilder{}).BuildOperator(strings.TrimPrefix(dbURL, "lostlock+"))
+	if err != nil {
+		return nil, err
+	}
+	return &lostLockDBOperator{IDBOperator: dbOperator}, nil
+}
+
+type lostLockDBOperator struct {
+	definitions.IDBOperator
+}
+
+func (o *lostLockDBOperator) Lock(ctx context.Context, wait bool) (context.Context, func(), error) {
+	lockedCtx, cancel := context.WithCancelCause(ctx)
+	cancel(errors.New("lost the lock of database \"dev\""))
+	return lockedCtx, func() {}, nil
 }
 
 var testAppVersion = "v0.0.0"
@@ -34,9 +119,13 @@ func createAndRunApp(programArgs string) error {
 }
 
 func createAndRunAppWithDataStore(dataStore definitions.IDataStore, programArgs string) error {
+	return createAndRunAppWithContext(context.Background(), dataStore, programArgs)
+}
+
+func createAndRunAppWithContext(ctx context.Context, dataStore definitions.IDataStore, programArgs string) error {
 	testLogger = memory_logger.NewMemoryLogger()
 	app := NewApp(testAppVersion, testDBOperatorBuilders, testLogger, testTableBuilder)
-	return app.Run(dataStore, "gho", strings.Split(programArgs, " "))
+	return app.Run(ctx, dataStore, "gho", strings.Split(programArgs, " "))
 }
 
 func TestUnit_App_Version(t *testing.T) {
@@ -49,7 +138,7 @@ func TestUnit_App_Help(t *testing.T) {
 	fullLog := testLogger.GetFullLog()
 	assert.Contains(t, fullLog, "gho version")
 	assert.Contains(t, fullLog, "gho help")
-	assert.Contains(t, fullLog, "gho init <project_name> <database_name>")
+	assert.Contains(t, fullLog, "gho init <project_name> <database_url>")
 	assert.Contains(t, fullLog, "gho select <project_name>")
 	assert.Contains(t, fullLog, "gho set <key> <value>")
 	assert.Contains(t, fullLog, "gho status")
@@ -57,6 +146,10 @@ func TestUnit_App_Help(t *testing.T) {
 	assert.Contains(t, fullLog, "gho restore <snapshot_name>")
 	assert.Contains(t, fullLog, "gho rm <snapshot_name>")
 	assert.Contains(t, fullLog, "gho ls")
+	assert.Contains(t, fullLog, "gho export <snapshot_name> <file_path>")
+	assert.Contains(t, fullLog, "gho import <file_path> [snapshot_name]")
+	assert.Contains(t, fullLog, "gho tag <snapshot_name> [tags...]")
+	assert.Contains(t, fullLog, "gho prune [--dry-run]")
 }
 
 func TestUnit_App_Init(t *testing.T) {
@@ -70,6 +163,9 @@ func TestUnit_App_Init_Errors(t *testing.T) {
 	assert.Errorf(t, createAndRunApp("init"), "should fail if no options provided")
 	assert.Errorf(t, createAndRunApp("init xxx"), "should fail if no database URL provided")
 	assert.Errorf(t, createAndRunApp("init xxx invalidUrl"), "should fail if database url is invalid")
+	err := createAndRunApp("init xxx mysql://localhost:3306")
+	assert.ErrorIs(t, err, values.MissingDatabaseNameErr, "should fail if the database name is missing")
+	assert.Equal(t, "missing_database_name", values.ErrorCode(err))
 }
 
 func TestUnit_App_Select(t *testing.T) {
@@ -97,6 +193,48 @@ func TestUnit_App_Select(t *testing.T) {
 	assert.Equal(t, "bbb", c.SelectedProject)
 }
 
+func TestUnit_App_ConcurrentSet(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".ghostal")
+	dataStore := file_data_store.NewFixedFileDataStore(configPath)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init my_project postgresql://localhost/app"))
+
+	// like two gho processes, each with its own view of the config
+	var wg sync.WaitGroup
+	for _, key := range []string{"keepLast", "autoSnapshotKeepLast"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= 30; i++ {
+				app := NewApp(testAppVersion, testDBOperatorBuilders, memory_logger.NewMemoryLogger(), testTableBuilder)
+				assert.NoError(t, app.Run(context.Background(), file_data_store.NewFixedFileDataStore(configPath), "gho", []string{"set", key, strconv.Itoa(i)}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(configPath)
+	assert.NoError(t, err)
+	var c definitions.ConfigData
+	assert.NoError(t, json.Unmarshal(data, &c))
+	assert.Equal(t, utils.ToPointer(30), c.Projects[0].KeepLast, "neither set should overwrite the other one")
+	assert.Equal(t, utils.ToPointer(30), c.Projects[0].AutoSnapshotKeepLast, "neither set should overwrite the other one")
+}
+
+func TestUnit_App_RelativeSQLitePath(t *testing.T) {
+	configDir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(configDir, "data"), 0755))
+	sqlite_db_operator.WriteSQLiteSeedData(filepath.Join(configDir, "data", "dev.db"), "vehicles")
+	dataStore := file_data_store.NewFixedFileDataStore(filepath.Join(configDir, ".ghostal"))
+	// relative to the directory of the config, not to the working directory of the tests
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init my_project sqlite://./data/dev.db"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1"))
+
+	files, err := filepath.Glob(filepath.Join(configDir, "data", "*v1*"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files, "the snapshot should be next to the database")
+}
+
 func TestUnit_App_Set(t *testing.T) {
 	dataStore := memory_data_store.NewMemoryDataStore()
 	configDataToSave, err := json.Marshal(definitions.ConfigData{
@@ -126,6 +264,41 @@ func TestUnit_App_Set(t *testing.T) {
 	{
 		assert.Error(t, createAndRunAppWithDataStore(dataStore, "set fastRestore xxx"))
 	}
+	{
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set keepLast 5"))
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set maxAge 7d"))
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set maxTotalSize 10GB"))
+		var c definitions.ConfigData
+		assert.NoError(t, json.Unmarshal(dataStore.Data, &c), "should have saved correct config data")
+		assert.Equal(t, 5, *c.Projects[0].KeepLast)
+		assert.Equal(t, "7d", *c.Projects[0].MaxAge)
+		assert.Equal(t, "10GB", *c.Projects[0].MaxTotalSize)
+	}
+	{
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set keepLast none"))
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set maxAge none"))
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set maxTotalSize none"))
+		var c definitions.ConfigData
+		assert.NoError(t, json.Unmarshal(dataStore.Data, &c), "should have saved correct config data")
+		assert.Nil(t, c.Projects[0].KeepLast)
+		assert.Nil(t, c.Projects[0].MaxAge)
+		assert.Nil(t, c.Projects[0].MaxTotalSize)
+	}
+	{
+		assert.Error(t, createAndRunAppWithDataStore(dataStore, "set keepLast 0"))
+		assert.Error(t, createAndRunAppWithDataStore(dataStore, "set maxAge forever"))
+		assert.Error(t, createAndRunAppWithDataStore(dataStore, "set maxTotalSize lots"))
+	}
+	{
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set autoSnapshotBeforeRestore true"))
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set autoSnapshotKeepLast 3"))
+		var c definitions.ConfigData
+		assert.NoError(t, json.Unmarshal(dataStore.Data, &c), "should have saved correct config data")
+		assert.True(t, *c.Projects[0].AutoSnapshotBeforeRestore)
+		assert.Equal(t, 3, *c.Projects[0].AutoSnapshotKeepLast)
+		assert.Error(t, createAndRunAppWithDataStore(dataStore, "set autoSnapshotBeforeRestore xxx"))
+		assert.Error(t, createAndRunAppWithDataStore(dataStore, "set autoSnapshotKeepLast 0"))
+	}
 }
 
 func TestUnit_App_Status(t *testing.T) {
@@ -159,6 +332,795 @@ func TestUnit_App_Status(t *testing.T) {
 	assert.NotContains(t, fullLog, "mongodb://localhost")
 }
 
+func TestUnit_App_Export(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	archivePath := filepath.Join(dir, "v1.zip")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "export v2 "+archivePath), "should fail to export non-existent snapshot")
+	assert.NoFileExists(t, archivePath)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "export v1 "+archivePath))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "export v1 "+archivePath), "should not overwrite existing file")
+
+	reader, err := zip.OpenReader(archivePath)
+	assert.NoError(t, err)
+	defer reader.Close()
+	entries := make(map[string]*zip.File)
+	for _, file := range reader.File {
+		entries[file.Name] = file
+	}
+	assert.Contains(t, entries, sqlite_db_operator.DatabaseArchiveEntry)
+	assert.Contains(t, entries, values.SnapshotArchiveManifestName)
+
+	manifestFile, err := entries[values.SnapshotArchiveManifestName].Open()
+	assert.NoError(t, err)
+	defer manifestFile.Close()
+	var manifest definitions.SnapshotArchiveManifest
+	assert.NoError(t, json.NewDecoder(manifestFile).Decode(&manifest))
+	assert.Equal(t, values.SnapshotArchiveVersion, manifest.Version)
+	assert.Equal(t, "SQLite", manifest.DBType)
+	assert.Equal(t, "v1", manifest.SnapshotName)
+	assert.False(t, manifest.CreatedAt.IsZero())
+}
+
+func TestUnit_App_Import(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	archivePath := filepath.Join(dir, "v1.zip")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "export v1 "+archivePath))
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "import "+archivePath), values.SnapshotNameTakenErr, "should not import over existing snapshot")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "import "+archivePath+" v2"))
+
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "planets")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v2"))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+
+	// --force replaces the snapshot once the archive is imported
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "planets")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v3"))
+	brokenArchivePath := filepath.Join(dir, "broken.zip")
+	file, err := os.Create(brokenArchivePath)
+	assert.NoError(t, err)
+	assert.NoError(t, zip_snapshot_archive.NewZipSnapshotArchiveWriter(file).Close(definitions.SnapshotArchiveManifest{
+		Version:      values.SnapshotArchiveVersion,
+		DBType:       "SQLite",
+		SnapshotName: "v3",
+	}))
+	assert.NoError(t, file.Close())
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "import "+brokenArchivePath+" --force"), "archive without a database")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v3"))
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(dbPath), "should keep the previous snapshot")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "import "+archivePath+" v3 --force"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v3"))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+
+	// archive of another database type
+	otherArchivePath := filepath.Join(dir, "other.zip")
+	file, err = os.Create(otherArchivePath)
+	assert.NoError(t, err)
+	writer := zip_snapshot_archive.NewZipSnapshotArchiveWriter(file)
+	assert.NoError(t, writer.Close(definitions.SnapshotArchiveManifest{
+		Version:      values.SnapshotArchiveVersion,
+		DBType:       "Postgres",
+		SnapshotName: "v3",
+	}))
+	assert.NoError(t, file.Close())
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "import "+otherArchivePath), "should refuse archive of another database type")
+
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "import "+filepath.Join(dir, "main.db")), values.InvalidSnapshotArchiveErr)
+}
+
+func TestUnit_App_SnapshotMetadata(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 before user migration"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v2"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "tag v1 seed stable seed"))
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "tag v3 seed"), values.SnapshotNotExistsErr)
+
+	var c definitions.ConfigData
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.Equal(t, "before user migration", c.Projects[0].Snapshots["v1"].Description)
+	assert.Equal(t, []string{"seed", "stable"}, c.Projects[0].Snapshots["v1"].Tags)
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	fullLog := testLogger.GetFullLog()
+	assert.Contains(t, fullLog, "before user migration")
+	assert.Contains(t, fullLog, "seed, stable")
+
+	// metadata travels with exported snapshots
+	archivePath := filepath.Join(dir, "v1.zip")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "export v1 "+archivePath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "import "+archivePath+" v1_copy"))
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.Equal(t, c.Projects[0].Snapshots["v1"], c.Projects[0].Snapshots["v1_copy"])
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "tag v1"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm v1_copy"))
+	c = definitions.ConfigData{}
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.Empty(t, c.Projects[0].Snapshots["v1"].Tags)
+	assert.Equal(t, "before user migration", c.Projects[0].Snapshots["v1"].Description)
+	assert.True(t, c.Projects[0].Snapshots["v1_copy"].Trashed, "should keep the metadata of a removed snapshot until it is permanently deleted")
+}
+
+func TestUnit_App_RichSnapshotNames(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot before-migration_42"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1.2"))
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "snapshot v1.2"), values.SnapshotNameTakenErr)
+
+	assertLogContains(t, "before-migration_42", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+	assertLogContains(t, "v1.2", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "planets")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore before-migration_42"))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm v1.2"))
+	assertLogContains(t, "v1.2", false, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+}
+
+func TestUnit_App_Prune(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "prune"), "should fail without retention policy")
+	for _, snapshotName := range []string{"v1", "v2", "v3", "v4"} {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot "+snapshotName+" some description"))
+		// distinct timestamps
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set keepLast 2"))
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "prune --dry-run"))
+	fullLog := testLogger.GetFullLog()
+	assert.Contains(t, fullLog, "v1")
+	assert.Contains(t, fullLog, "v2")
+	assert.NotContains(t, fullLog, "v3")
+	assert.Contains(t, fullLog, "2 snapshot(s) would be pruned")
+	assertLogContains(t, "v1", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "prune"))
+	assert.Contains(t, testLogger.GetFullLog(), "2 snapshot(s) pruned")
+	assertLogContains(t, "v1", false, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+	assertLogContains(t, "v4", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+	var c definitions.ConfigData
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.NotContains(t, c.Projects[0].Snapshots, "v1")
+	assert.Contains(t, c.Projects[0].Snapshots, "v4")
+
+	assertLogContains(t, "No snapshots to prune", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "prune"))
+	})
+
+	// a removed snapshot takes up space until it is pruned
+	assertLogContains(t, "use --force to delete it right away", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm v4"))
+	})
+	snapshotFiles, err := filepath.Glob(filepath.Join(dir, values.SnapshotDBPrefix+"*"))
+	assert.NoError(t, err)
+	assert.Len(t, snapshotFiles, 2)
+	assertLogContains(t, "1 snapshot(s) pruned", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "prune"))
+	})
+	snapshotFiles, err = filepath.Glob(filepath.Join(dir, values.SnapshotDBPrefix+"*"))
+	assert.NoError(t, err)
+	assert.Len(t, snapshotFiles, 1)
+	var afterPrune definitions.ConfigData
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &afterPrune))
+	assert.NotContains(t, afterPrune.Projects[0].Snapshots, "v4")
+}
+
+func TestUnit_App_AutoSnapshotBeforeRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+	// a description keeps the metadata of v1 in the config, even when it isn't run from a git repository
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 seeded"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set autoSnapshotBeforeRestore true"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set autoSnapshotKeepLast 2"))
+
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "restore nope"), values.SnapshotNotExistsErr)
+	var c definitions.ConfigData
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.Len(t, c.Projects[0].Snapshots, 1, "should not take a snapshot when the restored one doesn't exist")
+
+	for _, content := range []string{"planets", "stars", "moons"} {
+		sqlite_db_operator.WriteSQLiteSeedData(dbPath, content)
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1"))
+		assert.Contains(t, testLogger.GetFullLog(), "Automatic snapshot \""+values.AutoSnapshotPrefix)
+		assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+		// distinct timestamps
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	autoSnapshotNames := make([]string, 0)
+	for snapshotName, metadata := range c.Projects[0].Snapshots {
+		if !metadata.Automatic {
+			continue
+		}
+		assert.Equal(t, "before restoring \"v1\"", metadata.Description)
+		autoSnapshotNames = append(autoSnapshotNames, snapshotName)
+	}
+	assert.Len(t, autoSnapshotNames, 2, "should keep only the last 2 automatic snapshots")
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	fullLog := testLogger.GetFullLog()
+	assert.Equal(t, 2, strings.Count(fullLog, values.AutoSnapshotPrefix))
+	assert.Contains(t, fullLog, "yes")
+
+	// the latest automatic snapshot holds the data before the last restore
+	slices.Sort(autoSnapshotNames)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore "+autoSnapshotNames[1]))
+	assert.Equal(t, "moons", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+
+	// regular retention doesn't apply to automatic snapshots
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set keepLast 1"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "prune"))
+	assertLogContains(t, "v1", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+	assertLogContains(t, autoSnapshotNames[1], true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+}
+
+func TestUnit_App_Undo(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "undo"), values.NothingToUndoErr)
+
+	// undo snapshot
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v2"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "undo"))
+	assertLogContains(t, "v2", false, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+
+	// undo rm
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm v1"))
+	assertLogContains(t, "v1", false, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "restore v1"), values.SnapshotNotExistsErr)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "undo"))
+	assertLogContains(t, "v1", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+
+	// undo restore
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1"))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "undo"), "should fail without automatic snapshots")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set autoSnapshotBeforeRestore true"))
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "planets")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1"))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "undo"))
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+
+	// only the last removal can be undone
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v3"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm v1"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm v3"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "undo"))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "undo"), "v1 should have been permanently deleted")
+	var c definitions.ConfigData
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.NotContains(t, c.Projects[0].Snapshots, "v1")
+	assertLogContains(t, "v3", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+
+	// a removed name can be reused
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm v3"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v3"))
+	assert.NotEmpty(t, dataStore.Siblings[values.JournalFileSuffix].Data, "should persist the journal next to the config")
+}
+
+func TestUnit_App_History(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	otherDBPath := filepath.Join(dir, "other.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	sqlite_db_operator.WriteSQLiteSeedData(otherDBPath, "planets")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "restore nope"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init other sqlite://"+otherDBPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot o1"))
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "history"))
+	fullLog := testLogger.GetFullLog()
+	assert.Contains(t, fullLog, "v1")
+	assert.Contains(t, fullLog, "o1")
+	assert.Contains(t, fullLog, values.SnapshotNotExistsErr.Error())
+	assert.NotContains(t, fullLog, " ls ", "should only record mutating commands")
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "history --project local"))
+	fullLog = testLogger.GetFullLog()
+	assert.Contains(t, fullLog, "v1")
+	assert.NotContains(t, fullLog, "o1")
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "history --limit 1"))
+	fullLog = testLogger.GetFullLog()
+	assert.Contains(t, fullLog, "o1")
+	assert.NotContains(t, fullLog, "v1")
+
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "history --limit"))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "history --limit 0"))
+	assert.Equal(t, 5, strings.Count(string(dataStore.Siblings[values.HistoryFileSuffix].Data), "\n"), "should append one line per command")
+}
+
+func TestUnit_App_StructuredOutput(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath+" --output json"))
+	var output struct {
+		Result   []map[string]any `json:"result" yaml:"result"`
+		Messages []string         `json:"messages" yaml:"messages"`
+		Error    *struct {
+			Code    string `json:"code" yaml:"code"`
+			Message string `json:"message" yaml:"message"`
+		} `json:"error" yaml:"error"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(testLogger.GetFullLog()), &output))
+	assert.Len(t, output.Messages, 1)
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 first one"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls --output json"))
+	output.Messages = nil
+	assert.NoError(t, json.Unmarshal([]byte(testLogger.GetFullLog()), &output))
+	assert.Len(t, output.Result, 1)
+	assert.Equal(t, "v1", output.Result[0]["name"])
+	assert.Equal(t, "first one", output.Result[0]["description"])
+	assert.Empty(t, output.Messages)
+	// values for scripts rather than for display
+	createdAt, err := time.Parse(time.RFC3339, output.Result[0]["createdAt"].(string))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), createdAt, time.Minute)
+	assert.IsType(t, float64(0), output.Result[0]["sizeBytes"])
+	assert.Greater(t, output.Result[0]["sizeBytes"], float64(0))
+	assert.Equal(t, []any{}, output.Result[0]["tags"])
+	assert.Equal(t, false, output.Result[0]["automatic"])
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "history --output json"))
+	assert.NoError(t, json.Unmarshal([]byte(testLogger.GetFullLog()), &output))
+	assert.Len(t, output.Result, 2)
+	assert.Equal(t, "snapshot", output.Result[1]["command"])
+	_, err = time.Parse(time.RFC3339, output.Result[1]["time"].(string))
+	assert.NoError(t, err)
+	assert.IsType(t, float64(0), output.Result[1]["durationMs"])
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "--output=yaml status"))
+	assert.NoError(t, yaml.Unmarshal([]byte(testLogger.GetFullLog()), &output))
+	assert.Len(t, output.Result, 1)
+	assert.Equal(t, "local", output.Result[0]["project"])
+	assert.Equal(t, "yes", output.Result[0]["selected"])
+
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "restore nope --output json"), values.SnapshotNotExistsErr)
+	assert.NoError(t, json.Unmarshal([]byte(testLogger.GetFullLog()), &output))
+	assert.Equal(t, "snapshot_not_found", output.Error.Code)
+	assert.Equal(t, values.SnapshotNotExistsErr.Error(), output.Error.Message)
+
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "ls --output xml"), values.UnknownOutputFormatErr)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "status --output table"))
+	lines := strings.Split(strings.TrimSpace(testLogger.GetFullLog()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"PROJECT", "DATABASE", "TYPE"}, strings.Fields(lines[0]))
+	// the "Selected" column is only part of the structured output
+	dbName := definitions.Project{DBURL: "sqlite://" + dbPath}.DBName()
+	assert.Equal(t, []string{"*", "local", dbName, "SQLite"}, strings.Fields(lines[1]))
+}
+
+func TestUnit_App_CommandHelp(t *testing.T) {
+	assert.NoError(t, createAndRunApp("help restore"))
+	fullLog := testLogger.GetFullLog()
+	assert.Contains(t, fullLog, "gho restore <snapshot_name> [--fast]")
+	assert.Contains(t, fullLog, "--output <table|json|yaml>")
+	assert.ErrorIs(t, createAndRunApp("help nope"), values.UnknownCommandErr)
+	assert.ErrorIs(t, createAndRunApp("nope"), values.UnknownCommandErr)
+}
+
+func TestUnit_App_Flags(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+
+	// validated before touching the database
+	for _, programArgs := range []string{"restore", "ls extra", "ls --bogus", "history --limit", "prune --dry-run=yes", "export v1"} {
+		assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, programArgs), values.InvalidUsageErr, programArgs)
+	}
+
+	// flags anywhere, "--" ends them
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot --force v1 first one"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "tag v1 -- --weird"))
+	var c definitions.ConfigData
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.Equal(t, "first one", c.Projects[0].Snapshots["v1"].Description)
+	assert.Equal(t, []string{"--weird"}, c.Projects[0].Snapshots["v1"].Tags)
+
+	// --force replaces a snapshot
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"), values.SnapshotNameTakenErr)
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "planets")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 second one --force"))
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "moons")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1 --fast"))
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+	snapshotFiles, err := filepath.Glob(filepath.Join(dir, values.SnapshotDBPrefix+"main.db_v1_*"))
+	assert.NoError(t, err)
+	assert.Len(t, snapshotFiles, 1, "should delete the previous snapshot")
+
+	// a failed --force keeps the previous snapshot
+	assert.NoError(t, os.WriteFile(dbPath, []byte("not a database"), 0644))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 third one --force"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1 --fast"))
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.Equal(t, "second one", c.Projects[0].Snapshots["v1"].Description)
+
+	// --force removes permanently
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v2"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm --force v2"))
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "undo"))
+	assertLogContains(t, "v2", false, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+
+	// --config
+	configPath := filepath.Join(dir, "custom.json")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init other sqlite://"+dbPath+" --config "+configPath))
+	assert.FileExists(t, configPath)
+	assertLogContains(t, "other", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "--config="+configPath+" status"))
+	})
+	assertLogContains(t, "other", false, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "status"))
+	})
+}
+
+func TestUnit_App_ProjectOverride(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "main.db")
+	otherDBPath := filepath.Join(dir, "other.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	sqlite_db_operator.WriteSQLiteSeedData(otherDBPath, "planets")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init other sqlite://"+otherDBPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init local sqlite://"+dbPath))
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot o1 --project other"))
+	assertLogContains(t, "o1", false, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	})
+	assertLogContains(t, "o1", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls --project other"))
+	})
+
+	sqlite_db_operator.WriteSQLiteSeedData(otherDBPath, "stars")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore --project other o1"))
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(otherDBPath))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "tag o1 seed --project other"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "rm o1 --project other"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "undo --project other"))
+	assertLogContains(t, "o1", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls --project other"))
+	})
+
+	var c definitions.ConfigData
+	assert.NoError(t, json.Unmarshal(dataStore.Data, &c))
+	assert.Equal(t, "local", c.SelectedProject, "should not change the selected project")
+	assert.Equal(t, []string{"seed"}, c.Projects[0].Snapshots["o1"].Tags)
+
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "ls --project nope"), values.ProjectNotFoundErr)
+}
+
+func TestUnit_App_Groups(t *testing.T) {
+	dir := t.TempDir()
+	paths := map[string]string{}
+	for _, name := range []string{"pg", "mongo", "flaky", "broken"} {
+		paths[name] = filepath.Join(dir, name+".db")
+	}
+	sqlite_db_operator.WriteSQLiteSeedData(paths["pg"], "vehicles")
+	sqlite_db_operator.WriteSQLiteSeedData(paths["mongo"], "planets")
+	sqlite_db_operator.WriteSQLiteSeedData(paths["flaky"], "stars")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init pg sqlite://"+paths["pg"]))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init mongo sqlite://"+paths["mongo"]))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init flaky flaky+sqlite://"+paths["flaky"]))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init broken sqlite://"+paths["broken"]))
+
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "group app pg nope"), values.ProjectNotFoundErr)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "group app pg mongo"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "group bad pg broken"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "group worse pg flaky"))
+	assertLogContains(t, "pg, mongo", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "group"))
+	})
+
+	// every project of the group
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 --group app"))
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 --group app"), values.SnapshotNameTakenErr)
+	sqlite_db_operator.WriteSQLiteSeedData(paths["pg"], "moons")
+	sqlite_db_operator.WriteSQLiteSeedData(paths["mongo"], "comets")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1 --group app"))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(paths["pg"]))
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(paths["mongo"]))
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "restore nope --group app"), values.SnapshotNotExistsErr)
+
+	// a failed snapshot is rolled back
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "snapshot v2 --group bad"))
+	assertLogContains(t, "v2", false, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls --project pg"))
+	})
+
+	// a failed restore is rolled back
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v3 --group worse"))
+	sqlite_db_operator.WriteSQLiteSeedData(paths["pg"], "asteroids")
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "restore v3 --group worse"))
+	assert.Equal(t, "asteroids", sqlite_db_operator.ReadSQLiteSeedData(paths["pg"]))
+
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "snapshot v4 --group app --project pg"), values.InvalidUsageErr)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ungroup app"))
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "snapshot v4 --group app"), values.GroupNotFoundErr)
+}
+
+func TestUnit_App_AllProjects(t *testing.T) {
+	dir := t.TempDir()
+	paths := map[string]string{}
+	for _, name := range []string{"pg", "mongo", "mysql", "broken"} {
+		paths[name] = filepath.Join(dir, name+".db")
+	}
+	sqlite_db_operator.WriteSQLiteSeedData(paths["pg"], "vehicles")
+	sqlite_db_operator.WriteSQLiteSeedData(paths["mongo"], "planets")
+	sqlite_db_operator.WriteSQLiteSeedData(paths["mysql"], "stars")
+
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init pg sqlite://"+paths["pg"]))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init mongo sqlite://"+paths["mongo"]))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init mysql sqlite://"+paths["mysql"]))
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 --all-projects --workers 2"))
+	assertLogContains(t, "mysql", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls --all-projects"))
+	})
+	for _, name := range []string{"pg", "mongo", "mysql"} {
+		sqlite_db_operator.WriteSQLiteSeedData(paths[name], "moons")
+	}
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1 --all-projects"))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(paths["pg"]))
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(paths["mongo"]))
+	assert.Equal(t, "stars", sqlite_db_operator.ReadSQLiteSeedData(paths["mysql"]))
+
+	// a failing project doesn't stop the others
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init broken sqlite://"+paths["broken"]))
+	var err error
+	assertLogContains(t, "created", true, func() {
+		err = createAndRunAppWithDataStore(dataStore, "snapshot v2 --all-projects")
+	})
+	assert.ErrorContains(t, err, "1 of 4 projects failed")
+	assert.ErrorContains(t, err, "project \"broken\"")
+	assertLogContains(t, "v2", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls --project mysql"))
+	})
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "restore v1 --all-projects"), values.SnapshotNotExistsErr)
+
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "snapshot v3 --all-projects --project pg"), values.InvalidUsageErr)
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "ls --all-projects --workers 0"))
+}
+
+func TestUnit_App_Cancel(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "dev.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init my_project sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "planets")
+
+	// an interrupted restore puts the original database back
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, createAndRunAppWithContext(ctx, dataStore, "restore v1"), context.Canceled)
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+}
+
+func TestUnit_App_OperationTimeout(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "dev.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init my_project sqlite://"+dbPath))
+
+	assert.Error(t, createAndRunAppWithDataStore(dataStore, "set operationTimeout soon"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set operationTimeout 1ns"))
+	err := createAndRunAppWithDataStore(dataStore, "snapshot v1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "timeout", values.ErrorCode(err))
+
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "set operationTimeout none"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+}
+
+func TestUnit_App_Recover(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "dev.db")
+	backupPath := filepath.Join(dir, "temp_emergency_backup_dev.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init my_project sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+	assertLogContains(t, "Nothing to recover", true, func() {
+		assert.NoError(t, createAndRunAppWithDataStore(dataStore, "recover"))
+	})
+
+	// killed after backing up the database
+	assert.NoError(t, os.Rename(dbPath, backupPath))
+	err := createAndRunAppWithDataStore(dataStore, "restore v1")
+	assert.ErrorIs(t, err, values.EmergencyBackupFoundErr)
+	assert.Equal(t, "recovery_needed", values.ErrorCode(err))
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "snapshot v2 --all-projects"), values.EmergencyBackupFoundErr)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "recover"))
+	assert.Contains(t, testLogger.GetFullLog(), "temp_emergency_backup_dev.db")
+	assert.Contains(t, testLogger.GetFullLog(), "gho recover --rollback")
+	assert.NotContains(t, testLogger.GetFullLog(), "gho recover --rollforward")
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "recover --rollback --rollforward"), values.InvalidUsageErr)
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "recover --rollforward"), values.InvalidUsageErr)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "recover --rollback"))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+	assert.NoFileExists(t, backupPath)
+
+	// killed after copying the snapshot, before dropping the backup
+	assert.NoError(t, os.Rename(dbPath, backupPath))
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "planets")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "recover"))
+	assert.Contains(t, testLogger.GetFullLog(), "gho recover --rollback")
+	assert.Contains(t, testLogger.GetFullLog(), "gho recover --rollforward")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "recover --rollforward"))
+	assert.Equal(t, "planets", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+	assert.NoFileExists(t, backupPath)
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1"))
+	assert.Equal(t, "vehicles", sqlite_db_operator.ReadSQLiteSeedData(dbPath))
+}
+
+func TestUnit_App_Lock(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "dev.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init my_project sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+
+	dataStore.SetLockedBy("my_project", &definitions.LockInfo{PID: 42, Host: "laptop", Since: time.Now()})
+	err := createAndRunAppWithDataStore(dataStore, "restore v1")
+	assert.ErrorIs(t, err, values.LockedErr)
+	assert.Equal(t, "locked", values.ErrorCode(err))
+	assert.Contains(t, err.Error(), "locked by pid 42 on laptop")
+	// tagging and exporting wait for the running operation
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "tag v1 stable"), values.LockedErr)
+	archivePath := filepath.Join(t.TempDir(), "v1.zip")
+	assert.ErrorIs(t, createAndRunAppWithDataStore(dataStore, "export v1 "+archivePath), values.LockedErr)
+	assert.NoFileExists(t, archivePath)
+	// reading doesn't need the lock
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	// the other projects stay free
+	otherDBPath := filepath.Join(t.TempDir(), "other.db")
+	sqlite_db_operator.WriteSQLiteSeedData(otherDBPath, "planets")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init other_project sqlite://"+otherDBPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1 --project other_project"))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "select my_project"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, createAndRunAppWithContext(ctx, dataStore, "restore v1 --wait"), context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		dataStore.SetLockedBy("my_project", nil)
+	}()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1 --wait"))
+	assert.Contains(t, testLogger.GetFullLog(), "waiting for it to be released")
+	// released once done
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "restore v1"))
+}
+
+func TestUnit_App_LostLock(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "dev.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init my_project lostlock+sqlite://"+dbPath))
+
+	err := createAndRunAppWithDataStore(dataStore, "snapshot v1")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, testLogger.GetFullLog(), "project \"my_project\": lost the lock of database \"dev\"")
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "ls"))
+	assert.NotContains(t, testLogger.GetFullLog(), "v1")
+}
+
+// recordingProgressReporter keeps the reported progress and counts the clears
+type recordingProgressReporter struct {
+	reports []definitions.Progress
+	clears  int
+}
+
+func (r *recordingProgressReporter) Report(progress definitions.Progress) {
+	r.reports = append(r.reports, progress)
+}
+
+func (r *recordingProgressReporter) Clear() {
+	r.clears++
+}
+
+func TestUnit_App_Progress(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "dev.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath, "vehicles")
+	dataStore := memory_data_store.NewMemoryDataStore()
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "init my_project flaky+sqlite://"+dbPath))
+	assert.NoError(t, createAndRunAppWithDataStore(dataStore, "snapshot v1"))
+
+	reporter := &recordingProgressReporter{}
+	testLogger = memory_logger.NewMemoryLogger()
+	app := NewApp(testAppVersion, testDBOperatorBuilders, testLogger, testTableBuilder)
+	app.SetProgressReporter(reporter)
+	assert.Error(t, app.Run(context.Background(), dataStore, "gho", []string{"restore", "v1"}))
+	assert.Equal(t, []definitions.Progress{{Project: "my_project", Phase: "restoring", Current: 1, Total: 2}}, reporter.reports)
+
+	// the progress is erased before anything is printed
+	assert.NoError(t, app.Run(context.Background(), dataStore, "gho", []string{"ls"}))
+	assert.Positive(t, reporter.clears)
+}
+
 // ---------
 
 func createPostgresContainer() (string, func()) {
@@ -239,6 +1201,74 @@ func createMongoContainer() (string, func()) {
 	}
 }
 
+func createMySQLContainer() (string, func()) {
+	dbName := "mydb"
+	password := "mysqlpass"
+	ctx := context.Background()
+	req := testcontainers.ContainerRequest{
+		Image:        "mysql:8.0.36",
+		ExposedPorts: []string{"3306/tcp"},
+		Env: map[string]string{
+			"MYSQL_DATABASE":      dbName,
+			"MYSQL_ROOT_PASSWORD": password,
+		},
+		WaitingFor: wait.ForListeningPort("3306/tcp"),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		panic(fmt.Errorf("failed to start container: %s", err))
+	}
+
+	host, err := container.Host(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	mappedPort, err := container.MappedPort(context.Background(), "3306")
+	if err != nil {
+		panic(err)
+	}
+
+	dbURL := fmt.Sprintf("mysql://root:%s@%s:%s/%s", password, host, mappedPort.Port(), dbName)
+	return dbURL, func() {
+		_ = container.Terminate(ctx)
+	}
+}
+
+func createRedisContainer() (string, func()) {
+	ctx := context.Background()
+	req := testcontainers.ContainerRequest{
+		Image:        "redis:7.2.4-alpine",
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForListeningPort("6379/tcp"),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		panic(fmt.Errorf("failed to start container: %s", err))
+	}
+
+	host, err := container.Host(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	mappedPort, err := container.MappedPort(context.Background(), "6379")
+	if err != nil {
+		panic(err)
+	}
+
+	dbURL := fmt.Sprintf("redis://%s:%s/0", host, mappedPort.Port())
+	return dbURL, func() {
+		_ = container.Terminate(ctx)
+	}
+}
+
 func TestIntegration_App_SnapshotSmokePostgres(t *testing.T) {
 	dbURL1, cleanup := createPostgresContainer()
 	defer cleanup()
@@ -280,6 +1310,56 @@ func TestIntegration_App_SnapshotSmokeMongo(t *testing.T) {
 	runSmokeTest(t, "mongo_local", dbURL1, dbURL2)
 }
 
+func TestIntegration_App_SnapshotSmokeMySQL(t *testing.T) {
+	dbURL1, cleanup := createMySQLContainer()
+	defer cleanup()
+	mysql_db_operator.WriteMySQLSeedData(dbURL1, "vehicles")
+	// add another DB
+	dbURL2 := ""
+	{
+		parsedDBURL, err := mysql_db_operator.ParseMySQLURL(dbURL1)
+		if err != nil {
+			panic(err)
+		}
+		clone := parsedDBURL.Clone()
+		clone.Path = "otherdb"
+		dbURL2 = clone.String()
+
+		_ = mysql_db_operator.MySQLRunQuery(dbURL1, "CREATE DATABASE otherdb")
+		mysql_db_operator.WriteMySQLSeedData(dbURL2, "vehicles")
+	}
+	runSmokeTest(t, "mysql_local", dbURL1, dbURL2)
+}
+
+func TestIntegration_App_SnapshotSmokeRedis(t *testing.T) {
+	dbURL1, cleanup := createRedisContainer()
+	defer cleanup()
+	redis_db_operator.WriteRedisSeedData(dbURL1, "vehicles")
+	// add another DB
+	dbURL2 := ""
+	{
+		parsedDBURL, err := redis_db_operator.ParseRedisURL(dbURL1)
+		if err != nil {
+			panic(err)
+		}
+		clone := parsedDBURL.Clone()
+		clone.Path = "1"
+		dbURL2 = clone.String()
+
+		redis_db_operator.WriteRedisSeedData(dbURL2, "vehicles")
+	}
+	runSmokeTest(t, "redis_local", dbURL1, dbURL2)
+}
+
+func TestUnit_App_SnapshotSmokeSQLite(t *testing.T) {
+	dir := t.TempDir()
+	dbPath1 := filepath.Join(dir, "main.db")
+	dbPath2 := filepath.Join(dir, "other.db")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath1, "vehicles")
+	sqlite_db_operator.WriteSQLiteSeedData(dbPath2, "vehicles")
+	runSmokeTest(t, "sqlite_local", "sqlite://"+dbPath1, "sqlite://"+dbPath2)
+}
+
 func assertLogContains(t *testing.T, substring string, positive bool, fn func()) {
 	// reset memory logger before listing snapshots
 	testLogger = memory_logger.NewMemoryLogger()
@@ -2,18 +2,117 @@ package app
 
 import (
 	"fmt"
+	"ghostal/pkg/values"
+	"strings"
 )
 
-type Options []string
+// Args are the positional arguments of a command
+type Args []string
 
-func (o Options) Get(idx int, description string) (string, error) {
-	if idx > len(o)-1 {
-		return "", fmt.Errorf("option at position %d (%s) not found", idx, description)
+func (a Args) Get(idx int, description string) (string, error) {
+	if idx > len(a)-1 {
+		return "", fmt.Errorf("argument at position %d (%s) not found", idx, description)
 	}
-	return o[idx], nil
+	return a[idx], nil
+}
+
+// Rest returns the arguments from position `idx` on, for variadic arguments
+func (a Args) Rest(idx int) []string {
+	if idx > len(a) {
+		return []string{}
+	}
+	return a[idx:]
+}
+
+// Flags holds the value of every flag set, "true" for boolean flags
+type Flags map[string]string
+
+func (f Flags) Get(name string) string {
+	return f[name]
+}
+
+func (f Flags) IsSet(name string) bool {
+	_, ok := f[name]
+	return ok
 }
 
 type ProgramArgs struct {
 	Command Command
-	Options Options
+	Args    Args
+	Flags   Flags
+}
+
+func usageError(executable string, command Command, format string, a ...any) error {
+	helpCommand := fmt.Sprintf("%s %s", executable, HelpCommand)
+	if command != "" {
+		helpCommand += " " + string(command)
+	}
+	return fmt.Errorf("%w: %s - run \"%s\" for help", values.InvalidUsageErr, fmt.Sprintf(format, a...), helpCommand)
+}
+
+// parseProgramArgs validates the args against the CommandInfo of the command, flags can be anywhere and "--" ends them.
+// The flags parsed so far are returned along with any error.
+func parseProgramArgs(executable string, programArgs []string) (ProgramArgs, error) {
+	result := ProgramArgs{
+		Args:  make(Args, 0),
+		Flags: make(Flags),
+	}
+	// only the global flags are known until the command is
+	var info CommandInfo
+	flagsEnded := false
+	for idx := 0; idx < len(programArgs); idx++ {
+		token := programArgs[idx]
+		if !flagsEnded && token == "--" {
+			flagsEnded = true
+			continue
+		}
+		if !flagsEnded && strings.HasPrefix(token, "--") {
+			name, value, hasValue := strings.Cut(token, "=")
+			flag, ok := info.FindFlag(name)
+			if !ok {
+				return result, usageError(executable, result.Command, "unknown flag %s", name)
+			}
+			if flag.Value == "" {
+				if hasValue {
+					return result, usageError(executable, result.Command, "flag %s doesn't take a value", name)
+				}
+				value = "true"
+			} else if !hasValue {
+				if idx+1 > len(programArgs)-1 {
+					return result, usageError(executable, result.Command, "flag %s requires a value <%s>", name, flag.Value)
+				}
+				idx++
+				value = programArgs[idx]
+			}
+			result.Flags[name] = value
+			continue
+		}
+		if result.Command == "" {
+			if token == "" {
+				continue
+			}
+			result.Command = Command(token)
+			var ok bool
+			if info, ok = FindCommand(result.Command); !ok {
+				return result, fmt.Errorf("%w \"%s\" - run \"%s %s\" for help", values.UnknownCommandErr, token, executable, HelpCommand)
+			}
+			continue
+		}
+		result.Args = append(result.Args, token)
+	}
+	if result.Command == "" {
+		return result, values.NoProgramArgsProvidedError
+	}
+
+	variadic := false
+	for idx, arg := range info.Args {
+		variadic = variadic || arg.Variadic
+		if !arg.Optional && idx > len(result.Args)-1 {
+			return result, usageError(executable, result.Command, "missing %s", arg.Usage())
+		}
+	}
+	if !variadic && len(result.Args) > len(info.Args) {
+		return result, usageError(executable, result.Command, "unexpected argument \"%s\"", result.Args[len(info.Args)])
+	}
+	return result, nil
 }
@@ -1,6 +1,7 @@
 package definitions
 
 import (
+	"ghostal/pkg/utils"
 	"ghostal/pkg/values"
 	"net/url"
 	"strings"
@@ -12,6 +13,17 @@ type Project struct {
 	DBURL       string    `json:"dbUrl"`
 	FastRestore *bool     `json:"fastRestore,omitempty"`
 	CreatedAt   time.Time `json:"createdAt"`
+	// KeepLast, MaxAge and MaxTotalSize make up the retention policy applied by "gho prune"
+	KeepLast     *int    `json:"keepLast,omitempty"`
+	MaxAge       *string `json:"maxAge,omitempty"`
+	MaxTotalSize *string `json:"maxTotalSize,omitempty"`
+	// AutoSnapshotBeforeRestore makes every restore undoable, the automatic snapshots have their own limit
+	AutoSnapshotBeforeRestore *bool `json:"autoSnapshotBeforeRestore,omitempty"`
+	AutoSnapshotKeepLast      *int  `json:"autoSnapshotKeepLast,omitempty"`
+	// OperationTimeout bounds every snapshot, restore or listing of the project, e.g. "10m"
+	OperationTimeout *string `json:"operationTimeout,omitempty"`
+	// Snapshots holds the metadata of the snapshots of the project, keyed by snapshot name
+	Snapshots map[string]SnapshotMetadata `json:"snapshots,omitempty"`
 }
 
 func (p Project) DBName() string {
@@ -31,21 +43,101 @@ func (p Project) DBType(dbOperatorBuilders []IDBOperatorBuilder) string {
 	return values.Unknown
 }
 
+func (p Project) RetentionPolicy() (RetentionPolicy, error) {
+	policy := RetentionPolicy{
+		KeepLast: p.KeepLast,
+	}
+	if p.MaxAge != nil {
+		maxAge, err := utils.StringAsDuration(*p.MaxAge)
+		if err != nil {
+			return RetentionPolicy{}, err
+		}
+		policy.MaxAge = &maxAge
+	}
+	if p.MaxTotalSize != nil {
+		maxTotalSize, err := utils.StringAsBytes(*p.MaxTotalSize)
+		if err != nil {
+			return RetentionPolicy{}, err
+		}
+		policy.MaxTotalSize = &maxTotalSize
+	}
+	return policy, nil
+}
+
+// Timeout returns the OperationTimeout of the project, 0 if there is none
+func (p Project) Timeout() (time.Duration, error) {
+	if p.OperationTimeout == nil {
+		return 0, nil
+	}
+	return utils.StringAsDuration(*p.OperationTimeout)
+}
+
+func (p Project) AutoSnapshotRetentionPolicy() RetentionPolicy {
+	keepLast := values.DefaultAutoSnapshotKeepLast
+	if p.AutoSnapshotKeepLast != nil {
+		keepLast = *p.AutoSnapshotKeepLast
+	}
+	return RetentionPolicy{
+		KeepLast: &keepLast,
+	}
+}
+
+func (p *Project) SetSnapshotMetadata(snapshotName string, metadata SnapshotMetadata) {
+	if metadata.IsEmpty() {
+		delete(p.Snapshots, snapshotName)
+		return
+	}
+	if p.Snapshots == nil {
+		p.Snapshots = make(map[string]SnapshotMetadata)
+	}
+	p.Snapshots[snapshotName] = metadata
+}
+
 type ProjectsList []Project
 
 func (p ProjectsList) TableInfo(selectedProjectName string, dbOperatorBuilders []IDBOperatorBuilder) ([]string, [][]string) {
-	columns := []string{"Project", "Database", "Type"}
+	columns, rows := p.DataInfo(selectedProjectName, dbOperatorBuilders)
+	for idx, row := range rows {
+		// the selected project is marked in front of its name instead of in its own column
+		if row[3] != "" {
+			row[0] = "* " + row[0]
+		} else {
+			row[0] = "  " + row[0]
+		}
+		rows[idx] = row[:3]
+	}
+	return columns[:3], rows
+}
+
+// DataInfo is TableInfo for machine-readable output, with the selected project flagged in the "Selected" column
+func (p ProjectsList) DataInfo(selectedProjectName string, dbOperatorBuilders []IDBOperatorBuilder) ([]string, [][]string) {
+	columns := []string{"Project", "Database", "Type", "Selected"}
 	rows := make([][]string, 0)
 	for _, p := range p {
-		projectName := p.Name
+		selected := ""
 		if p.Name == selectedProjectName {
-			projectName = "* " + projectName
-		} else {
-			projectName = "  " + projectName
+			selected = "yes"
 		}
 		dbName := p.DBName()
 		dbType := p.DBType(dbOperatorBuilders)
-		rows = append(rows, []string{projectName, dbName, dbType})
+		rows = append(rows, []string{p.Name, dbName, dbType, selected})
+	}
+	return columns, rows
+}
+
+// Group names projects that are snapshotted and restored together
+type Group struct {
+	Name     string   `json:"name"`
+	Projects []string `json:"projects"`
+}
+
+type GroupsList []Group
+
+func (g GroupsList) TableInfo() ([]string, [][]string) {
+	columns := []string{"Group", "Projects"}
+	rows := make([][]string, 0)
+	for _, group := range g {
+		rows = append(rows, []string{group.Name, strings.Join(group.Projects, ", ")})
 	}
 	return columns, rows
 }
@@ -53,12 +145,20 @@ func (p ProjectsList) TableInfo(selectedProjectName string, dbOperatorBuilders [
 type ConfigData struct {
 	SelectedProject string    `json:"selectedProject"`
 	Projects        []Project `json:"projects"`
+	Groups          []Group   `json:"groups,omitempty"`
 }
 
 type IConfig interface {
 	InitProject(name, DBURL string) error
 	SelectProject(name string) error
 	GetProject(name *string) (Project, error)
-	SetProject(name *string, value Project) error
+	// UpdateProject saves what `fn` makes of the latest state of the project, other changes to the config wait until it is done.
+	// Nothing is saved if `fn` fails.
+	UpdateProject(name string, fn func(project *Project) error) error
 	GetAllProjects() (ProjectsList, error)
+	GetGroup(name string) (Group, error)
+	// SetGroup creates or replaces the group
+	SetGroup(group Group) error
+	DeleteGroup(name string) error
+	GetAllGroups() (GroupsList, error)
 }
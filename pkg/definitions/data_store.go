@@ -1,6 +1,20 @@
 package definitions
 
+import "context"
+
 type IDataStore interface {
 	Load() ([]byte, error)
 	Save([]byte) error
+	// Update saves what `fn` makes of the stored data, nothing is saved if it fails.
+	// Other updates, even from other processes, wait until it is done.
+	Update(fn func(data []byte) ([]byte, error)) error
+	// Append adds to the stored data without rewriting it
+	Append([]byte) error
+	// Sibling returns a store kept next to this one, `suffix` is appended to its name
+	Sibling(suffix string) IDataStore
+	// Lock keeps other processes from taking the lock `name` of the store until the returned unlock is called,
+	// it fails with values.LockedErr if another process holds the lock, unless `wait` is set
+	Lock(ctx context.Context, name string, wait bool) (func(), error)
+	// Dir returns the directory the store is kept in, empty if it isn't kept in one
+	Dir() string
 }
@@ -1,6 +1,7 @@
 package definitions
 
 import (
+	"context"
 	"ghostal/pkg/utils"
 	"time"
 )
@@ -9,25 +10,123 @@ type SnapshotListResult struct {
 	SnapshotName string
 	DBName       string
 	CreatedAt    time.Time
+	SizeBytes    int64
+	// Metadata is filled in from the project config, not by the operators
+	Metadata SnapshotMetadata
 }
 
 type SnapshotList []SnapshotListResult
 
+// WithMetadata attaches the metadata stored for each snapshot
+func (list SnapshotList) WithMetadata(metadata map[string]SnapshotMetadata) SnapshotList {
+	result := make(SnapshotList, len(list))
+	for idx, item := range list {
+		item.Metadata = metadata[item.SnapshotName]
+		result[idx] = item
+	}
+	return result
+}
+
+// FilterAutomatic keeps either the automatic or the regular snapshots, metadata must be attached first
+func (list SnapshotList) FilterAutomatic(automatic bool) SnapshotList {
+	result := make(SnapshotList, 0)
+	for _, item := range list {
+		if item.Metadata.Automatic == automatic {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Named keeps the snapshots named `snapshotName`, there is more than one only while a snapshot is being replaced
+func (list SnapshotList) Named(snapshotName string) SnapshotList {
+	result := make(SnapshotList, 0)
+	for _, item := range list {
+		if item.SnapshotName == snapshotName {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// WithoutTrashed hides the removed snapshots, metadata must be attached first
+func (list SnapshotList) WithoutTrashed() SnapshotList {
+	result := make(SnapshotList, 0)
+	for _, item := range list {
+		if !item.Metadata.Trashed {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// OnlyTrashed keeps the removed snapshots, metadata must be attached first
+func (list SnapshotList) OnlyTrashed() SnapshotList {
+	result := make(SnapshotList, 0)
+	for _, item := range list {
+		if item.Metadata.Trashed {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 func (list SnapshotList) TableInfo() ([]string, [][]string) {
-	columns := []string{"Name", "Created", "Timestamp"}
+	columns := []string{"Name", "Created", "Timestamp", "Size", "Description", "Tags", "Git", "Auto"}
 	rows := make([][]string, len(list))
 	for idx := range list {
 		item := list[idx]
 		relativeTime := utils.ToRelativeTime(item.CreatedAt, time.Now())
 		formattedTime := item.CreatedAt.Format("2006-01-02 15:04:05")
-		rows[idx] = []string{item.SnapshotName, relativeTime, formattedTime}
+		size := utils.FormatBytes(item.SizeBytes)
+		auto := ""
+		if item.Metadata.Automatic {
+			auto = "yes"
+		}
+		rows[idx] = []string{item.SnapshotName, relativeTime, formattedTime, size, item.Metadata.Description, item.Metadata.FormattedTags(), item.Metadata.Git(), auto}
 	}
 	return columns, rows
 }
 
+// DataInfo is TableInfo for machine-readable output, with RFC3339 timestamps and sizes in bytes
+func (list SnapshotList) DataInfo() ([]string, [][]any) {
+	columns := []string{"Name", "Created At", "Size Bytes", "Description", "Tags", "Git Commit", "Git Branch", "Automatic"}
+	rows := make([][]any, len(list))
+	for idx, item := range list {
+		tags := item.Metadata.Tags
+		if tags == nil {
+			tags = make([]string, 0)
+		}
+		rows[idx] = []any{
+			item.SnapshotName,
+			item.CreatedAt.UTC().Format(time.RFC3339),
+			item.SizeBytes,
+			item.Metadata.Description,
+			tags,
+			item.Metadata.GitCommit,
+			item.Metadata.GitBranch,
+			item.Metadata.Automatic,
+		}
+	}
+	return columns, rows
+}
+
+// IDBOperator methods stop as soon as `ctx` is done, an interrupted restore puts the original database back
 type IDBOperator interface {
-	Snapshot(snapshotName string) error
-	Restore(snapshotName string, fast bool) error
-	Delete(snapshotName string) error
-	ListSnapshots() (SnapshotList, error)
+	Snapshot(ctx context.Context, snapshotName string) error
+	Restore(ctx context.Context, snapshotName string, fast bool) error
+	Delete(ctx context.Context, snapshotName string) error
+	ListSnapshots(ctx context.Context) (SnapshotList, error)
+}
+
+// IReplacingOperator is implemented by operators that can replace a snapshot: the new snapshot is taken under the same
+// name first, and the previous one is only deleted once it exists, so that a failure never loses both
+type IReplacingOperator interface {
+	ReplaceSnapshot(ctx context.Context, snapshotName string) error
+}
+
+// IRelativePathOperator is implemented by operators whose database URL can hold a relative path,
+// which is relative to the directory of the config rather than to the working directory
+type IRelativePathOperator interface {
+	SetBaseDir(dir string)
 }
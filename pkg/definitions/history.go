@@ -0,0 +1,64 @@
+package definitions
+
+import (
+	"time"
+)
+
+const HistoryOutcomeOK = "ok"
+
+// HistoryEntry records a command that changed the config or a database
+type HistoryEntry struct {
+	Time         time.Time `json:"time"`
+	User         string    `json:"user,omitempty"`
+	Project      string    `json:"project,omitempty"`
+	Command      string    `json:"command"`
+	SnapshotName string    `json:"snapshotName,omitempty"`
+	DurationMs   int64     `json:"durationMs"`
+	// Outcome is HistoryOutcomeOK or the error message
+	Outcome string `json:"outcome"`
+}
+
+func (e HistoryEntry) Duration() time.Duration {
+	return time.Duration(e.DurationMs) * time.Millisecond
+}
+
+type HistoryList []HistoryEntry
+
+// Filter keeps the last `limit` entries of `projectName`, all projects if it's empty
+func (list HistoryList) Filter(projectName string, limit int) HistoryList {
+	result := make(HistoryList, 0)
+	for _, entry := range list {
+		if projectName == "" || entry.Project == projectName {
+			result = append(result, entry)
+		}
+	}
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
+
+func (list HistoryList) TableInfo() ([]string, [][]string) {
+	columns := []string{"Time", "User", "Project", "Command", "Snapshot", "Duration", "Outcome"}
+	rows := make([][]string, len(list))
+	for idx, entry := range list {
+		formattedTime := entry.Time.Format("2006-01-02 15:04:05")
+		rows[idx] = []string{formattedTime, entry.User, entry.Project, entry.Command, entry.SnapshotName, entry.Duration().String(), entry.Outcome}
+	}
+	return columns, rows
+}
+
+// DataInfo is TableInfo for machine-readable output, with RFC3339 timestamps and durations in milliseconds
+func (list HistoryList) DataInfo() ([]string, [][]any) {
+	columns := []string{"Time", "User", "Project", "Command", "Snapshot", "Duration Ms", "Outcome"}
+	rows := make([][]any, len(list))
+	for idx, entry := range list {
+		rows[idx] = []any{entry.Time.UTC().Format(time.RFC3339), entry.User, entry.Project, entry.Command, entry.SnapshotName, entry.DurationMs, entry.Outcome}
+	}
+	return columns, rows
+}
+
+type IHistory interface {
+	Append(entry HistoryEntry) error
+	List() (HistoryList, error)
+}
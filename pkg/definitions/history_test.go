@@ -0,0 +1,19 @@
+package definitions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_HistoryList_Filter(t *testing.T) {
+	list := HistoryList{
+		{Project: "a", Command: "init"},
+		{Project: "a", Command: "snapshot"},
+		{Project: "b", Command: "init"},
+		{Project: "a", Command: "restore"},
+	}
+	assert.Len(t, list.Filter("", 0), 4)
+	assert.Equal(t, HistoryList{list[0], list[1], list[3]}, list.Filter("a", 0))
+	assert.Equal(t, HistoryList{list[1], list[3]}, list.Filter("a", 2), "should keep the latest entries")
+	assert.Empty(t, list.Filter("c", 10))
+}
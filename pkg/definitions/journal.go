@@ -0,0 +1,32 @@
+package definitions
+
+import "time"
+
+type JournalOperation string
+
+const (
+	JournalSnapshot JournalOperation = "snapshot"
+	JournalRestore  JournalOperation = "restore"
+	JournalDelete   JournalOperation = "rm"
+)
+
+// JournalEntry records an operation that "gho undo" can revert
+type JournalEntry struct {
+	Project      string           `json:"project"`
+	Operation    JournalOperation `json:"operation"`
+	SnapshotName string           `json:"snapshotName"`
+	// AutoSnapshotName is the snapshot taken before a restore, restoring it reverts the restore
+	AutoSnapshotName string    `json:"autoSnapshotName,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+type JournalData struct {
+	Entries []JournalEntry `json:"entries"`
+}
+
+type IJournal interface {
+	Append(entry JournalEntry) error
+	// Last returns the latest entry of the project, or values.NothingToUndoErr
+	Last(projectName string) (JournalEntry, error)
+	RemoveLast(projectName string) error
+}
@@ -0,0 +1,47 @@
+package definitions
+
+import (
+	"context"
+	"fmt"
+	"ghostal/pkg/values"
+	"os"
+	"time"
+)
+
+// LockInfo tells which process holds a lock
+type LockInfo struct {
+	PID   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Since time.Time `json:"since"`
+}
+
+// NewLockInfo describes a lock taken by the current process
+func NewLockInfo() LockInfo {
+	host, _ := os.Hostname()
+	return LockInfo{
+		PID:   os.Getpid(),
+		Host:  host,
+		Since: time.Now(),
+	}
+}
+
+// LockedErr is the error returned when `resource` can't be locked because of this lock
+func (l LockInfo) LockedErr(resource string) error {
+	if l.PID == 0 {
+		return fmt.Errorf("%s is %w by another process", resource, values.LockedErr)
+	}
+	host := ""
+	if l.Host != "" {
+		host = " on " + l.Host
+	}
+	return fmt.Errorf("%s is %w by pid %d%s since %s", resource, values.LockedErr, l.PID, host, l.Since.Local().Format(time.DateTime))
+}
+
+// ILockingOperator is implemented by operators that can keep other gho processes away from their database
+type ILockingOperator interface {
+	// Lock fails with values.LockedErr if another process holds the lock, unless `wait` is set,
+	// the returned unlock must always be called.
+	// The work done under the lock uses the returned context, it is cancelled if the lock is lost,
+	// with the reason as its cause.
+	Lock(ctx context.Context, wait bool) (context.Context, func(), error)
+}
@@ -0,0 +1,22 @@
+package definitions
+
+import (
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUnit_LockInfo_LockedErr(t *testing.T) {
+	since := time.Date(2024, 5, 1, 10, 30, 0, 0, time.Local)
+	err := LockInfo{PID: 42, Host: "laptop", Since: since}.LockedErr("database \"app\"")
+	assert.ErrorIs(t, err, values.LockedErr)
+	assert.Equal(t, "database \"app\" is locked by pid 42 on laptop since 2024-05-01 10:30:00", err.Error())
+
+	err = LockInfo{}.LockedErr("config")
+	assert.ErrorIs(t, err, values.LockedErr)
+	assert.Equal(t, "config is locked by another process", err.Error())
+
+	assert.Equal(t, os.Getpid(), NewLockInfo().PID)
+}
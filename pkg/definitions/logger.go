@@ -13,3 +13,8 @@ type ILogger interface {
 
 	Fatal(msg string, keysAndValues ...interface{})
 }
+
+// ILoggingOperator is implemented by operators that can warn about what they come across, e.g. unreadable snapshots
+type ILoggingOperator interface {
+	SetLogger(logger ILogger)
+}
@@ -0,0 +1,34 @@
+package definitions
+
+// Progress describes how far a long snapshot or restore has come
+type Progress struct {
+	// Project is filled in by the app, not by the operators
+	Project string
+	Phase   string
+	// Current and Total count the units of work of the phase, e.g. documents, Total is 0 if the phase isn't measured
+	Current int64
+	Total   int64
+}
+
+// Percent returns how much of the phase is done, -1 if it isn't measured
+func (p Progress) Percent() int {
+	if p.Total <= 0 {
+		return -1
+	}
+	if p.Current >= p.Total {
+		return 100
+	}
+	return int(p.Current * 100 / p.Total)
+}
+
+type IProgressReporter interface {
+	// Report may be called from several goroutines at once
+	Report(progress Progress)
+	// Clear erases the live progress, so that other output can be printed
+	Clear()
+}
+
+// IProgressReportingOperator is implemented by operators that can report the progress of their operations
+type IProgressReportingOperator interface {
+	SetProgressReporter(reporter IProgressReporter)
+}
@@ -0,0 +1,13 @@
+package definitions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_Progress_Percent(t *testing.T) {
+	assert.Equal(t, -1, Progress{Phase: "dropping the backup"}.Percent())
+	assert.Equal(t, 0, Progress{Current: 0, Total: 3}.Percent())
+	assert.Equal(t, 33, Progress{Current: 1, Total: 3}.Percent())
+	assert.Equal(t, 100, Progress{Current: 4, Total: 3}.Percent())
+}
@@ -0,0 +1,47 @@
+package definitions
+
+import "context"
+
+// EmergencyBackup is the backup of the database taken by a restore, it is only left behind when gho was killed
+// before the restore could finish or roll back
+type EmergencyBackup struct {
+	Name string
+	// Complete is false when gho was killed while taking the backup, the database wasn't changed yet then
+	Complete bool
+	// DBExists is false when gho was killed after dropping the database, before copying the snapshot
+	DBExists bool
+}
+
+// CanRollBack tells whether the backup can replace the database
+func (b EmergencyBackup) CanRollBack() bool {
+	return b.Complete
+}
+
+// CanRollForward tells whether the database can be kept and the backup dropped
+func (b EmergencyBackup) CanRollForward() bool {
+	return b.DBExists
+}
+
+// State explains how the restore was interrupted
+func (b EmergencyBackup) State() string {
+	switch {
+	case !b.Complete && b.DBExists:
+		return "the restore was interrupted while backing up the database, which wasn't changed"
+	case !b.Complete:
+		return "the restore was interrupted while backing up the database, and the database is missing"
+	case !b.DBExists:
+		return "the restore was interrupted after dropping the database, which is missing"
+	default:
+		return "the restore was interrupted while copying the snapshot or dropping the backup, the database may be incomplete"
+	}
+}
+
+// IRecoveringOperator is implemented by operators that can recover from a restore that was killed
+type IRecoveringOperator interface {
+	// FindEmergencyBackup returns nil if no restore was interrupted
+	FindEmergencyBackup(ctx context.Context) (*EmergencyBackup, error)
+	// RollBack replaces the database by the emergency backup, as if the restore never happened
+	RollBack(ctx context.Context) error
+	// RollForward keeps the database as it is and drops the emergency backup
+	RollForward(ctx context.Context) error
+}
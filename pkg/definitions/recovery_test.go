@@ -0,0 +1,27 @@
+package definitions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_EmergencyBackup(t *testing.T) {
+	partial := EmergencyBackup{Complete: false, DBExists: true}
+	assert.False(t, partial.CanRollBack())
+	assert.True(t, partial.CanRollForward())
+	assert.Contains(t, partial.State(), "wasn't changed")
+
+	dropped := EmergencyBackup{Complete: true, DBExists: false}
+	assert.True(t, dropped.CanRollBack())
+	assert.False(t, dropped.CanRollForward())
+	assert.Contains(t, dropped.State(), "missing")
+
+	copied := EmergencyBackup{Complete: true, DBExists: true}
+	assert.True(t, copied.CanRollBack())
+	assert.True(t, copied.CanRollForward())
+	assert.Contains(t, copied.State(), "may be incomplete")
+
+	lost := EmergencyBackup{Complete: false, DBExists: false}
+	assert.False(t, lost.CanRollBack())
+	assert.False(t, lost.CanRollForward())
+}
@@ -0,0 +1,57 @@
+package definitions
+
+import (
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which snapshots of a project are kept, unset limits don't apply
+type RetentionPolicy struct {
+	KeepLast     *int
+	MaxAge       *time.Duration
+	MaxTotalSize *int64
+}
+
+func (r RetentionPolicy) IsEmpty() bool {
+	return r.KeepLast == nil && r.MaxAge == nil && r.MaxTotalSize == nil
+}
+
+// Evaluate returns the snapshots of `list` that fall outside the policy, oldest first.
+// Newer snapshots take precedence, so once a snapshot no longer fits the total size, neither do the older ones.
+func (r RetentionPolicy) Evaluate(list SnapshotList, now time.Time) SnapshotList {
+	newestFirst := make(SnapshotList, len(list))
+	copy(newestFirst, list)
+	sort.SliceStable(newestFirst, func(i, j int) bool {
+		return newestFirst[i].CreatedAt.After(newestFirst[j].CreatedAt)
+	})
+
+	pruned := make(SnapshotList, 0)
+	var totalSize int64
+	sizeExceeded := false
+	for idx, item := range newestFirst {
+		keep := true
+		if r.KeepLast != nil && idx >= *r.KeepLast {
+			keep = false
+		}
+		if r.MaxAge != nil && now.Sub(item.CreatedAt) > *r.MaxAge {
+			keep = false
+		}
+		if r.MaxTotalSize != nil && keep {
+			if sizeExceeded || totalSize+item.SizeBytes > *r.MaxTotalSize {
+				sizeExceeded = true
+				keep = false
+			} else {
+				totalSize += item.SizeBytes
+			}
+		}
+		if !keep {
+			pruned = append(pruned, item)
+		}
+	}
+
+	// oldest first
+	for i, j := 0, len(pruned)-1; i < j; i, j = i+1, j-1 {
+		pruned[i], pruned[j] = pruned[j], pruned[i]
+	}
+	return pruned
+}
@@ -0,0 +1,62 @@
+package definitions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func names(list SnapshotList) []string {
+	result := make([]string, 0)
+	for _, item := range list {
+		result = append(result, item.SnapshotName)
+	}
+	return result
+}
+
+func testSnapshotList(now time.Time) SnapshotList {
+	// deliberately out of order
+	return SnapshotList{
+		{SnapshotName: "v3", CreatedAt: now.Add(-1 * time.Hour), SizeBytes: 300},
+		{SnapshotName: "v1", CreatedAt: now.Add(-72 * time.Hour), SizeBytes: 100},
+		{SnapshotName: "v4", CreatedAt: now.Add(-1 * time.Minute), SizeBytes: 400},
+		{SnapshotName: "v2", CreatedAt: now.Add(-48 * time.Hour), SizeBytes: 200},
+	}
+}
+
+func TestUnit_RetentionPolicy_Empty(t *testing.T) {
+	now := time.Now()
+	policy := RetentionPolicy{}
+	assert.True(t, policy.IsEmpty())
+	assert.Empty(t, policy.Evaluate(testSnapshotList(now), now))
+}
+
+func TestUnit_RetentionPolicy_KeepLast(t *testing.T) {
+	now := time.Now()
+	keepLast := 2
+	policy := RetentionPolicy{KeepLast: &keepLast}
+	assert.Equal(t, []string{"v1", "v2"}, names(policy.Evaluate(testSnapshotList(now), now)))
+}
+
+func TestUnit_RetentionPolicy_MaxAge(t *testing.T) {
+	now := time.Now()
+	maxAge := 24 * time.Hour
+	policy := RetentionPolicy{MaxAge: &maxAge}
+	assert.Equal(t, []string{"v1", "v2"}, names(policy.Evaluate(testSnapshotList(now), now)))
+}
+
+func TestUnit_RetentionPolicy_MaxTotalSize(t *testing.T) {
+	now := time.Now()
+	maxTotalSize := int64(750)
+	policy := RetentionPolicy{MaxTotalSize: &maxTotalSize}
+	// v4 (400) + v3 (300) fit, v2 (200) doesn't and neither does the older v1 even though it would fit
+	assert.Equal(t, []string{"v1", "v2"}, names(policy.Evaluate(testSnapshotList(now), now)))
+}
+
+func TestUnit_RetentionPolicy_Combined(t *testing.T) {
+	now := time.Now()
+	keepLast := 3
+	maxAge := 60 * time.Hour
+	policy := RetentionPolicy{KeepLast: &keepLast, MaxAge: &maxAge}
+	assert.Equal(t, []string{"v1"}, names(policy.Evaluate(testSnapshotList(now), now)))
+}
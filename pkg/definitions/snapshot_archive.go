@@ -0,0 +1,44 @@
+package definitions
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type SnapshotArchiveManifest struct {
+	Version      int       `json:"version"`
+	DBType       string    `json:"dbType"`
+	DBName       string    `json:"dbName"`
+	SnapshotName string    `json:"snapshotName"`
+	CreatedAt    time.Time `json:"createdAt"`
+	ExportedAt   time.Time `json:"exportedAt"`
+	// Metadata is optional since it lives in the project config of the exporter
+	Metadata *SnapshotMetadata `json:"metadata,omitempty"`
+}
+
+type ISnapshotArchiveWriter interface {
+	// CreateEntry adds a file to the archive, which must be fully written before the next entry is created
+	CreateEntry(name string) (io.Writer, error)
+}
+
+// ISnapshotExporter is implemented by operators that can stream a snapshot out to an archive
+type ISnapshotExporter interface {
+	ExportSnapshot(ctx context.Context, snapshotName string, archive ISnapshotArchiveWriter) error
+}
+
+type ISnapshotArchiveReader interface {
+	Manifest() SnapshotArchiveManifest
+	EntryNames() []string
+	OpenEntry(name string) (io.ReadCloser, error)
+}
+
+// ISnapshotImporter is implemented by operators that can materialize an archive as a snapshot
+type ISnapshotImporter interface {
+	ImportSnapshot(ctx context.Context, snapshotName string, archive ISnapshotArchiveReader) error
+}
+
+// IReplacingImporter is IReplacingOperator for imports, the previous snapshot is only deleted once the archive is imported
+type IReplacingImporter interface {
+	ReplaceWithImportedSnapshot(ctx context.Context, snapshotName string, archive ISnapshotArchiveReader) error
+}
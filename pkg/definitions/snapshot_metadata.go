@@ -0,0 +1,34 @@
+package definitions
+
+import "strings"
+
+// SnapshotMetadata is what gho knows about a snapshot besides what is stored in the database itself
+type SnapshotMetadata struct {
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	GitCommit   string   `json:"gitCommit,omitempty"`
+	GitBranch   string   `json:"gitBranch,omitempty"`
+	// Automatic is set on the snapshots taken by gho itself, e.g. before a restore
+	Automatic bool `json:"automatic,omitempty"`
+	// Trashed is set on a removed snapshot that is kept until the next "gho rm", so that the removal can be undone
+	Trashed bool `json:"trashed,omitempty"`
+}
+
+func (m SnapshotMetadata) IsEmpty() bool {
+	return m.Description == "" && len(m.Tags) == 0 && m.GitCommit == "" && m.GitBranch == "" && !m.Automatic && !m.Trashed
+}
+
+// Git formats the source info as "branch@commit"
+func (m SnapshotMetadata) Git() string {
+	if m.GitBranch == "" {
+		return m.GitCommit
+	}
+	if m.GitCommit == "" {
+		return m.GitBranch
+	}
+	return m.GitBranch + "@" + m.GitCommit
+}
+
+func (m SnapshotMetadata) FormattedTags() string {
+	return strings.Join(m.Tags, ", ")
+}
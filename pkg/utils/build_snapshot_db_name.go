@@ -4,16 +4,13 @@ import (
 	"fmt"
 	"ghostal/pkg/values"
 	"time"
-	"unicode"
 )
 
 func BuildSnapshotDBName(sourceDBName, snapshotName string, timestamp time.Time) (string, error) {
-	// only allow alphanumeric chars
-	for _, c := range snapshotName {
-		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
-			return "", values.NoSpecialCharsErr
-		}
+	if len(snapshotName) == 0 {
+		return "", values.EmptySnapshotNameErr
 	}
-	result := fmt.Sprintf("%s%s_%s_%d", values.SnapshotDBPrefix, sourceDBName, snapshotName, timestamp.UnixMilli())
+	// special chars are escaped so that the name stays a valid database name
+	result := fmt.Sprintf("%s%s_%s_%d", values.SnapshotDBPrefix, sourceDBName, EncodeSnapshotName(snapshotName), timestamp.UnixMilli())
 	return result, nil
 }
@@ -24,9 +24,25 @@ func TestUnit_BuildSnapshotDBName_ValidInput(t *testing.T) {
 	assert.Equal(t, nowUnixStr, timestampStr)
 }
 
+func TestUnit_BuildSnapshotDBName_SpecialChars(t *testing.T) {
+	inputDBName := "myDB"
+	inputSnapshotName := "nice-app_v1.2"
+	inputTimestamp := time.Now()
+	output, err := BuildSnapshotDBName(inputDBName, inputSnapshotName, inputTimestamp)
+	assert.NoError(t, err)
+	parts := strings.Split(output, "_")
+	assert.Len(t, parts, 4)
+	assert.Equal(t, "nice-2Dapp-5Fv1-2E2", parts[2])
+
+	parsed, err := ParseSnapshotDBName(output)
+	assert.NoError(t, err)
+	assert.Equal(t, inputDBName, parsed.SourceDBName)
+	assert.Equal(t, inputSnapshotName, parsed.SnapshotName)
+}
+
 func TestUnit_BuildSnapshotDBName_InvalidInput(t *testing.T) {
 	inputDBName := "myDB"
-	inputSnapshotName := "nice-app"
+	inputSnapshotName := ""
 	inputTimestamp := time.Now()
 	_, err := BuildSnapshotDBName(inputDBName, inputSnapshotName, inputTimestamp)
 	assert.Error(t, err)
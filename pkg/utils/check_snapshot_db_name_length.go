@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"fmt"
+	"ghostal/pkg/values"
+)
+
+// CheckSnapshotDBNameLength fails if `snapshotDBName` is longer than the `maxLength` bytes the database allows for a name.
+// Special chars take 3 bytes each once escaped.
+func CheckSnapshotDBNameLength(snapshotDBName string, maxLength int) error {
+	if len(snapshotDBName) <= maxLength {
+		return nil
+	}
+	return fmt.Errorf("%w: the snapshot database name \"%s\" is %d characters long, the database allows %d, use a shorter snapshot name or fewer special characters",
+		values.SnapshotNameTooLongErr, snapshotDBName, len(snapshotDBName), maxLength)
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"ghostal/pkg/values"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnit_CheckSnapshotDBNameLength(t *testing.T) {
+	assert.NoError(t, CheckSnapshotDBNameLength("ghostalsnapshot_app_v1_1714550400000", 64))
+	assert.NoError(t, CheckSnapshotDBNameLength(strings.Repeat("a", 64), 64))
+
+	// every special char takes 3 characters of the database name
+	snapshotDBName, err := BuildSnapshotDBName("app", "before-migration_42.1", time.UnixMilli(1714550400000))
+	assert.NoError(t, err)
+	err = CheckSnapshotDBNameLength(snapshotDBName, 32)
+	assert.ErrorIs(t, err, values.SnapshotNameTooLongErr)
+	assert.Contains(t, err.Error(), "is 61 characters long, the database allows 32")
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// snapshotNameEscape starts an escaped byte, chosen since it is valid in the database names of every supported database
+const snapshotNameEscape = '-'
+
+func isPlainSnapshotNameByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// EncodeSnapshotName escapes every byte outside of [A-Za-z0-9] as "-XX" (hex), so that any name can be
+// part of a database name and never contains the "_" separator. Alphanumeric names are left unchanged.
+func EncodeSnapshotName(snapshotName string) string {
+	var sb strings.Builder
+	for idx := 0; idx < len(snapshotName); idx++ {
+		c := snapshotName[idx]
+		if isPlainSnapshotNameByte(c) {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%c%02X", snapshotNameEscape, c))
+	}
+	return sb.String()
+}
+
+// DecodeSnapshotName reverses EncodeSnapshotName
+func DecodeSnapshotName(encodedName string) (string, error) {
+	var sb strings.Builder
+	for idx := 0; idx < len(encodedName); idx++ {
+		c := encodedName[idx]
+		if c != snapshotNameEscape {
+			sb.WriteByte(c)
+			continue
+		}
+		if idx+2 >= len(encodedName) {
+			return "", fmt.Errorf("invalid escape sequence in snapshot name \"%s\"", encodedName)
+		}
+		// hex digits may have been lowercased by the database
+		value, err := strconv.ParseUint(encodedName[idx+1:idx+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape sequence in snapshot name \"%s\"", encodedName)
+		}
+		sb.WriteByte(byte(value))
+		idx += 2
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_EncodeSnapshotName(t *testing.T) {
+	assert.Equal(t, "niceapp", EncodeSnapshotName("niceapp"))
+	assert.Equal(t, "before-2Dmigration-5F42", EncodeSnapshotName("before-migration_42"))
+	assert.Equal(t, "v1-2E2", EncodeSnapshotName("v1.2"))
+	assert.Equal(t, "caf-C3-A9", EncodeSnapshotName("café"))
+}
+
+func TestUnit_DecodeSnapshotName(t *testing.T) {
+	for _, name := range []string{"niceapp", "before-migration_42", "v1.2", "café", "a b/c\\d", "--", "_"} {
+		encoded := EncodeSnapshotName(name)
+		assert.NotContains(t, encoded, "_")
+		decoded, err := DecodeSnapshotName(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, name, decoded)
+	}
+
+	// lowercased by the database
+	decoded, err := DecodeSnapshotName("v1-2e2")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.2", decoded)
+
+	_, err = DecodeSnapshotName("v1-2")
+	assert.Error(t, err)
+	_, err = DecodeSnapshotName("v1-ZZ")
+	assert.Error(t, err)
+}
@@ -0,0 +1,16 @@
+package utils
+
+import "fmt"
+
+func FormatBytes(numBytes int64) string {
+	const unit = 1024
+	if numBytes < unit {
+		return fmt.Sprintf("%d B", numBytes)
+	}
+	div, exp := int64(unit), 0
+	for n := numBytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(numBytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_FormatBytes(t *testing.T) {
+	assert.Equal(t, "0 B", FormatBytes(0))
+	assert.Equal(t, "1023 B", FormatBytes(1023))
+	assert.Equal(t, "1.0 KiB", FormatBytes(1024))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "8.0 MiB", FormatBytes(8*1024*1024))
+	assert.Equal(t, "2.0 GiB", FormatBytes(2*1024*1024*1024))
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"os/exec"
+	"strings"
+)
+
+type GitInfo struct {
+	Commit string
+	Branch string
+}
+
+func runGit(dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// GetGitInfo returns the commit and branch checked out in `dir`, both empty if it isn't a git repository
+func GetGitInfo(dir string) GitInfo {
+	if _, err := exec.LookPath("git"); err != nil {
+		return GitInfo{}
+	}
+	commit := runGit(dir, "rev-parse", "--short", "HEAD")
+	if commit == "" {
+		return GitInfo{}
+	}
+	branch := runGit(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if branch == "HEAD" {
+		// detached
+		branch = ""
+	}
+	return GitInfo{
+		Commit: commit,
+		Branch: branch,
+	}
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os/exec"
+	"testing"
+)
+
+func TestUnit_GetGitInfo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	assert.Equal(t, GitInfo{}, GetGitInfo(dir), "should be empty outside of a repository")
+
+	for _, args := range [][]string{
+		{"init", "--initial-branch=main"},
+		{"-c", "user.name=gho", "-c", "user.email=gho@example.com", "commit", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		assert.NoError(t, cmd.Run())
+	}
+	info := GetGitInfo(dir)
+	assert.Equal(t, "main", info.Branch)
+	assert.NotEmpty(t, info.Commit)
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"os"
+	"os/user"
+)
+
+// GetUsername returns the name of the user running the program, empty if unknown
+func GetUsername() string {
+	return getUsername(user.Current)
+}
+
+func getUsername(currentUser func() (*user.User, error)) string {
+	if current, err := currentUser(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	if username := os.Getenv("USER"); username != "" {
+		return username
+	}
+	return os.Getenv("USERNAME")
+}
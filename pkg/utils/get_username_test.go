@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os/user"
+	"testing"
+)
+
+func TestUnit_GetUsername(t *testing.T) {
+	knownUser := func() (*user.User, error) {
+		return &user.User{Username: "alice"}, nil
+	}
+	// e.g. minimal containers without a passwd entry
+	unknownUser := func() (*user.User, error) {
+		return nil, errors.New("unknown user")
+	}
+	t.Setenv("USER", "bob")
+	t.Setenv("USERNAME", "carol")
+
+	assert.Equal(t, "alice", getUsername(knownUser))
+	assert.Equal(t, "bob", getUsername(unknownUser))
+
+	t.Setenv("USER", "")
+	assert.Equal(t, "carol", getUsername(unknownUser))
+
+	t.Setenv("USERNAME", "")
+	assert.Equal(t, "", getUsername(unknownUser))
+}
@@ -0,0 +1,12 @@
+package utils
+
+import "os"
+
+// IsTerminal tells whether `file` is an interactive terminal rather than a pipe or a regular file
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnit_IsTerminal(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output.txt"))
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.False(t, IsTerminal(file))
+
+	reader, writer, err := os.Pipe()
+	assert.NoError(t, err)
+	defer reader.Close()
+	defer writer.Close()
+	assert.False(t, IsTerminal(writer))
+}
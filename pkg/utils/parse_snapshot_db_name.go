@@ -18,6 +18,9 @@ func ParseSnapshotDBName(fullDBName string) (SnapshotDBNameParts, error) {
 	withoutPrefix := strings.TrimPrefix(fullDBName, values.SnapshotDBPrefix)
 
 	partsAfterPrefix := strings.Split(withoutPrefix, "_")
+	if len(partsAfterPrefix) < 3 {
+		return SnapshotDBNameParts{}, fmt.Errorf("invalid snapshot database name \"%s\"", fullDBName)
+	}
 
 	// go backwards from timestamp
 	position := len(partsAfterPrefix) - 1
@@ -29,7 +32,10 @@ func ParseSnapshotDBName(fullDBName string) (SnapshotDBNameParts, error) {
 
 	position -= 1
 
-	snapshotName := partsAfterPrefix[position]
+	snapshotName, err := DecodeSnapshotName(partsAfterPrefix[position])
+	if err != nil {
+		return SnapshotDBNameParts{}, err
+	}
 
 	position -= 1
 
@@ -32,3 +32,17 @@ func TestUnit_ParseSnapshotDBNameCaseSensitive(t *testing.T) {
 	assert.Equal(t, "v3", output.SnapshotName)
 	assert.Equal(t, time.UnixMilli(1712976085062), output.Timestamp)
 }
+
+func TestUnit_ParseSnapshotDBNameEscaped(t *testing.T) {
+	input := "ghostalsnapshot_my_db_before-2Dmigration-5F42_1712976085063"
+	output, err := ParseSnapshotDBName(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "my_db", output.SourceDBName)
+	assert.Equal(t, "before-migration_42", output.SnapshotName)
+	assert.Equal(t, time.UnixMilli(1712976085063), output.Timestamp)
+}
+
+func TestUnit_ParseSnapshotDBNameInvalid(t *testing.T) {
+	_, err := ParseSnapshotDBName("ghostalsnapshot_1712976085063")
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// Poll calls `fn` right away and then every `interval` until it is done, fails, or `ctx` is done
+func Poll(ctx context.Context, interval time.Duration, fn func() (bool, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done, err := fn()
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUnit_Poll(t *testing.T) {
+	calls := 0
+	err := Poll(context.Background(), time.Millisecond, func() (bool, error) {
+		calls++
+		return calls == 3, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	testErr := errors.New("test err")
+	err = Poll(context.Background(), time.Millisecond, func() (bool, error) {
+		return false, testErr
+	})
+	assert.ErrorIs(t, err, testErr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = Poll(ctx, time.Millisecond, func() (bool, error) {
+		return false, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
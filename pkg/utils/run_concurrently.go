@@ -0,0 +1,53 @@
+package utils
+
+import "sync"
+
+// RunConcurrently calls `fn` for every item using at most `workers` goroutines.
+// No new items are started once an error occurs, and the first error is returned.
+func RunConcurrently[T any](items []T, workers int, fn func(item T) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	next := 0
+	take := func() (T, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		var item T
+		if firstErr != nil || next >= len(items) {
+			return item, false
+		}
+		item = items[next]
+		next++
+		return item, true
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, ok := take()
+				if !ok {
+					return
+				}
+				if err := fn(item); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUnit_RunConcurrently(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	var sum int64
+	var running int64
+	var maxRunning int64
+	err := RunConcurrently(items, 3, func(item int) error {
+		current := atomic.AddInt64(&running, 1)
+		for {
+			seen := atomic.LoadInt64(&maxRunning)
+			if current <= seen || atomic.CompareAndSwapInt64(&maxRunning, seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		atomic.AddInt64(&sum, int64(item))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 55, sum)
+	assert.LessOrEqual(t, maxRunning, int64(3))
+}
+
+func TestUnit_RunConcurrently_Error(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	testErr := errors.New("test err")
+	var calls int64
+	err := RunConcurrently(items, 1, func(item int) error {
+		atomic.AddInt64(&calls, 1)
+		if item == 3 {
+			return testErr
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, testErr)
+	assert.EqualValues(t, 3, calls, "should stop after first error")
+}
+
+func TestUnit_RunConcurrently_Empty(t *testing.T) {
+	assert.NoError(t, RunConcurrently([]string{}, 4, func(item string) error {
+		return errors.New("should not be called")
+	}))
+}
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func StringAsBool(str string) (bool, error) {
@@ -14,3 +17,61 @@ func StringAsBool(str string) (bool, error) {
 		return false, fmt.Errorf("failed to parse \"%s\" as bool", str)
 	}
 }
+
+func StringAsPositiveInt(str string) (int, error) {
+	value, err := strconv.Atoi(str)
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("failed to parse \"%s\" as positive integer", str)
+	}
+	return value, nil
+}
+
+// StringAsDuration accepts the units of time.ParseDuration plus days ("d") and weeks ("w"), e.g. "7d" or "36h"
+func StringAsDuration(str string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if !strings.HasSuffix(str, suffix) {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSuffix(str, suffix), 64)
+		if err != nil || value <= 0 {
+			return 0, fmt.Errorf("failed to parse \"%s\" as duration", str)
+		}
+		return time.Duration(value * float64(unit)), nil
+	}
+	value, err := time.ParseDuration(str)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("failed to parse \"%s\" as duration", str)
+	}
+	return value, nil
+}
+
+// StringAsBytes accepts a number of bytes with an optional unit, e.g. "512MB", "2GiB" or "1024"
+func StringAsBytes(str string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		// longest suffixes first
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000}, {"TB", 1000 * 1000 * 1000 * 1000},
+		{"B", 1},
+	}
+	numberPart := strings.TrimSpace(str)
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(strings.ToUpper(numberPart), strings.ToUpper(unit.suffix)) {
+			numberPart = strings.TrimSpace(numberPart[:len(numberPart)-len(unit.suffix)])
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(numberPart, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("failed to parse \"%s\" as size", str)
+	}
+	return int64(value * float64(multiplier)), nil
+}
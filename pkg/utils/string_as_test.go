@@ -3,6 +3,7 @@ package utils
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestUnit_StringAsBool_True(t *testing.T) {
@@ -21,3 +22,54 @@ func TestUnit_StringAsBool_Error(t *testing.T) {
 	_, err := StringAsBool("blah")
 	assert.Error(t, err)
 }
+
+func TestUnit_StringAsPositiveInt(t *testing.T) {
+	value, err := StringAsPositiveInt("5")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, value)
+
+	for _, str := range []string{"0", "-1", "five"} {
+		_, err := StringAsPositiveInt(str)
+		assert.Error(t, err, str)
+	}
+}
+
+func TestUnit_StringAsDuration(t *testing.T) {
+	expected := map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for str, duration := range expected {
+		value, err := StringAsDuration(str)
+		assert.NoError(t, err, str)
+		assert.Equal(t, duration, value, str)
+	}
+
+	for _, str := range []string{"", "d", "-1d", "0h", "week"} {
+		_, err := StringAsDuration(str)
+		assert.Error(t, err, str)
+	}
+}
+
+func TestUnit_StringAsBytes(t *testing.T) {
+	expected := map[string]int64{
+		"1024":   1024,
+		"512MB":  512 * 1000 * 1000,
+		"2GiB":   2 * 1024 * 1024 * 1024,
+		"1.5KiB": 1536,
+		"10 gb":  10 * 1000 * 1000 * 1000,
+		"100B":   100,
+	}
+	for str, numBytes := range expected {
+		value, err := StringAsBytes(str)
+		assert.NoError(t, err, str)
+		assert.Equal(t, numBytes, value, str)
+	}
+
+	for _, str := range []string{"", "GB", "-1GB", "lots"} {
+		_, err := StringAsBytes(str)
+		assert.Error(t, err, str)
+	}
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ToCamelCase turns a column title like "Snapshot Name" into "snapshotName"
+func ToCamelCase(title string) string {
+	var builder strings.Builder
+	for idx, word := range strings.Fields(title) {
+		runes := []rune(strings.ToLower(word))
+		if idx > 0 {
+			runes[0] = unicode.ToUpper(runes[0])
+		}
+		builder.WriteString(string(runes))
+	}
+	return builder.String()
+}
@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnit_ToCamelCase(t *testing.T) {
+	assert.Equal(t, "name", ToCamelCase("Name"))
+	assert.Equal(t, "snapshotName", ToCamelCase("Snapshot Name"))
+	assert.Equal(t, "dbUrl", ToCamelCase(" DB  URL "))
+	assert.Equal(t, "", ToCamelCase(""))
+}
@@ -1,6 +1,23 @@
 package values
 
+import "time"
+
 const DefaultConfigFilepath = ".ghostal"
 const SnapshotDBPrefix = "ghostalsnapshot_"
 const ConfigScanClimbMaxDepth = 50
 const Unknown = "<UNKNOWN>"
+const SnapshotArchiveVersion = 1
+const SnapshotArchiveManifestName = "manifest.json"
+const AutoSnapshotPrefix = "autoBeforeRestore"
+const DefaultAutoSnapshotKeepLast = 5
+const JournalFileSuffix = ".journal"
+const JournalMaxEntries = 100
+const HistoryFileSuffix = ".history"
+const DefaultHistoryLimit = 20
+const DefaultWorkers = 4
+const LockFileSuffix = ".lock"
+const UpdateLockFileSuffix = ".update.lock"
+const LockPollInterval = 250 * time.Millisecond
+const TableOutputFormat = "table"
+const JSONOutputFormat = "json"
+const YAMLOutputFormat = "yaml"
@@ -0,0 +1,44 @@
+package values
+
+import (
+	"context"
+	"errors"
+)
+
+const UnknownErrorCode = "error"
+
+// errorCodes are part of the machine-readable output, they must never change
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{NoProgramArgsProvidedError, "no_program_args"},
+	{EmptySnapshotNameErr, "empty_snapshot_name"},
+	{SnapshotNameTakenErr, "snapshot_name_taken"},
+	{SnapshotNotExistsErr, "snapshot_not_found"},
+	{UnsupportedURLSchemeError, "unsupported_url_scheme"},
+	{InvalidSnapshotArchiveErr, "invalid_snapshot_archive"},
+	{NothingToUndoErr, "nothing_to_undo"},
+	{ProjectNotFoundErr, "project_not_found"},
+	{UnknownCommandErr, "unknown_command"},
+	{UnknownOutputFormatErr, "unknown_output_format"},
+	{InvalidUsageErr, "invalid_usage"},
+	{GroupNotFoundErr, "group_not_found"},
+	{EmergencyBackupFoundErr, "recovery_needed"},
+	{NothingToRecoverErr, "nothing_to_recover"},
+	{LockedErr, "locked"},
+	{SnapshotNameTooLongErr, "snapshot_name_too_long"},
+	{MissingDatabaseNameErr, "missing_database_name"},
+	{context.Canceled, "cancelled"},
+	{context.DeadlineExceeded, "timeout"},
+}
+
+// ErrorCode returns the stable code of `err`, UnknownErrorCode if it isn't one of the known errors
+func ErrorCode(err error) string {
+	for _, item := range errorCodes {
+		if errors.Is(err, item.err) {
+			return item.code
+		}
+	}
+	return UnknownErrorCode
+}
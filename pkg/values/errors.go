@@ -3,7 +3,19 @@ package values
 import "errors"
 
 var NoProgramArgsProvidedError = errors.New("no program arguments provided")
-var NoSpecialCharsErr = errors.New("snapshot name can only contain alphanumeric characters")
+var EmptySnapshotNameErr = errors.New("snapshot name cannot be empty")
 var SnapshotNameTakenErr = errors.New("snapshot name already used")
+var SnapshotNameTooLongErr = errors.New("snapshot name is too long")
 var SnapshotNotExistsErr = errors.New("snapshot does not exist")
 var UnsupportedURLSchemeError = errors.New("url scheme is unsupported")
+var InvalidSnapshotArchiveErr = errors.New("file is not a valid snapshot archive")
+var NothingToUndoErr = errors.New("nothing to undo")
+var ProjectNotFoundErr = errors.New("project not found")
+var UnknownCommandErr = errors.New("unknown command")
+var UnknownOutputFormatErr = errors.New("unknown output format")
+var InvalidUsageErr = errors.New("invalid usage")
+var GroupNotFoundErr = errors.New("group not found")
+var EmergencyBackupFoundErr = errors.New("an interrupted restore left an emergency backup behind")
+var NothingToRecoverErr = errors.New("nothing to recover")
+var LockedErr = errors.New("locked")
+var MissingDatabaseNameErr = errors.New("database URL has no database name")